@@ -35,6 +35,32 @@ class Stack extends cdk.Stack {
       })
     });
 
+    // Post a comment back onto a resurfaced page. The Lambda requires a workspace key
+    // or the reflect_secret from Secrets Manager, in the X-Reflect-Secret header
+    api.addRoutes({
+      path: "/reflect",
+      methods: [apigw.HttpMethod.POST],
+      integration: new integrations.LambdaProxyIntegration({
+        handler: apiHandler,
+      })
+    });
+
+    // Let other workspaces authorize the integration through OAuth
+    api.addRoutes({
+      path: "/oauth/authorize",
+      methods: [apigw.HttpMethod.GET],
+      integration: new integrations.LambdaProxyIntegration({
+        handler: apiHandler,
+      })
+    });
+    api.addRoutes({
+      path: "/oauth/callback",
+      methods: [apigw.HttpMethod.GET],
+      integration: new integrations.LambdaProxyIntegration({
+        handler: apiHandler,
+      })
+    });
+
     // Grant access to AWS Secret Manager
     const apiKeySecretArn = "arn:aws:secretsmanager:us-west-2:760655967349:secret:random-notion/notion-api-zFj6xG";
     const apiKeySecret = secretsmanager.Secret.fromSecretCompleteArn(
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	selection "github.com/jeffrosenberg/random-notion/internal/pageselection"
-	"github.com/jeffrosenberg/random-notion/internal/persistence"
 	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/jeffrosenberg/random-notion/pkg/render"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -23,56 +31,268 @@ const (
 	SecretRegion = "us-west-2"
 )
 
+const (
+	FormatUrl      = "url"
+	FormatMarkdown = "markdown"
+	FormatHtml     = "html"
+)
+
 type AwsSecret struct {
-	Token      string `json:"token"`
-	DatabaseId string `json:"database_id"`
+	Token           string         `json:"token"`
+	DatabaseId      string         `json:"database_id"`
+	DatabaseIds     []string       `json:"database_ids,omitempty"` // Further databases to pick pages from
+	Filter          *notion.Filter `json:"filter,omitempty"`
+	NotionVersion   string         `json:"notion_version,omitempty"`
+	CreatedProperty string         `json:"created_property,omitempty"` // DEFAULT_CREATED_PROPERTY if empty
+	// Whether to retrieve the complete values of properties Notion truncates in query results
+	CompleteTruncated bool `json:"complete_truncated,omitempty"`
 }
 
-func exec(api notion.PageGetter, selector selection.PageSelector,
-	db dynamodbiface.DynamoDBAPI) (string, error) {
+type execOptions struct {
+	Format string // One of FormatUrl (the default), FormatMarkdown or FormatHtml
+	Sync   string // selection.SYNC_CREATED (the default) or selection.SYNC_EDITED
+	// How often to check every cached page against Notion; never if zero
+	ReconcileInterval time.Duration
+	Author            string // Only select pages created by this user, by name or ID
+	Comments          bool   // Follow the page with its comment thread
+	// Properties to record the selected page as surfaced with in Notion; not recorded if nil
+	Surface *selection.SurfaceOptions
+}
+
+func exec(ctx context.Context, api notion.PageGetter, selector selection.PageSelector,
+	db dynamodbiface.DynamoDBAPI, opts execOptions) (string, error) {
 	execStartTime := time.Now().Unix()
-	databaseId := api.GetDatabaseId()
 
-	// 1. Get cached pages from DynamoDb
-	dto, err := persistence.GetPages(db, &databaseId)
-	if dto == nil {
+	// Combine the pages cached in DynamoDb with any new ones from the Notion API
+	result := selection.RefreshPages(ctx, api, db, execStartTime, selection.RefreshOptions{
+		Mode:              opts.Sync,
+		ReconcileInterval: opts.ReconcileInterval,
+	})
+	for _, database := range result.Databases {
+		if database.Partial {
+			fmt.Fprintln(os.Stderr, "Interrupted while reading pages from Notion API, using partial results")
+		} else if database.Err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to read pages from Notion API:", database.Dto.DatabaseId)
+		}
+		if database.CacheErr != nil {
+			fmt.Fprintln(os.Stderr, "Unable to cache pages in DynamoDb:", database.Dto.DatabaseId, database.CacheErr)
+		}
+	}
+	if added, updated := result.Counts(); added > 0 || updated > 0 {
+		fmt.Fprintf(os.Stderr, "Cached %d new and %d updated pages\n", added, updated)
+	}
+	if removed := result.Pruned(); removed > 0 {
+		fmt.Fprintf(os.Stderr, "Removed %d pages that no longer exist in Notion\n", removed)
+	}
+
+	pages := result.Pages()
+	if len(pages) == 0 {
+		// Return any error, or none if there are simply no pages available
+		return "No records found", result.Err()
+	}
+
+	if opts.Author != "" {
+		authorIds, err := selection.AuthorIds(ctx, api, opts.Author)
+		if err != nil {
+			return "No records found", fmt.Errorf("Unable to find author: %w", err)
+		}
+		if len(authorIds) == 0 {
+			return "No records found", fmt.Errorf("Unknown author: %s", opts.Author)
+		}
+		selector = selection.ByAuthor(selector, authorIds...)
+	}
+
+	// Select across the pages of every database, skipping any that no longer exist
+	selectedPage, livePage, err := selection.SelectLivePage(ctx, api, selector, db, result)
+	if err != nil {
+		return "No records found", err
+	}
+	if selectedPage == nil {
+		return "No records found", nil
+	}
+	selection.ResolveUsers(ctx, api, selectedPage)
+	if selectedPage.CreatedBy != nil && selectedPage.CreatedBy.Name != "" && (opts.Format == "" || opts.Format == FormatUrl) {
+		fmt.Fprintln(os.Stderr, "Created by", selectedPage.CreatedBy.Name)
+	}
+
+	if opts.Surface != nil {
+		properties, err := selection.RecordSurfaced(ctx, api, selectedPage, livePage, time.Unix(execStartTime, 0), *opts.Surface)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Unable to read cached data from DynamoDb")
+			return "No records found", fmt.Errorf("Unable to record surfaced page in Notion: %w", err)
+		}
+		if opts.Surface.DryRun {
+			fmt.Fprintln(os.Stderr, "Would set", describeProperties(properties))
+		}
+	}
+
+	content, _ := api.(notion.ContentGetter)
+	output, err := formatPage(ctx, content, selectedPage, opts.Format)
+	if err != nil || !opts.Comments {
+		return output, err
+	}
+
+	comments, err := selection.PageComments(ctx, api, selectedPage.Id)
+	if err != nil {
+		// The page is still worth showing without its comments
+		fmt.Fprintln(os.Stderr, "Unable to read comments from Notion API:", err)
+		return output, nil
+	}
+	if opts.Format == FormatHtml {
+		return output + render.CommentsHTML(comments), nil
+	}
+	return strings.TrimRight(output, "\n") + "\n" + render.CommentsMarkdown(comments), nil
+}
+
+// Describe property values written back to a page, e.g. "Last Surfaced to 2022-07-15"
+func describeProperties(properties map[string]notion.PropertyValue) string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	descriptions := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if property := properties[name]; property.Date != nil {
+			value = property.Date.Start
+		} else if property.Number != nil {
+			value = strconv.FormatFloat(*property.Number, 'f', -1, 64)
+		}
+		descriptions[i] = fmt.Sprintf("%s to %s", name, value)
+	}
+	return strings.Join(descriptions, " and ")
+}
+
+// Post a comment onto a page, e.g. one picked on an earlier run
+func postReflection(ctx context.Context, api notion.PageGetter, pageId string, text string, w io.Writer) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("Unable to add comment: no text given")
+	}
+	comment, err := selection.Reflect(ctx, api, pageId, text)
+	if err != nil {
+		return fmt.Errorf("Unable to add comment: %w", err)
+	}
+	fmt.Fprintln(w, "Added comment", comment.Id)
+	return nil
+}
+
+// Create a page from the arguments of the capture command and print its URL.
+// The body is read from stdin if given as "-"
+func capturePage(ctx context.Context, api notion.PageGetter, db dynamodbiface.DynamoDBAPI,
+	args []string, stdin io.Reader, w io.Writer) error {
+	flags := flag.NewFlagSet("capture", flag.ContinueOnError)
+	flags.SetOutput(w)
+	tags := flags.String("tags", "", "Comma-separated tags for the page")
+	url := flags.String("url", "", "URL the page is about")
+	body := flags.String("body", "", "Text of the page; - to read it from standard input")
+	databaseId := flags.String("database", "", "Database to add the page to (default the first configured)")
+	tagsProperty := flags.String("tagsProperty", selection.DEFAULT_TAGS_PROPERTY, "Multi-select property to set the tags of")
+	urlProperty := flags.String("urlProperty", selection.DEFAULT_URL_PROPERTY, "URL property to set the URL of")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	capture := selection.Capture{
+		Title: strings.Join(flags.Args(), " "),
+		Url:   *url,
+		Body:  *body,
+	}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			capture.Tags = append(capture.Tags, tag)
 		}
-		// We could still read from the API, so set dto to a stub and keep going
-		dto = &persistence.NotionDTO{
-			DatabaseId: databaseId,
-			Pages:      []notion.Page{},
-			LastQuery:  execStartTime,
+	}
+	if capture.Body == "-" {
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("Unable to read page body: %w", err)
 		}
+		capture.Body = string(input)
 	}
 
-	// 2. Get additional pages from the Notion API
-	apiPages, err := api.GetPagesSinceTime(time.Unix(dto.LastQuery, 0))
+	page, err := selection.CapturePage(ctx, api, db, capture, selection.CaptureOptions{
+		DatabaseId:   *databaseId,
+		TagsProperty: *tagsProperty,
+		UrlProperty:  *urlProperty,
+	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Unable to read pages from Notion API")
-		// We could still read from the API, so set apiPages to a stub and keep going
-		apiPages = []notion.Page{}
+		return err
 	}
+	fmt.Fprintln(w, "Captured", page.Url)
+	return nil
+}
 
-	if len(dto.Pages) == 0 && len(apiPages) == 0 {
+// Pages shared with the integration anywhere in the workspace
+type workspaceSource interface {
+	notion.ContentGetter
+	SearchPages(context.Context) ([]notion.Page, error)
+}
+
+// Select a random page from everything shared with the integration, bypassing the cache
+func execWorkspace(ctx context.Context, api workspaceSource, selector selection.PageSelector,
+	opts execOptions) (string, error) {
+	pages, err := api.SearchPages(ctx)
+	if notion.IsPartial(err) {
+		fmt.Fprintln(os.Stderr, "Interrupted while searching the workspace, using partial results")
+	} else if err != nil {
+		return "No records found", err
+	}
+	if len(pages) == 0 {
+		return "No records found", nil
+	}
+
+	selectedPage := selector.SelectPage(pages)
+	return formatPage(ctx, api, selectedPage, opts.Format)
+}
+
+// Write the databases and pages shared with the integration, with their IDs and titles
+func discover(ctx context.Context, api notion.Searcher, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tID\tTITLE")
+	for _, object := range []string{notion.SEARCH_OBJECT_DATABASE, notion.SEARCH_OBJECT_PAGE} {
+		results, err := api.Search(ctx, notion.SearchQuery{
+			Object: object,
+			Sort:   notion.SortByLastEdited(notion.DESCENDING),
+		})
+		if err != nil && !notion.IsPartial(err) {
+			return fmt.Errorf("Unable to search the workspace: %w", err)
+		}
+		for _, result := range results {
+			title := result.Name()
+			if title == "" {
+				title = "Untitled"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", object, result.Id, title)
+		}
 		if err != nil {
-			return "No records found", err
-		} else {
-			// No error, but no pages available
-			return "No records found", nil
+			tw.Flush()
+			return fmt.Errorf("Interrupted while searching the workspace: %w", err)
 		}
 	}
+	return tw.Flush()
+}
 
-	// 3. Dedup and combine both sources of pages
-	pagesAdded := selection.UnionPages(dto, apiPages)
-	if pagesAdded {
-		dto.LastQuery = execStartTime
-		persistence.PutPages(db, dto)
+// Return the page's URL, or its content rendered in the given format
+func formatPage(ctx context.Context, content notion.ContentGetter, page *notion.Page, format string) (string, error) {
+	if format == "" || format == FormatUrl {
+		return page.Url, nil
+	}
+
+	if content == nil {
+		return page.Url, fmt.Errorf("Unable to retrieve page content")
+	}
+	blocks, err := content.GetPageContent(ctx, page.Id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to read page content from Notion API")
+		return page.Url, err
 	}
-	selectedPage := selector.SelectPage(dto.Pages)
 
-	return selectedPage.Url, nil
+	if format == FormatHtml {
+		return render.PageHTML(*page, blocks), nil
+	}
+	return render.PageMarkdown(*page, blocks), nil
 }
 
 // Code snippet via AWS docs:
@@ -97,6 +317,17 @@ func setApiSecrets(api *notion.ApiConfig, sess *session.Session) {
 		json.Unmarshal([]byte(*result.SecretString), &secret)
 		api.SecretToken = secret.Token
 		api.DatabaseId = secret.DatabaseId
+		api.DatabaseIds = secret.DatabaseIds
+		if api.Filter == nil {
+			api.Filter = secret.Filter
+		}
+		if api.CreatedProperty == "" {
+			api.CreatedProperty = secret.CreatedProperty
+		}
+		if api.Version == "" {
+			api.Version = secret.NotionVersion
+		}
+		api.CompleteTruncated = api.CompleteTruncated || secret.CompleteTruncated
 	} else {
 		panic("Unable to retrieve API secrets")
 	}
@@ -106,16 +337,79 @@ func main() {
 	// Parse command-line arguments and create a config object
 	url := flag.String("url", notion.API_URI, "Base URL of the Notion API")
 	databaseId := flag.String("databaseId", "", "Notion Database ID")
+	databaseIds := flag.String("databaseIds", "", "Comma-separated IDs of further Notion databases to pick pages from")
 	secret := flag.String("secret", "", "Notion API secret token")
 	pageSize := flag.Uint("pageSize", uint(notion.DEFAULT_PAGE_SIZE), "Pages to retrieve per Notion API call")
+	version := flag.String("notionVersion", "", "Notion-Version to send with API requests (default \""+notion.DEFAULT_NOTION_VERSION+"\")")
+	format := flag.String("format", FormatUrl, "Output format: url, markdown or html")
+	createdProperty := flag.String("createdProperty", "", "Date property used to find pages created since the last run (default \"Created\")")
+	completeTruncated := flag.Bool("completeTruncated", false, "Retrieve the complete values of properties Notion truncates to 25 items")
+	filter := flag.String("filter", "", "Notion filter object (JSON) applied to every database query")
+	maxAttempts := flag.Int("maxAttempts", notion.DEFAULT_MAX_ATTEMPTS, "Attempts per Notion API call before giving up")
+	rateLimit := flag.Float64("rateLimit", notion.DEFAULT_RATE_LIMIT, "Most Notion API calls per second; 0 for no limit")
+	rateBurst := flag.Int("rateBurst", notion.DEFAULT_RATE_BURST, "Notion API calls allowed at once before rate limiting")
+	faultRate := flag.Float64("faultRate", 0, "Fraction of Notion API calls to fail, for testing retries")
+	sync := flag.String("sync", selection.SYNC_CREATED, "Pages to update the cache with: created or edited since the last run")
+	reconcile := flag.Duration("reconcile", selection.DEFAULT_RECONCILE_INTERVAL, "How often to check every cached page still exists in Notion; 0 to never check")
+	author := flag.String("author", "", "Only pick pages created by this user, by name or ID")
+	comments := flag.Bool("comments", false, "Follow the page with its comment thread")
+	writeBack := flag.Bool("writeBack", false, "Record in Notion that the page was surfaced")
+	lastSurfaced := flag.String("lastSurfacedProperty", selection.DEFAULT_LAST_SURFACED_PROPERTY, "Date property -writeBack sets to today; empty to not set it")
+	timesSurfaced := flag.String("timesSurfacedProperty", selection.DEFAULT_TIMES_SURFACED_PROPERTY, "Number property -writeBack increments; empty to not set it")
+	dryRun := flag.Bool("dryRun", false, "With -writeBack, print the properties to set instead of updating the page")
+	workspace := flag.Bool("workspace", false, "Pick from every page shared with the integration instead of the configured databases")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [discover | reflect <page ID> [comment] | capture [capture flags] <title>]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "With no command, print a random page. discover lists the databases and pages shared with the integration.")
+		fmt.Fprintln(flag.CommandLine.Output(), "reflect adds a comment to a page, read from standard input if not given.")
+		fmt.Fprintln(flag.CommandLine.Output(), "capture adds a page to a database; run capture -h for its flags.")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+	command := flag.Arg(0)
+	if (command != "" && command != "discover" && command != "reflect" && command != "capture") ||
+		(command == "reflect" && flag.NArg() < 2) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *format != FormatUrl && *format != FormatMarkdown && *format != FormatHtml {
+		fmt.Fprintln(os.Stderr, "Unknown format:", *format)
+		os.Exit(1)
+	}
+	if *sync != selection.SYNC_CREATED && *sync != selection.SYNC_EDITED {
+		fmt.Fprintln(os.Stderr, "Unknown sync mode:", *sync)
+		os.Exit(1)
+	}
 
 	// Initialize interfaces
 	api := &notion.ApiConfig{
-		Url:         *url,
-		DatabaseId:  *databaseId,
-		SecretToken: *secret,
-		PageSize:    uint8(*pageSize),
+		Url:               *url,
+		DatabaseId:        *databaseId,
+		SecretToken:       *secret,
+		PageSize:          uint8(*pageSize),
+		Version:           *version,
+		DeadlineBuffer:    notion.DEFAULT_DEADLINE_BUFFER,
+		Retry:             notion.DefaultRetryPolicy(),
+		RateLimiter:       notion.NewRateLimiter(*rateLimit, *rateBurst),
+		Users:             notion.NewUserCache(),
+		DataSources:       notion.NewDataSourceCache(),
+		CreatedProperty:   *createdProperty,
+		CompleteTruncated: *completeTruncated,
+	}
+	api.Retry.MaxAttempts = *maxAttempts
+	if *databaseIds != "" {
+		api.DatabaseIds = strings.Split(*databaseIds, ",")
+	}
+	api.Middlewares = []notion.Middleware{notion.LoggingMiddleware()}
+	if *faultRate > 0 {
+		api.Middlewares = append(api.Middlewares, notion.FaultInjectionMiddleware(notion.FaultInjection{Rate: *faultRate}))
+	}
+	if *filter != "" {
+		api.Filter = &notion.Filter{}
+		if err := json.Unmarshal([]byte(*filter), api.Filter); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to parse filter:", err)
+			os.Exit(1)
+		}
 	}
 	selector := &selection.RandomPage{}
 	sess := session.Must(session.NewSession())
@@ -124,10 +418,57 @@ func main() {
 	}
 	db := dynamodb.New(sess)
 
-	output, err := exec(api, selector, db)
+	// Stop paging on Ctrl+C and pick from whatever has been retrieved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var output string
+	var err error
+	switch {
+	case command == "discover":
+		err = discover(ctx, api, os.Stdout)
+	case command == "reflect":
+		text := strings.Join(flag.Args()[2:], " ")
+		if text == "" {
+			var input []byte
+			input, err = io.ReadAll(os.Stdin)
+			text = string(input)
+		}
+		if err == nil {
+			err = postReflection(ctx, api, flag.Arg(1), text, os.Stdout)
+		}
+	case command == "capture":
+		err = capturePage(ctx, api, db, flag.Args()[1:], os.Stdin, os.Stdout)
+	case *workspace:
+		output, err = execWorkspace(ctx, api, selector, execOptions{Format: *format})
+	default:
+		if err := api.ValidateSchema(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to validate database schema:", err)
+		}
+		selection.LoadUsers(ctx, db, api.Users)
+		usersCached := api.Users.Len()
+		opts := execOptions{
+			Format:            *format,
+			Sync:              *sync,
+			ReconcileInterval: *reconcile,
+			Author:            *author,
+			Comments:          *comments,
+		}
+		if *writeBack {
+			opts.Surface = &selection.SurfaceOptions{
+				LastSurfaced:  *lastSurfaced,
+				TimesSurfaced: *timesSurfaced,
+				DryRun:        *dryRun,
+			}
+		}
+		output, err = exec(ctx, api, selector, db, opts)
+		selection.SaveUsers(ctx, db, api.Users, usersCached)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(2)
 	}
-	fmt.Fprintln(os.Stdout, output)
+	if output != "" {
+		fmt.Fprintln(os.Stdout, output)
+	}
 }
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	selection "github.com/jeffrosenberg/random-notion/internal/pageselection"
 	"github.com/jeffrosenberg/random-notion/pkg/notion"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +19,8 @@ import (
 
 type TestApiConfig struct {
 	mock.Mock
-	pages []notion.Page
+	pages      []notion.Page
+	partialErr error
 }
 
 type TestSelector struct {
@@ -41,6 +45,18 @@ func (api *TestApiConfig) GetPagesSinceTime(sinceTime time.Time) ([]notion.Page,
 	return api.pages, nil
 }
 
+func (api *TestApiConfig) GetPagesSinceTimeWithContext(ctx context.Context, sinceTime time.Time) ([]notion.Page, error) {
+	pages, err := api.GetPagesSinceTime(sinceTime)
+	if err == nil && api.partialErr != nil {
+		return pages, api.partialErr
+	}
+	return pages, err
+}
+
+func (api *TestApiConfig) GetPagesWithContext(ctx context.Context) ([]notion.Page, error) {
+	return api.GetPages()
+}
+
 func (api *TestApiConfig) GetPages() ([]notion.Page, error) {
 	api.MethodCalled("GetPages")
 
@@ -56,6 +72,68 @@ func (api *TestApiConfig) GetDatabaseId() string {
 	return mockDatabaseId
 }
 
+func (api *TestApiConfig) GetPageContent(ctx context.Context, pageId string) ([]notion.Block, error) {
+	api.MethodCalled("GetPageContent", pageId)
+	return []notion.Block{
+		{
+			Type: notion.BLOCK_PARAGRAPH,
+			Paragraph: &notion.TextBlock{
+				RichText: []notion.RichText{notion.NewRichText("Set some goals")},
+			},
+		},
+	}, nil
+}
+
+func (api *TestApiConfig) Search(ctx context.Context, query notion.SearchQuery) ([]notion.SearchResult, error) {
+	api.MethodCalled("Search", query.Object)
+	if query.Object == notion.SEARCH_OBJECT_DATABASE {
+		return []notion.SearchResult{
+			{
+				Object: "database",
+				Id:     mockDatabaseId,
+				Title:  []notion.RichText{notion.NewRichText("Reading List")},
+			},
+		}, nil
+	}
+	results := []notion.SearchResult{}
+	for _, page := range api.pages {
+		results = append(results, notion.SearchResult{Object: "page", Id: page.Id, Url: page.Url})
+	}
+	return results, nil
+}
+
+func (api *TestApiConfig) SearchPages(ctx context.Context) ([]notion.Page, error) {
+	api.MethodCalled("SearchPages")
+	return api.pages, api.partialErr
+}
+
+func (api *TestApiConfig) ListComments(ctx context.Context, blockId string) ([]notion.Comment, error) {
+	api.MethodCalled("ListComments", blockId)
+	return []notion.Comment{
+		{
+			Id:          "249911a-125e-803e-a164-001cf338b8ec",
+			CreatedTime: "2021-12-01T09:30:00.000Z",
+			CreatedBy:   &notion.User{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada Lovelace"},
+			RichText:    []notion.RichText{notion.NewRichText("Still relevant")},
+		},
+	}, nil
+}
+
+func (api *TestApiConfig) CreateComment(ctx context.Context, comment notion.NewComment) (*notion.Comment, error) {
+	api.MethodCalled("CreateComment", comment.PageId, notion.PlainText(comment.RichText))
+	return &notion.Comment{Id: "7a793800-3e55-4d5e-8009-2261de026179", DiscussionId: "f4be6752-a539-4da2-a8a9-c3953e13bc0b"}, nil
+}
+
+func (api *TestApiConfig) CreatePage(ctx context.Context, page notion.NewPage) (*notion.Page, error) {
+	api.MethodCalled("CreatePage", notion.PlainText(page.Properties["title"].Title), len(page.Children))
+	return &notion.Page{
+		Id:               "5331da24-6597-4f2d-a684-fd94a0f3278a",
+		Url:              "https://www.notion.so/Captured-5331da2465974f2da684fd94a0f3278a",
+		Properties:       page.Properties,
+		SourceDatabaseId: mockDatabaseId,
+	}, nil
+}
+
 func (selector *TestSelector) SelectPage(pages []notion.Page) *notion.Page {
 	selector.MethodCalled("SelectPage")
 	return &pages[0]
@@ -92,7 +170,7 @@ func TestHandleRequest_Success(t *testing.T) {
 	db.Mock.On("GetItem", mock.Anything)
 	db.Mock.On("PutItem", mock.Anything)
 
-	result, err := exec(api, selector, db)
+	result, err := exec(context.Background(), api, selector, db, execOptions{})
 	require.NoError(t, err)
 	assert.EqualValues(t, api.pages[0].Url, result)
 	api.AssertExpectations(t)
@@ -108,9 +186,177 @@ func TestHandleRequest_Error(t *testing.T) {
 	// selector.Mock.On("SelectPage") // PageSelector methods should NOT be called
 	db.Mock.On("GetItem", mock.Anything)
 
-	result, err := exec(api, selector, db)
+	result, err := exec(context.Background(), api, selector, db, execOptions{})
 	require.Error(t, err)
 	assert.EqualValues(t, "No records found", result)
 	api.AssertExpectations(t)
 	selector.AssertExpectations(t)
 }
+
+func TestHandleRequest_Markdown(t *testing.T) {
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{
+				Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				CreatedTime:    "2021-11-05T12:54:00.000Z",
+				LastEditedTime: "2021-11-05T12:55:00.000Z",
+				Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			},
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	api.Mock.On("GetPagesSinceTime", mock.Anything) // Set expectations for mock methods
+	api.Mock.On("GetDatabaseId")
+	api.Mock.On("GetPageContent", api.pages[0].Id)
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	result, err := exec(context.Background(), api, selector, db, execOptions{Format: FormatMarkdown})
+	require.NoError(t, err)
+	assert.EqualValues(t, "# Untitled\n\n<"+api.pages[0].Url+">\n\nSet some goals\n", result)
+	api.AssertExpectations(t)
+	selector.AssertExpectations(t)
+}
+
+func TestHandleRequest_Workspace(t *testing.T) {
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{
+				Id:  "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				Url: "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			},
+		},
+	}
+	selector := &TestSelector{}
+	api.Mock.On("SearchPages") // Set expectations for mock methods
+	selector.Mock.On("SelectPage")
+
+	result, err := execWorkspace(context.Background(), api, selector, execOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, api.pages[0].Url, result)
+	api.AssertExpectations(t)
+	selector.AssertExpectations(t)
+}
+
+func TestDiscover(t *testing.T) {
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{Id: "3350ba04-48b1-43e3-8726-1b1e9828b2b3"},
+		},
+	}
+	api.Mock.On("Search", mock.Anything) // Set expectations for mock methods
+
+	var output strings.Builder
+	err := discover(context.Background(), api, &output)
+	require.NoError(t, err)
+	expected := "TYPE      ID                                    TITLE\n" +
+		"database  " + mockDatabaseId + "      Reading List\n" +
+		"page      3350ba04-48b1-43e3-8726-1b1e9828b2b3  Untitled\n"
+	assert.Equal(t, expected, output.String())
+	api.AssertNumberOfCalls(t, "Search", 2)
+}
+
+func TestHandleRequest_Comments(t *testing.T) {
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{
+				Id:  "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				Url: "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			},
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	api.Mock.On("GetPagesSinceTime", mock.Anything) // Set expectations for mock methods
+	api.Mock.On("GetDatabaseId")
+	api.Mock.On("ListComments", api.pages[0].Id)
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	result, err := exec(context.Background(), api, selector, db, execOptions{Comments: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, api.pages[0].Url+"\n\n## Comments\n\n- **Ada Lovelace** (2021-12-01): Still relevant\n", result)
+	api.AssertExpectations(t)
+}
+
+func TestReflect(t *testing.T) {
+	api := &TestApiConfig{}
+	api.Mock.On("CreateComment", "3350ba04-48b1-43e3-8726-1b1e9828b2b3", "Worth revisiting") // Set expectations for mock methods
+
+	var output strings.Builder
+	err := postReflection(context.Background(), api, "3350ba04-48b1-43e3-8726-1b1e9828b2b3", "Worth revisiting", &output)
+	require.NoError(t, err)
+	assert.Equal(t, "Added comment 7a793800-3e55-4d5e-8009-2261de026179\n", output.String())
+	api.AssertExpectations(t)
+
+	err = postReflection(context.Background(), api, "3350ba04-48b1-43e3-8726-1b1e9828b2b3", "  \n", &output)
+	assert.EqualError(t, err, "Unable to add comment: no text given")
+}
+
+func TestHandleRequest_WriteBackDryRun(t *testing.T) {
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{
+				Id:  "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				Url: "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+				Properties: map[string]notion.PropertyValue{
+					"Last Surfaced": {Type: notion.PROPERTY_DATE},
+				},
+			},
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	api.Mock.On("GetPagesSinceTime", mock.Anything) // Set expectations for mock methods
+	api.Mock.On("GetDatabaseId")
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	result, err := exec(context.Background(), api, selector, db, execOptions{
+		Surface: &selection.SurfaceOptions{LastSurfaced: "Last Surfaced", DryRun: true},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, api.pages[0].Url, result)
+
+	_, err = exec(context.Background(), api, selector, db, execOptions{
+		Surface: &selection.SurfaceOptions{TimesSurfaced: "Times Surfaced", DryRun: true},
+	})
+	assert.ErrorIs(t, err, selection.ErrMissingProperty)
+	assert.EqualError(t, err, `Unable to record surfaced page in Notion: Missing property: the database has no number property named "Times Surfaced"`)
+}
+
+func TestDescribeProperties(t *testing.T) {
+	count := float64(3)
+	description := describeProperties(map[string]notion.PropertyValue{
+		"Times Surfaced": {Number: &count},
+		"Last Surfaced":  {Date: &notion.DateValue{Start: "2022-07-15"}},
+	})
+	assert.Equal(t, "Last Surfaced to 2022-07-15 and Times Surfaced to 3", description)
+}
+
+func TestCapture(t *testing.T) {
+	api := &TestApiConfig{}
+	db := &TestDynamoDb{}
+	api.Mock.On("GetDatabaseId") // Set expectations for mock methods
+	api.Mock.On("CreatePage", "Gödel, Escher, Bach", 2)
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	var output strings.Builder
+	err := capturePage(context.Background(), api, db,
+		[]string{"-tags", "books, maths", "-url", "https://example.com/geb", "-body", "-", "Gödel,", "Escher,", "Bach"},
+		strings.NewReader("Strange loops.\n\nRecursion everywhere.\n"), &output)
+	require.NoError(t, err)
+	assert.Equal(t, "Captured https://www.notion.so/Captured-5331da2465974f2da684fd94a0f3278a\n", output.String())
+	api.AssertExpectations(t)
+	db.AssertExpectations(t)
+
+	// The new page is cached straight away
+	putItem := db.Calls[1].Arguments[0].(*dynamodb.PutItemInput)
+	assert.Equal(t, mockDatabaseId, *putItem.Item["database_id"].S)
+	assert.Len(t, putItem.Item["pages"].L, 1)
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
 
 	selection "github.com/jeffrosenberg/random-notion/internal/pageselection"
 	"github.com/jeffrosenberg/random-notion/internal/persistence"
 	"github.com/jeffrosenberg/random-notion/pkg/logging"
 	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/jeffrosenberg/random-notion/pkg/render"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -23,25 +30,67 @@ import (
 )
 
 const (
-	SecretName   = "random-notion/notion-api"
-	SecretRegion = "us-west-2"
+	SecretName    = "random-notion/notion-api"
+	SecretRegion  = "us-west-2"
+	SchemaTimeout = 3 * time.Second
+	// Header callers of /reflect send AwsSecret.ReflectSecret in, unless they send a workspace key
+	ReflectSecretHeader = "X-Reflect-Secret"
 )
 
 type HandlerFn func(context.Context, events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error)
 
 type AwsSecret struct {
-	Token      string `json:"token"`
-	DatabaseId string `json:"database_id"`
+	Token           string         `json:"token"`
+	DatabaseId      string         `json:"database_id"`
+	DatabaseIds     []string       `json:"database_ids,omitempty"` // Further databases to pick pages from
+	Filter          *notion.Filter `json:"filter,omitempty"`
+	NotionVersion   string         `json:"notion_version,omitempty"`
+	CreatedProperty string         `json:"created_property,omitempty"` // DEFAULT_CREATED_PROPERTY if empty
+	SyncMode        string         `json:"sync_mode,omitempty"`        // selection.SYNC_CREATED if empty
+	// Whether to retrieve the complete values of properties Notion truncates in query results
+	CompleteTruncated bool `json:"complete_truncated,omitempty"`
+	// How often to check every cached page against Notion, e.g. "12h"; DEFAULT_RECONCILE_INTERVAL if empty
+	ReconcileInterval string `json:"reconcile_interval,omitempty"`
+	// Whether to record each surfaced page in Notion, and the properties to do so with,
+	// DEFAULT_LAST_SURFACED_PROPERTY and DEFAULT_TIMES_SURFACED_PROPERTY if empty
+	WriteBack             bool   `json:"write_back,omitempty"`
+	WriteBackDryRun       bool   `json:"write_back_dry_run,omitempty"`
+	LastSurfacedProperty  string `json:"last_surfaced_property,omitempty"`
+	TimesSurfacedProperty string `json:"times_surfaced_property,omitempty"`
+	// Credentials of the public integration, to let other workspaces authorize it through OAuth,
+	// and the base64 key of TOKEN_KEY_LENGTH bytes their access tokens are encrypted with
+	OAuthClientId     string `json:"oauth_client_id,omitempty"`
+	OAuthClientSecret string `json:"oauth_client_secret,omitempty"`
+	OAuthRedirectUri  string `json:"oauth_redirect_uri,omitempty"`
+	TokenKey          string `json:"token_key,omitempty"`
+	// Shared secret callers of /reflect must send in ReflectSecretHeader to comment on pages of
+	// the default workspace; only callers with a workspace key can comment if empty
+	ReflectSecret string `json:"reflect_secret,omitempty"`
+}
+
+type handlerOptions struct {
+	Refresh selection.RefreshOptions
+	Users   *notion.UserCache         // Persisted to DynamoDb whenever more users are resolved; not persisted if nil
+	Surface *selection.SurfaceOptions // Written back to each selected page; not written back if nil
+	OAuth   *oauthOptions             // Serves workspaces that authorized the integration; only the default if nil
+	// Required of callers of /reflect without a workspace key; they're refused if empty
+	ReflectSecret string
 }
 
 // Closure for injection of notion.PageGetter interface
 func handleRequestForApi(api notion.PageGetter, selector selection.PageSelector,
 	db dynamodbiface.DynamoDBAPI) HandlerFn {
+	return handleRequestWithOptions(api, selector, db, handlerOptions{})
+}
+
+func handleRequestWithOptions(defaultApi notion.PageGetter, selector selection.PageSelector,
+	db dynamodbiface.DynamoDBAPI, opts handlerOptions) HandlerFn {
 	return func(ctx context.Context, e events.APIGatewayV2HTTPRequest) (event events.APIGatewayV2HTTPResponse, err error) {
-		var dto *persistence.NotionDTO
-		var apiPages []notion.Page
+		var pages selection.RefreshResult
 		execStartTime := time.Now().Unix()
-		databaseId := api.GetDatabaseId()
+		api := defaultApi
+		users := opts.Users
+		hasWorkspaceKey := false
 
 		logger := logging.GetLoggerWithContext(ctx)
 		logger.Trace().
@@ -49,7 +98,52 @@ func handleRequestForApi(api notion.PageGetter, selector selection.PageSelector,
 			Str("log_level", logger.GetLevel().String()).
 			Msg("Random Notion handler triggered")
 
-		if databaseId == "" {
+		// Serve callers from workspaces that authorized the integration with their own token
+		if opts.OAuth != nil {
+			switch e.RawPath {
+			case "/oauth/authorize":
+				return authorizeResponse(ctx, *opts.OAuth), nil
+			case "/oauth/callback":
+				return callbackResponse(ctx, e, db, *opts.OAuth), nil
+			}
+			workspace, err := workspaceApi(ctx, e, db, *opts.OAuth)
+			if err != nil {
+				logger.Warn().Err(err).Msg("Unable to identify workspace")
+				return events.APIGatewayV2HTTPResponse{StatusCode: 401, Body: "Invalid workspace key"}, nil
+			}
+			if workspace != nil {
+				api = workspace
+				hasWorkspaceKey = true
+				users = nil // Only users of the default workspace are persisted
+			} else if len(selection.DatabaseIds(api)) == 0 {
+				return events.APIGatewayV2HTTPResponse{
+					StatusCode: 401,
+					Body:       "No workspace key given; authorize the integration at /oauth/authorize",
+				}, nil
+			}
+		}
+		databaseIds := selection.DatabaseIds(api)
+		usersCached := users.Len()
+
+		if e.RequestContext.HTTP.Method == http.MethodPost && e.RawPath == "/reflect" {
+			// Comments are posted with the workspace's token, so only its owner may post them
+			if !hasWorkspaceKey && !validSecret(header(e, ReflectSecretHeader), opts.ReflectSecret) {
+				logger.Warn().Msg("Refused comment without a workspace key or reflect secret")
+				return events.APIGatewayV2HTTPResponse{StatusCode: 401, Body: "A workspace key or reflect secret is required"}, nil
+			}
+			return reflectResponse(ctx, api, db, e.Body), nil
+		}
+
+		switch format := e.QueryStringParameters["format"]; format {
+		case "", "json", "markdown", "html":
+		default:
+			return events.APIGatewayV2HTTPResponse{
+				StatusCode: 400,
+				Body:       fmt.Sprintf("Unknown format: %s", format),
+			}, nil
+		}
+
+		if len(databaseIds) == 0 {
 			logger.Warn().Msg("No DatabaseId provided")
 			return events.APIGatewayV2HTTPResponse{
 				StatusCode: 400,
@@ -63,11 +157,10 @@ func handleRequestForApi(api notion.PageGetter, selector selection.PageSelector,
 				err := fmt.Errorf("%v", r)
 				logger.
 					Err(err).
-					Interface("dto", dto).
-					Interface("api_pages", apiPages).
+					Interface("pages", pages).
 					Interface("pagegetter", api).
 					Interface("pageselector", selector).
-					Str("database_id", databaseId).
+					Strs("database_ids", databaseIds).
 					Msg("Recovered from a panic")
 				event = events.APIGatewayV2HTTPResponse{
 					StatusCode: 500,
@@ -76,42 +169,15 @@ func handleRequestForApi(api notion.PageGetter, selector selection.PageSelector,
 			}
 		}()
 
-		// 1. Get cached pages from DynamoDb
-		logger.Trace().Msg("Getting pages from DynamoDb")
-		dto, err = persistence.GetPages(db, &databaseId)
-		if dto == nil {
-			if err != nil {
-				logger.Err(err).Msg("Unable to read cached data from DynamoDb")
-			}
-			// We could still read from the API, so set dto to a stub and keep going
-			dto = &persistence.NotionDTO{
-				DatabaseId: databaseId,
-				Pages:      []notion.Page{},
-				LastQuery:  execStartTime,
-			}
-		}
-
-		// 2. Get additional pages from the Notion API
-		logger.Trace().Msg("Getting pages from Notion API")
-		apiPages, err = api.GetPagesSinceTime(time.Unix(dto.LastQuery, 0))
-		if err != nil {
-			logger.Err(err).Msg("Unable to read pages from Notion API")
-			// We could still read from the API, so set apiPages to a stub and keep going
-			apiPages = []notion.Page{}
-		}
-
-		logger.Debug().
-			Int("pages_cached", len(dto.Pages)).
-			Int("pages_api", len(apiPages)).
-			Msg("Retrieved pages")
+		// Combine the pages cached in DynamoDb with any new ones from the Notion API
+		pages = selection.RefreshPages(ctx, api, db, execStartTime, opts.Refresh)
+		allPages := pages.Pages()
+		partial := pages.Partial()
 
-		if len(dto.Pages) == 0 && len(apiPages) == 0 {
-			if err != nil {
+		if len(allPages) == 0 {
+			if err := pages.Err(); err != nil {
 				logger.Err(err).Send()
-				return events.APIGatewayV2HTTPResponse{
-					StatusCode: 400,
-					Body:       err.Error(),
-				}, err
+				return errorResponse(err), nil
 			} else {
 				// No error, but no pages available: Return 204 No Content
 				// This is a tough scenario to pick a status code for,
@@ -123,26 +189,241 @@ func handleRequestForApi(api notion.PageGetter, selector selection.PageSelector,
 			}
 		}
 
-		// 3. Dedup and combine both sources of pages
-		logger.Trace().Msg("Unioning pages")
-		pagesAdded := selection.UnionPages(dto, apiPages)
-		if pagesAdded {
-			dto.LastQuery = execStartTime
-			persistence.PutPages(db, dto)
+		// Optionally only select pages created by the given user, by name or ID
+		pageSelector := selector
+		if author := e.QueryStringParameters["author"]; author != "" {
+			authorIds, err := selection.AuthorIds(ctx, api, author)
+			if err != nil {
+				logger.Err(err).Msg("Unable to find author")
+				return errorResponse(err), nil
+			}
+			if len(authorIds) == 0 {
+				return events.APIGatewayV2HTTPResponse{
+					StatusCode: 404,
+					Body:       fmt.Sprintf("Unknown author: %s", author),
+				}, nil
+			}
+			pageSelector = selection.ByAuthor(selector, authorIds...)
+		}
+
+		// Select across the pages of every database, skipping any that no longer exist
+		selectedPage, livePage, err := selection.SelectLivePage(ctx, api, pageSelector, db, pages)
+		if err != nil {
+			logger.Err(err).Send()
+			return errorResponse(err), nil
+		}
+		if selectedPage == nil {
+			logger.Warn().Msg("No records found")
+			return events.APIGatewayV2HTTPResponse{
+				StatusCode: 204,
+			}, nil
+		}
+		selection.ResolveUsers(ctx, api, selectedPage)
+		selection.SaveUsers(ctx, db, users, usersCached)
+
+		// Optionally include the discussion on the page
+		var comments []notion.Comment
+		if withComments, _ := strconv.ParseBool(e.QueryStringParameters["comments"]); withComments {
+			comments, err = selection.PageComments(ctx, api, selectedPage.Id)
+			if err != nil {
+				// The page is still worth showing without its comments
+				logger.Warn().Err(err).Str("page_id", selectedPage.Id).Msg("Unable to read comments from Notion API")
+				comments = nil
+			}
 		}
-		selectedPage := selector.SelectPage(dto.Pages)
+
+		// Optionally record in Notion that the page was surfaced
+		if opts.Surface != nil {
+			_, err := selection.RecordSurfaced(ctx, api, selectedPage, livePage, time.Unix(execStartTime, 0), *opts.Surface)
+			if err != nil {
+				// The page is still worth showing, but the write-back needs fixing
+				logger.Err(err).Str("page_id", selectedPage.Id).Msg("Unable to record surfaced page in Notion")
+			}
+		}
+
+		if format := e.QueryStringParameters["format"]; format == "markdown" || format == "html" {
+			return contentResponse(ctx, api, selectedPage, comments, format), nil
+		}
+
+		body := fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\"", selectedPage.Id, selectedPage.Url)
+		if selectedPage.CreatedBy != nil && selectedPage.CreatedBy.Name != "" {
+			author, _ := json.Marshal(selectedPage.CreatedBy.Name)
+			body += fmt.Sprintf(", \"author\":%s", author)
+		}
+		if comments != nil {
+			thread, _ := json.Marshal(commentBodies(comments))
+			body += fmt.Sprintf(", \"comments\":%s", thread)
+		}
+		if partial {
+			body += ", \"partial\":true"
+		}
+		body += "}"
 
 		return events.APIGatewayV2HTTPResponse{
 			StatusCode: 200,
-			Body:       fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\"}", selectedPage.Id, selectedPage.Url),
+			Body:       body,
 			Headers:    map[string]string{"Content-Type": "application/json"},
 		}, nil
 	}
 }
 
+type commentBody struct {
+	Author      string `json:"author,omitempty"`
+	Text        string `json:"text"`
+	CreatedTime string `json:"created_time"`
+}
+
+func commentBodies(comments []notion.Comment) []commentBody {
+	bodies := make([]commentBody, len(comments))
+	for i, comment := range comments {
+		bodies[i] = commentBody{Text: comment.Text(), CreatedTime: comment.CreatedTime}
+		if comment.CreatedBy != nil {
+			bodies[i].Author = comment.CreatedBy.Name
+		}
+	}
+	return bodies
+}
+
+type reflectRequest struct {
+	PageId string `json:"page_id"`
+	Text   string `json:"text"`
+}
+
+// Post a comment onto a page, given a reflectRequest as JSON
+func reflectResponse(ctx context.Context, api notion.PageGetter, db dynamodbiface.DynamoDBAPI,
+	requestBody string) events.APIGatewayV2HTTPResponse {
+	logger := logging.GetLoggerWithContext(ctx)
+	var request reflectRequest
+	if err := json.Unmarshal([]byte(requestBody), &request); err != nil || request.PageId == "" || request.Text == "" {
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 400,
+			Body:       "Expected a JSON body with page_id and text",
+		}
+	}
+
+	err := selection.CheckCachedPage(api, db, request.PageId)
+	var comment *notion.Comment
+	if err == nil {
+		comment, err = selection.Reflect(ctx, api, request.PageId, request.Text)
+	}
+	if errors.Is(err, selection.ErrCommentsUnsupported) {
+		logger.Error().Msg("PageGetter can't create comments")
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 501,
+			Body:       "Comments not available",
+		}
+	} else if errors.Is(err, selection.ErrUnknownPage) {
+		logger.Warn().Str("page_id", request.PageId).Msg("Refused comment on a page that isn't cached")
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 404,
+			Body:       "Page not found in the configured databases",
+		}
+	} else if err != nil {
+		logger.Err(err).Str("page_id", request.PageId).Msg("Unable to create comment")
+		return errorResponse(err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"id": comment.Id, "discussion_id": comment.DiscussionId})
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: 201,
+		Body:       string(body),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// Return whether a secret was configured and sent, comparing in constant time
+func validSecret(sent string, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) == 1
+}
+
+// Respond with the page's content and any comments rendered as Markdown or HTML
+func contentResponse(ctx context.Context, api notion.PageGetter, page *notion.Page,
+	comments []notion.Comment, format string) events.APIGatewayV2HTTPResponse {
+	logger := logging.GetLoggerWithContext(ctx)
+	content, ok := api.(notion.ContentGetter)
+	if !ok {
+		logger.Error().Msg("PageGetter can't retrieve page content")
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 501,
+			Body:       "Page content not available",
+		}
+	}
+
+	blocks, err := content.GetPageContent(ctx, page.Id)
+	if err != nil {
+		logger.Err(err).Str("page_id", page.Id).Msg("Unable to read page content from Notion API")
+		return errorResponse(err)
+	}
+
+	if format == "html" {
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 200,
+			Body:       render.PageHTML(*page, blocks) + render.CommentsHTML(comments),
+			Headers:    map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		}
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: 200,
+		Body:       render.PageMarkdown(*page, blocks) + render.CommentsMarkdown(comments),
+		Headers:    map[string]string{"Content-Type": "text/markdown; charset=utf-8"},
+	}
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// Map an error reading from Notion to the response our caller should see
+func errorResponse(err error) events.APIGatewayV2HTTPResponse {
+	status := http.StatusBadGateway
+	headers := map[string]string{"Content-Type": "application/json"}
+	body := errorBody{Error: err.Error()}
+
+	var apiErr *notion.APIError
+	if errors.As(err, &apiErr) {
+		body.Code = apiErr.Code
+		switch apiErr.Code {
+		case notion.CODE_UNAUTHORIZED:
+			status = http.StatusUnauthorized
+		case notion.CODE_RESTRICTED_RESOURCE:
+			status = http.StatusForbidden
+		case notion.CODE_OBJECT_NOT_FOUND:
+			status = http.StatusNotFound
+		case notion.CODE_CONFLICT_ERROR:
+			status = http.StatusConflict
+		case notion.CODE_RATE_LIMITED:
+			status = http.StatusTooManyRequests
+		case notion.CODE_INVALID_JSON, notion.CODE_INVALID_REQUEST_URL, notion.CODE_INVALID_REQUEST,
+			notion.CODE_VALIDATION_ERROR, notion.CODE_MISSING_VERSION:
+			// Our request to Notion was malformed, which isn't the caller's fault
+			status = http.StatusInternalServerError
+		case notion.CODE_SERVICE_UNAVAILABLE, notion.CODE_DATABASE_CONNECTION_UNAVAILABLE:
+			status = http.StatusServiceUnavailable
+		case notion.CODE_GATEWAY_TIMEOUT:
+			status = http.StatusGatewayTimeout
+		default:
+			if apiErr.Status == http.StatusTooManyRequests || apiErr.Status == http.StatusServiceUnavailable {
+				status = apiErr.Status
+			}
+		}
+		if apiErr.RetryAfter > 0 {
+			headers["Retry-After"] = strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds())))
+		}
+	}
+
+	payload, _ := json.Marshal(body)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: status,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+}
+
 // Code snippet via AWS docs:
 // https://docs.aws.amazon.com/sdk-for-go/v1/developer-guide/setting-up.html
-func setApiSecrets(api *notion.ApiConfig, sess *session.Session) {
+// Configure api from the secret, returning how the secret asks for the cache to be refreshed
+func setApiSecrets(api *notion.ApiConfig, sess *session.Session) handlerOptions {
 	//Create a Secrets Manager client
 	svc := secretsmanager.New(sess, aws.NewConfig().WithRegion(SecretRegion))
 	input := &secretsmanager.GetSecretValueInput{
@@ -189,18 +470,90 @@ func setApiSecrets(api *notion.ApiConfig, sess *session.Session) {
 		json.Unmarshal([]byte(*result.SecretString), &secret)
 		api.SecretToken = secret.Token
 		api.DatabaseId = secret.DatabaseId
+		api.DatabaseIds = secret.DatabaseIds
+		api.Filter = secret.Filter
+		api.CreatedProperty = secret.CreatedProperty
+		api.CompleteTruncated = secret.CompleteTruncated
+		if secret.NotionVersion != "" {
+			api.Version = secret.NotionVersion
+		}
+		opts := handlerOptions{
+			Refresh: selection.RefreshOptions{
+				Mode:              secret.SyncMode,
+				ReconcileInterval: selection.DEFAULT_RECONCILE_INTERVAL,
+			},
+		}
+		if secret.ReconcileInterval != "" {
+			interval, err := time.ParseDuration(secret.ReconcileInterval)
+			if err != nil {
+				logging.GetLogger().Err(err).Msg("Unable to parse reconcile interval, using the default")
+			} else {
+				opts.Refresh.ReconcileInterval = interval
+			}
+		}
+		if secret.WriteBack {
+			surface := selection.DefaultSurfaceOptions()
+			if secret.LastSurfacedProperty != "" {
+				surface.LastSurfaced = secret.LastSurfacedProperty
+			}
+			if secret.TimesSurfacedProperty != "" {
+				surface.TimesSurfaced = secret.TimesSurfacedProperty
+			}
+			surface.DryRun = secret.WriteBackDryRun
+			opts.Surface = &surface
+		}
+		opts.ReflectSecret = secret.ReflectSecret
+		if secret.OAuthClientId != "" {
+			oauth, err := newOAuthOptions(api, secret)
+			if err != nil {
+				logging.GetLogger().Err(err).Msg("Unable to configure OAuth, serving the default workspace only")
+			} else {
+				opts.OAuth = oauth
+			}
+		}
+		return opts
 	} else {
 		panic("Unable to retrieve API secrets")
 	}
 }
 
+func newOAuthOptions(api *notion.ApiConfig, secret AwsSecret) (*oauthOptions, error) {
+	key, err := base64.StdEncoding.DecodeString(secret.TokenKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode token key: %w", err)
+	}
+	tokens, err := persistence.NewTokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &oauthOptions{
+		Config: notion.OAuthConfig{
+			ClientId:     secret.OAuthClientId,
+			ClientSecret: secret.OAuthClientSecret,
+			RedirectUri:  secret.OAuthRedirectUri,
+		},
+		Tokens: tokens,
+		Api:    api,
+	}, nil
+}
+
 func main() {
 	// Initialize interfaces
 	api := notion.NewApiConfig()
+	api.Middlewares = []notion.Middleware{notion.LoggingMiddleware()}
 	selector := &selection.RandomPage{}
 	sess := session.Must(session.NewSession())
-	setApiSecrets(api, sess)
+	opts := setApiSecrets(api, sess)
+	opts.Users = api.Users
 	db := dynamodb.New(sess)
+	selection.LoadUsers(context.Background(), db, api.Users)
+
+	// Check the database schema once per cold start
+	ctx, cancel := context.WithTimeout(context.Background(), SchemaTimeout)
+	if err := api.ValidateSchema(ctx); err != nil {
+		logging.GetLogger().Err(err).Msg("Unable to validate database schema")
+	}
+	cancel()
 
-	lambda.Start(handleRequestForApi(api, selector, db))
+	lambda.Start(handleRequestWithOptions(api, selector, db, opts))
 }
@@ -3,14 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	selection "github.com/jeffrosenberg/random-notion/internal/pageselection"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
 	"github.com/jeffrosenberg/random-notion/pkg/notion"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,6 +25,8 @@ type TestApiConfig struct {
 	mock.Mock
 	pages      []notion.Page
 	databaseId *string
+	partialErr error
+	err        error
 }
 
 type TestSelector struct {
@@ -48,6 +54,9 @@ const nextCursor string = "5331da24-6597-4f2d-a684-fd94a0f3278a"
 func (api *TestApiConfig) GetPagesSinceTime(sinceTime time.Time) ([]notion.Page, error) {
 	api.MethodCalled("GetPagesSinceTime", sinceTime.Format(notion.ISO_TIME))
 
+	if api.err != nil {
+		return nil, api.err
+	}
 	if api.pages == nil {
 		return nil, fmt.Errorf("No pages found")
 	}
@@ -68,6 +77,18 @@ func (api *TestApiConfig) GetPagesSinceTime(sinceTime time.Time) ([]notion.Page,
 	return api.pages[index:], nil
 }
 
+func (api *TestApiConfig) GetPagesSinceTimeWithContext(ctx context.Context, sinceTime time.Time) ([]notion.Page, error) {
+	pages, err := api.GetPagesSinceTime(sinceTime)
+	if err == nil && api.partialErr != nil {
+		return pages, api.partialErr
+	}
+	return pages, err
+}
+
+func (api *TestApiConfig) GetPagesWithContext(ctx context.Context) ([]notion.Page, error) {
+	return api.GetPages()
+}
+
 func (api *TestApiConfig) GetPages() ([]notion.Page, error) {
 	api.MethodCalled("GetPages")
 
@@ -86,6 +107,19 @@ func (api *TestApiConfig) GetDatabaseId() string {
 	return mockDatabaseId
 }
 
+func (api *TestApiConfig) GetPageContent(ctx context.Context, pageId string) ([]notion.Block, error) {
+	api.MethodCalled("GetPageContent", pageId)
+	return []notion.Block{
+		{
+			Type: notion.BLOCK_TO_DO,
+			ToDo: &notion.ToDoBlock{
+				RichText: []notion.RichText{notion.NewRichText("Set some goals")},
+				Checked:  true,
+			},
+		},
+	}, nil
+}
+
 func (selector *TestSelector) SelectPage(pages []notion.Page) *notion.Page {
 	selector.MethodCalled("SelectPage")
 	if len(pages) == 0 {
@@ -406,10 +440,68 @@ func TestHandleNotionApiError(t *testing.T) {
 	result, err := handler(context.Background(), event)
 
 	// Assert
-	require.Error(t, err)
+	require.NoError(t, err) // Errors are reported through the response status
 	expected := events.APIGatewayV2HTTPResponse{
-		StatusCode: 400,
-		Body:       "No pages found",
+		StatusCode: 502,
+		Body:       `{"error":"No pages found"}`,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+	assert.EqualValues(t, expected, result)
+	api.AssertExpectations(t)
+	selector.AssertExpectations(t)
+}
+
+func TestMapNotionApiErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{&notion.APIError{Status: 401, Code: notion.CODE_UNAUTHORIZED}, 401},
+		{&notion.APIError{Status: 403, Code: notion.CODE_RESTRICTED_RESOURCE}, 403},
+		{&notion.APIError{Status: 404, Code: notion.CODE_OBJECT_NOT_FOUND}, 404},
+		{&notion.APIError{Status: 429, Code: notion.CODE_RATE_LIMITED}, 429},
+		{&notion.APIError{Status: 400, Code: notion.CODE_VALIDATION_ERROR}, 500},
+		{&notion.APIError{Status: 503, Code: notion.CODE_SERVICE_UNAVAILABLE}, 503},
+		{&notion.APIError{Status: 504, Code: notion.CODE_GATEWAY_TIMEOUT}, 504},
+		{&notion.APIError{Status: 500, Code: notion.CODE_INTERNAL_SERVER_ERROR}, 502},
+		{fmt.Errorf("Unable to retrieve response: %w", &notion.APIError{Status: 503}), 503},
+		{fmt.Errorf("Unable to retrieve response"), 502},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, errorResponse(test.err).StatusCode, test.err.Error())
+	}
+}
+
+func TestHandleNotionApiRateLimit(t *testing.T) {
+	// Arrange
+	api := &TestApiConfig{
+		err: &notion.APIError{
+			Status:     429,
+			Code:       notion.CODE_RATE_LIMITED,
+			Message:    "You have been rate limited. Please try again in a few minutes.",
+			RetryAfter: 1500 * time.Millisecond,
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	event := events.APIGatewayV2HTTPRequest{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	db.Mock.On("GetItem", mock.Anything)
+
+	// Act
+	handler := handleRequestForApi(api, selector, db)
+	result, err := handler(context.Background(), event)
+
+	// Assert
+	require.NoError(t, err)
+	expected := events.APIGatewayV2HTTPResponse{
+		StatusCode: 429,
+		Body:       `{"error":"Received invalid status: 429 rate_limited: You have been rate limited. Please try again in a few minutes.","code":"rate_limited"}`,
+		Headers:    map[string]string{"Content-Type": "application/json", "Retry-After": "2"},
 	}
 	assert.EqualValues(t, expected, result)
 	api.AssertExpectations(t)
@@ -441,3 +533,410 @@ func TestErrorWhenNoDatabaseId(t *testing.T) {
 	api.AssertExpectations(t)
 	selector.AssertExpectations(t)
 }
+
+func TestReturnPartialResultsFromNotionApi(t *testing.T) {
+	// Arrange
+	api := &TestApiConfig{
+		pages: []notion.Page{
+			{
+				Id:             mockPageId,
+				CreatedTime:    mockTime,
+				LastEditedTime: mockTime,
+				Url:            mockPageUrl,
+			},
+		},
+		partialErr: &notion.PartialResultError{
+			Cursor: nextCursor,
+			Pages:  1,
+			Err:    context.DeadlineExceeded,
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	event := events.APIGatewayV2HTTPRequest{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	// Act
+	handler := handleRequestForApi(api, selector, db)
+	result, err := handler(context.Background(), event)
+
+	// Assert
+	require.NoError(t, err)
+	expected := events.APIGatewayV2HTTPResponse{
+		StatusCode: 200,
+		Body:       fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\", \"partial\":true}", mockPageId, mockPageUrl),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+	assert.EqualValues(t, expected, result)
+	api.AssertExpectations(t)
+	selector.AssertExpectations(t)
+	db.AssertExpectations(t)
+}
+
+func TestReturnPageContent(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+		body        string
+	}{
+		{
+			"markdown",
+			"text/markdown; charset=utf-8",
+			"# Untitled\n\n<" + mockPageUrl + ">\n\n- [x] Set some goals\n",
+		},
+		{
+			"html",
+			"text/html; charset=utf-8",
+			"<article>\n<h1>Untitled</h1>\n<p><a href=\"" + mockPageUrl + "\">Open in Notion</a></p>\n" +
+				"<ul class=\"to-do\">\n<li><input type=\"checkbox\" disabled checked> Set some goals</li>\n</ul>\n</article>\n",
+		},
+	}
+
+	for _, test := range tests {
+		// Arrange
+		api := &TestApiConfig{
+			pages: []notion.Page{
+				{
+					Id:             mockPageId,
+					CreatedTime:    mockTime,
+					LastEditedTime: mockTime,
+					Url:            mockPageUrl,
+				},
+			},
+		}
+		selector := &TestSelector{}
+		db := &TestDynamoDb{}
+		event := events.APIGatewayV2HTTPRequest{
+			QueryStringParameters: map[string]string{"format": test.format},
+		}
+
+		// Set expectations for mock methods
+		api.Mock.On("GetPagesSinceTime", mock.Anything)
+		api.Mock.On("GetDatabaseId")
+		api.Mock.On("GetPageContent", mockPageId)
+		selector.Mock.On("SelectPage")
+		db.Mock.On("GetItem", mock.Anything)
+		db.Mock.On("PutItem", mock.Anything)
+
+		// Act
+		handler := handleRequestForApi(api, selector, db)
+		result, err := handler(context.Background(), event)
+
+		// Assert
+		require.NoError(t, err)
+		expected := events.APIGatewayV2HTTPResponse{
+			StatusCode: 200,
+			Body:       test.body,
+			Headers:    map[string]string{"Content-Type": test.contentType},
+		}
+		assert.EqualValues(t, expected, result)
+		api.AssertExpectations(t)
+		selector.AssertExpectations(t)
+	}
+}
+
+// Reports the pages in dead as deleted from Notion
+type TestCheckedApiConfig struct {
+	TestApiConfig
+	dead map[string]bool
+}
+
+func (api *TestCheckedApiConfig) GetPage(ctx context.Context, pageId string) (*notion.Page, error) {
+	api.MethodCalled("GetPage", pageId)
+	if api.dead[pageId] {
+		return nil, &notion.APIError{Status: 404, Code: notion.CODE_OBJECT_NOT_FOUND}
+	}
+	return &notion.Page{Id: pageId}, nil
+}
+
+func TestRetrySelectionWhenPageIsDeleted(t *testing.T) {
+	// Arrange
+	deadPageId := "5331da24-6597-4f2d-a684-fd94a0f3278a"
+	api := &TestCheckedApiConfig{
+		TestApiConfig: TestApiConfig{
+			pages: []notion.Page{
+				{Id: mockPageId, CreatedTime: mockTime, Url: mockPageUrl},
+				{Id: deadPageId, CreatedTime: mockTime, Url: "https://www.notion.so/Deleted-5331da2465974f2da684fd94a0f3278a"},
+			},
+		},
+		dead: map[string]bool{deadPageId: true},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+	event := events.APIGatewayV2HTTPRequest{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	api.Mock.On("GetPage", deadPageId).Once()
+	api.Mock.On("GetPage", mockPageId).Once()
+	selector.Mock.On("SelectPage").Twice()
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	// Act
+	handler := handleRequestForApi(api, selector, db)
+	result, err := handler(context.Background(), event)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Equal(t, fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\"}", mockPageId, mockPageUrl), result.Body)
+	api.AssertExpectations(t)
+	selector.AssertExpectations(t)
+}
+
+// Knows a single user, who created mockPageId
+type TestUsersApiConfig struct {
+	TestApiConfig
+}
+
+var mockUser = notion.User{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada Lovelace"}
+
+func (api *TestUsersApiConfig) ResolveUsers(ctx context.Context, page *notion.Page) error {
+	if page.CreatedBy != nil && page.CreatedBy.Id == mockUser.Id {
+		page.CreatedBy = &mockUser
+	}
+	return nil
+}
+
+func (api *TestUsersApiConfig) ResolveCommentUsers(ctx context.Context, comments []notion.Comment) error {
+	return nil
+}
+
+func (api *TestUsersApiConfig) FindUsers(ctx context.Context, nameOrId string) ([]notion.User, error) {
+	if nameOrId == mockUser.Name {
+		return []notion.User{mockUser}, nil
+	}
+	return []notion.User{}, nil
+}
+
+func TestSelectPageByAuthor(t *testing.T) {
+	// Arrange
+	api := &TestUsersApiConfig{
+		TestApiConfig: TestApiConfig{
+			pages: []notion.Page{
+				{Id: mockPageId, CreatedTime: mockTime, Url: mockPageUrl, CreatedBy: &notion.User{Id: mockUser.Id}},
+				{Id: nextCursor, CreatedTime: mockTime, Url: "https://www.notion.so/Someone-else-5331da2465974f2da684fd94a0f3278a"},
+			},
+		},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	// Act
+	handler := handleRequestForApi(api, selector, db)
+	result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		QueryStringParameters: map[string]string{"author": "Ada Lovelace"},
+	})
+	unknown, _ := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		QueryStringParameters: map[string]string{"author": "Alan Turing"},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Equal(t, fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\", \"author\":\"Ada Lovelace\"}", mockPageId, mockPageUrl), result.Body)
+	assert.Equal(t, 404, unknown.StatusCode)
+}
+
+// Holds the comments on mockPageId, and records those created
+type TestCommentsApiConfig struct {
+	TestApiConfig
+	comments []notion.Comment
+}
+
+func (api *TestCommentsApiConfig) ListComments(ctx context.Context, blockId string) ([]notion.Comment, error) {
+	api.MethodCalled("ListComments", blockId)
+	return api.comments, nil
+}
+
+func (api *TestCommentsApiConfig) CreateComment(ctx context.Context, comment notion.NewComment) (*notion.Comment, error) {
+	api.MethodCalled("CreateComment", comment.PageId, notion.PlainText(comment.RichText))
+	created := notion.Comment{
+		Id:           "94cc56ab-9f02-409d-9f99-1037e9fe502f",
+		DiscussionId: "f1407351-36f5-4c49-a13c-49f8ba11776d",
+		RichText:     comment.RichText,
+	}
+	api.comments = append(api.comments, created)
+	return &created, nil
+}
+
+func TestReturnPageComments(t *testing.T) {
+	// Arrange
+	api := &TestCommentsApiConfig{
+		TestApiConfig: TestApiConfig{
+			pages: []notion.Page{{Id: mockPageId, CreatedTime: mockTime, Url: mockPageUrl}},
+		},
+		comments: []notion.Comment{{
+			CreatedTime: "2022-07-15T16:52:00.000Z",
+			CreatedBy:   &mockUser,
+			RichText:    []notion.RichText{notion.NewRichText("Still true")},
+		}},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	api.Mock.On("ListComments", mockPageId).Once()
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	// Act
+	handler := handleRequestForApi(api, selector, db)
+	result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		QueryStringParameters: map[string]string{"comments": "true"},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Equal(t, fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\", "+
+		"\"comments\":[{\"author\":\"Ada Lovelace\",\"text\":\"Still true\",\"created_time\":\"2022-07-15T16:52:00.000Z\"}]}",
+		mockPageId, mockPageUrl), result.Body)
+	api.AssertExpectations(t)
+}
+
+func TestReflectOnPage(t *testing.T) {
+	secret := map[string]string{"x-reflect-secret": "secret_reflect"}
+	tests := []struct {
+		name     string
+		body     string
+		headers  map[string]string
+		status   int
+		expected string
+	}{
+		{
+			"comment",
+			`{"page_id": "` + mockPageId + `", "text": "Worth revisiting"}`,
+			secret,
+			201,
+			`{"discussion_id":"f1407351-36f5-4c49-a13c-49f8ba11776d","id":"94cc56ab-9f02-409d-9f99-1037e9fe502f"}`,
+		},
+		{
+			"page ID without dashes",
+			`{"page_id": "` + strings.ReplaceAll(mockPageId, "-", "") + `", "text": "Worth revisiting"}`,
+			secret,
+			201,
+			`{"discussion_id":"f1407351-36f5-4c49-a13c-49f8ba11776d","id":"94cc56ab-9f02-409d-9f99-1037e9fe502f"}`,
+		},
+		{"no text", `{"page_id": "` + mockPageId + `"}`, secret, 400, "Expected a JSON body with page_id and text"},
+		{"not json", "Worth revisiting", secret, 400, "Expected a JSON body with page_id and text"},
+		{
+			"no secret",
+			`{"page_id": "` + mockPageId + `", "text": "Worth revisiting"}`,
+			nil,
+			401,
+			"A workspace key or reflect secret is required",
+		},
+		{
+			"wrong secret",
+			`{"page_id": "` + mockPageId + `", "text": "Worth revisiting"}`,
+			map[string]string{"X-Reflect-Secret": "guess"},
+			401,
+			"A workspace key or reflect secret is required",
+		},
+		{
+			"page that isn't cached",
+			`{"page_id": "5331da24-6597-4f2d-a684-fd94a0f3278a", "text": "Worth revisiting"}`,
+			secret,
+			404,
+			"Page not found in the configured databases",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			api := &TestCommentsApiConfig{}
+			api.Mock.On("GetDatabaseId").Maybe()
+			api.Mock.On("CreateComment", mock.Anything, "Worth revisiting").Maybe()
+			cached, _ := dynamodbattribute.MarshalMap(persistence.NotionDTO{
+				DatabaseId: mockDatabaseId,
+				Pages:      []notion.Page{{Id: mockPageId, CreatedTime: mockTime, Url: mockPageUrl}},
+			})
+			db := &TestDynamoDb{outputMap: cached}
+			db.Mock.On("GetItem", mock.Anything).Maybe()
+			event := events.APIGatewayV2HTTPRequest{RawPath: "/reflect", Body: test.body, Headers: test.headers}
+			event.RequestContext.HTTP.Method = "POST"
+
+			// Act
+			handler := handleRequestWithOptions(api, &TestSelector{}, db, handlerOptions{ReflectSecret: "secret_reflect"})
+			result, err := handler(context.Background(), event)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, test.status, result.StatusCode)
+			assert.Equal(t, test.expected, result.Body)
+			if test.status != 201 {
+				api.AssertNotCalled(t, "CreateComment", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// Records the properties each page is updated with
+type TestUpdatedApiConfig struct {
+	TestApiConfig
+	updates map[string]map[string]notion.PropertyValue
+}
+
+func (api *TestUpdatedApiConfig) UpdatePage(ctx context.Context, pageId string, properties map[string]notion.PropertyValue) (*notion.Page, error) {
+	api.updates[pageId] = properties
+	return &notion.Page{Id: pageId}, nil
+}
+
+func TestWriteBackSurfacedPage(t *testing.T) {
+	// Arrange
+	count := float64(4)
+	api := &TestUpdatedApiConfig{
+		TestApiConfig: TestApiConfig{
+			pages: []notion.Page{{
+				Id:          mockPageId,
+				CreatedTime: mockTime,
+				Url:         mockPageUrl,
+				Properties: map[string]notion.PropertyValue{
+					"Times Surfaced": {Type: notion.PROPERTY_NUMBER, Number: &count},
+				},
+			}},
+		},
+		updates: map[string]map[string]notion.PropertyValue{},
+	}
+	selector := &TestSelector{}
+	db := &TestDynamoDb{}
+
+	// Set expectations for mock methods
+	api.Mock.On("GetPagesSinceTime", mock.Anything)
+	api.Mock.On("GetDatabaseId")
+	selector.Mock.On("SelectPage")
+	db.Mock.On("GetItem", mock.Anything)
+	db.Mock.On("PutItem", mock.Anything)
+
+	// Act
+	handler := handleRequestWithOptions(api, selector, db, handlerOptions{
+		Surface: &selection.SurfaceOptions{TimesSurfaced: "Times Surfaced"},
+	})
+	result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	require.Contains(t, api.updates, mockPageId)
+	assert.Equal(t, float64(5), *api.updates[mockPageId]["Times Surfaced"].Number)
+}
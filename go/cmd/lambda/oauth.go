@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+const (
+	OAuthStateCookie   = "random_notion_oauth_state"
+	WorkspaceKeyCookie = "random_notion_key"
+)
+
+var errInvalidWorkspaceKey = errors.New("Invalid workspace key")
+
+// Settings of the public integration, for serving workspaces that authorized it through OAuth
+type oauthOptions struct {
+	Config notion.OAuthConfig
+	Tokens *persistence.TokenCipher
+	Api    *notion.ApiConfig // Copied for each workspace, with its own token and databases
+}
+
+// Redirect to Notion to authorize the integration, remembering the state to expect back in a cookie
+func authorizeResponse(ctx context.Context, oauth oauthOptions) events.APIGatewayV2HTTPResponse {
+	state, err := randomToken()
+	if err != nil {
+		logging.GetLoggerWithContext(ctx).Err(err).Msg("Unable to generate OAuth state")
+		return events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: "Internal server error"}
+	}
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: http.StatusFound,
+		Headers:    map[string]string{"Location": oauth.Api.AuthorizeUrl(oauth.Config, state)},
+		Cookies:    []string{fmt.Sprintf("%s=%s; Path=/oauth; Max-Age=600; HttpOnly; Secure; SameSite=Lax", OAuthStateCookie, state)},
+	}
+}
+
+type callbackBody struct {
+	WorkspaceId   string `json:"workspace_id"`
+	WorkspaceName string `json:"workspace_name,omitempty"`
+	Databases     int    `json:"databases"`
+	Key           string `json:"key"` // Sent by callers from the workspace as a bearer token
+}
+
+// Exchange the code Notion redirects back with for the workspace's access token,
+// and store it along with the databases shared with the integration.
+// Responds with the key that identifies callers from the workspace, also set as a cookie
+func callbackResponse(ctx context.Context, e events.APIGatewayV2HTTPRequest, db dynamodbiface.DynamoDBAPI,
+	oauth oauthOptions) events.APIGatewayV2HTTPResponse {
+	logger := logging.GetLoggerWithContext(ctx)
+	params := e.QueryStringParameters
+	if params["error"] != "" {
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: 400,
+			Body:       fmt.Sprintf("Authorization failed: %s", params["error"]),
+		}
+	}
+	state := cookie(e, OAuthStateCookie)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(params["state"])) != 1 {
+		logger.Warn().Msg("OAuth callback state doesn't match")
+		return events.APIGatewayV2HTTPResponse{StatusCode: 400, Body: "Invalid OAuth state"}
+	}
+	if params["code"] == "" {
+		return events.APIGatewayV2HTTPResponse{StatusCode: 400, Body: "No OAuth code given"}
+	}
+
+	token, err := oauth.Api.ExchangeOAuthCode(ctx, oauth.Config, params["code"])
+	if err != nil {
+		return errorResponse(err)
+	}
+	databases, err := oauth.Api.WithToken(token.AccessToken, nil).Search(ctx, notion.SearchQuery{Object: notion.SEARCH_OBJECT_DATABASE})
+	if err != nil {
+		logger.Err(err).Str("workspace_id", token.WorkspaceId).Msg("Unable to find databases shared with the integration")
+		return errorResponse(err)
+	}
+	workspace := persistence.Workspace{
+		Id:          token.WorkspaceId,
+		Name:        token.WorkspaceName,
+		BotId:       token.BotId,
+		AccessToken: token.AccessToken,
+		DatabaseIds: []string{},
+	}
+	for _, database := range databases {
+		workspace.DatabaseIds = append(workspace.DatabaseIds, database.DatabaseId())
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		logger.Err(err).Msg("Unable to generate workspace key")
+		return events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: "Internal server error"}
+	}
+	hash := sha256.Sum256([]byte(secret))
+	workspace.KeyHash = hash[:]
+	if err := persistence.PutWorkspace(db, oauth.Tokens, workspace); err != nil {
+		logger.Err(err).Str("workspace_id", workspace.Id).Msg("Unable to store workspace")
+		return events.APIGatewayV2HTTPResponse{StatusCode: 500, Body: "Internal server error"}
+	}
+
+	key := workspace.Id + "." + secret
+	body, _ := json.Marshal(callbackBody{
+		WorkspaceId:   workspace.Id,
+		WorkspaceName: workspace.Name,
+		Databases:     len(workspace.DatabaseIds),
+		Key:           key,
+	})
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: 200,
+		Body:       string(body),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Cookies: []string{
+			fmt.Sprintf("%s=%s; Path=/; Max-Age=31536000; HttpOnly; Secure; SameSite=Lax", WorkspaceKeyCookie, key),
+			fmt.Sprintf("%s=; Path=/oauth; Max-Age=0; HttpOnly; Secure; SameSite=Lax", OAuthStateCookie),
+		},
+	}
+}
+
+// Return a config for the workspace identified by the request's key, sent as a bearer token
+// or cookie, or nil if the request has no key. Keys are "<workspace ID>.<secret>"
+func workspaceApi(ctx context.Context, e events.APIGatewayV2HTTPRequest, db dynamodbiface.DynamoDBAPI,
+	oauth oauthOptions) (*notion.ApiConfig, error) {
+	key := cookie(e, WorkspaceKeyCookie)
+	if authorization := header(e, "Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		key = strings.TrimPrefix(authorization, "Bearer ")
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	separator := strings.LastIndex(key, ".")
+	if separator <= 0 {
+		return nil, errInvalidWorkspaceKey
+	}
+	workspaceId, secret := key[:separator], key[separator+1:]
+	workspace, err := persistence.GetWorkspace(db, oauth.Tokens, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read workspace: %w", err)
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if workspace == nil || subtle.ConstantTimeCompare(hash[:], workspace.KeyHash) != 1 {
+		return nil, errInvalidWorkspaceKey
+	}
+	logging.GetLoggerWithContext(ctx).Debug().Str("workspace_id", workspace.Id).Msg("Serving workspace")
+	return oauth.Api.WithToken(workspace.AccessToken, workspace.DatabaseIds), nil
+}
+
+// Return the value of the named cookie sent with the request, or an empty string
+func cookie(e events.APIGatewayV2HTTPRequest, name string) string {
+	for _, c := range e.Cookies {
+		if value := strings.TrimPrefix(c, name+"="); value != c {
+			return value
+		}
+	}
+	return ""
+}
+
+// Return the value of a request header, whatever its case
+func header(e events.APIGatewayV2HTTPRequest, name string) string {
+	for key, value := range e.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Return 32 random bytes, URL-safe base64 encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	selection "github.com/jeffrosenberg/random-notion/internal/pageselection"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockWorkspaceId = "2f9d5a07-6a1c-4b8e-8e43-7f5c3b0a9d11"
+
+// Holds one item per key, as the DynamoDb table does
+type TestKeyedDynamoDb struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (db *TestKeyedDynamoDb) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: db.items[*input.Key["database_id"].S]}, nil
+}
+
+func (db *TestKeyedDynamoDb) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	db.items[*input.Item["database_id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// Serve a workspace with one database of one page, which only its own token can read
+func mockOAuthServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" && r.Header.Get("Authorization") != "Bearer secret_workspace" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"object": "error", "status": 401, "code": "unauthorized", "message": "API token is invalid."}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/oauth/token":
+			w.Write([]byte(`{"access_token": "secret_workspace", "bot_id": "b3414d65-1224-4a6c-9f4d-1c1d6a1a4b2e", "workspace_id": "` + mockWorkspaceId + `", "workspace_name": "Ada's Notion"}`))
+		case "/search":
+			w.Write([]byte(`{"object": "list", "results": [{"object": "database", "id": "` + mockDatabaseId + `"}], "next_cursor": null, "has_more": false}`))
+		case "/databases/" + mockDatabaseId + "/query":
+			w.Write([]byte(`{"object": "list", "results": [{"object": "page", "id": "` + mockPageId + `", "created_time": "` + mockTime + `", "url": "` + mockPageUrl + `"}], "next_cursor": null, "has_more": false}`))
+		case "/comments":
+			w.Write([]byte(`{"object": "comment", "id": "94cc56ab-9f02-409d-9f99-1037e9fe502f", "discussion_id": "f1407351-36f5-4c49-a13c-49f8ba11776d"}`))
+		case "/pages/" + mockPageId:
+			w.Write([]byte(`{"object": "page", "id": "` + mockPageId + `", "url": "` + mockPageUrl + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Not found."}`))
+		}
+	}))
+}
+
+func mockOAuthOptions(url string) *oauthOptions {
+	tokens, _ := persistence.NewTokenCipher(bytes.Repeat([]byte{7}, persistence.TOKEN_KEY_LENGTH))
+	return &oauthOptions{
+		Config: notion.OAuthConfig{ClientId: "463558a3-725e-4f37-b6d3-0889894f68de", ClientSecret: "secret_oauth"},
+		Tokens: tokens,
+		Api:    &notion.ApiConfig{Url: url},
+	}
+}
+
+func TestAuthorizeAndServeWorkspace(t *testing.T) {
+	// Arrange
+	ts := mockOAuthServer()
+	defer ts.Close()
+	db := &TestKeyedDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	oauth := mockOAuthOptions(ts.URL)
+	handler := handleRequestWithOptions(oauth.Api, &selection.RandomPage{}, db, handlerOptions{OAuth: oauth})
+
+	// Act
+	authorize, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{RawPath: "/oauth/authorize"})
+	require.NoError(t, err)
+	require.Len(t, authorize.Cookies, 1)
+	stateCookie := strings.SplitN(authorize.Cookies[0], ";", 2)[0]
+	state := strings.TrimPrefix(stateCookie, OAuthStateCookie+"=")
+	callback, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		RawPath:               "/oauth/callback",
+		QueryStringParameters: map[string]string{"code": "a1b2c3", "state": state},
+		Cookies:               []string{stateCookie},
+	})
+	require.NoError(t, err)
+	var body callbackBody
+	require.NoError(t, json.Unmarshal([]byte(callback.Body), &body))
+	result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"authorization": "Bearer " + body.Key},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, authorize.StatusCode)
+	assert.True(t, strings.HasPrefix(authorize.Headers["Location"], ts.URL+"/oauth/authorize?"))
+	assert.Contains(t, authorize.Headers["Location"], "state="+state)
+	assert.Equal(t, 200, callback.StatusCode)
+	assert.Equal(t, mockWorkspaceId, body.WorkspaceId)
+	assert.Equal(t, 1, body.Databases)
+	assert.True(t, strings.HasPrefix(callback.Cookies[0], WorkspaceKeyCookie+"="+body.Key+";"))
+	assert.NotContains(t, db.items[persistence.WORKSPACE_KEY_PREFIX+mockWorkspaceId]["encrypted_token"].String(), "secret_workspace")
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Equal(t, fmt.Sprintf("{\"id\":\"%s\", \"url\":\"%s\"}", mockPageId, mockPageUrl), result.Body)
+}
+
+// Authorize the integration for the mock workspace, returning its workspace key
+func authorizeWorkspace(t *testing.T, handler HandlerFn) string {
+	authorize, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{RawPath: "/oauth/authorize"})
+	require.NoError(t, err)
+	stateCookie := strings.SplitN(authorize.Cookies[0], ";", 2)[0]
+	callback, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		RawPath:               "/oauth/callback",
+		QueryStringParameters: map[string]string{"code": "a1b2c3", "state": strings.TrimPrefix(stateCookie, OAuthStateCookie+"=")},
+		Cookies:               []string{stateCookie},
+	})
+	require.NoError(t, err)
+	var body callbackBody
+	require.NoError(t, json.Unmarshal([]byte(callback.Body), &body))
+	return body.Key
+}
+
+func TestReflectWithWorkspaceKey(t *testing.T) {
+	// Arrange
+	ts := mockOAuthServer()
+	defer ts.Close()
+	db := &TestKeyedDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	oauth := mockOAuthOptions(ts.URL)
+	handler := handleRequestWithOptions(oauth.Api, &selection.RandomPage{}, db, handlerOptions{OAuth: oauth})
+	key := authorizeWorkspace(t, handler)
+	reflect := func(headers map[string]string) events.APIGatewayV2HTTPRequest {
+		event := events.APIGatewayV2HTTPRequest{
+			RawPath: "/reflect",
+			Body:    `{"page_id": "` + mockPageId + `", "text": "Worth revisiting"}`,
+			Headers: headers,
+		}
+		event.RequestContext.HTTP.Method = "POST"
+		return event
+	}
+
+	// Act
+	_, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{Headers: map[string]string{"Authorization": "Bearer " + key}})
+	require.NoError(t, err)
+	withKey, withKeyErr := handler(context.Background(), reflect(map[string]string{"Authorization": "Bearer " + key}))
+	withoutKey, withoutKeyErr := handler(context.Background(), reflect(map[string]string{ReflectSecretHeader: ""}))
+
+	// Assert
+	require.NoError(t, withKeyErr)
+	require.NoError(t, withoutKeyErr)
+	assert.Equal(t, 201, withKey.StatusCode, "The page was cached when it was surfaced")
+	assert.Equal(t, 401, withoutKey.StatusCode)
+}
+
+func TestRejectOAuthCallbackWithWrongState(t *testing.T) {
+	// Arrange
+	ts := mockOAuthServer()
+	defer ts.Close()
+	db := &TestKeyedDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	oauth := mockOAuthOptions(ts.URL)
+	handler := handleRequestWithOptions(oauth.Api, &selection.RandomPage{}, db, handlerOptions{OAuth: oauth})
+
+	// Act
+	result, err := handler(context.Background(), events.APIGatewayV2HTTPRequest{
+		RawPath:               "/oauth/callback",
+		QueryStringParameters: map[string]string{"code": "a1b2c3", "state": "forged"},
+		Cookies:               []string{OAuthStateCookie + "=expected"},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 400, result.StatusCode)
+	assert.Empty(t, db.items)
+}
+
+func TestRejectInvalidWorkspaceKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		request events.APIGatewayV2HTTPRequest
+		body    string
+	}{
+		{"no key", events.APIGatewayV2HTTPRequest{}, "No workspace key given; authorize the integration at /oauth/authorize"},
+		{"unknown workspace", events.APIGatewayV2HTTPRequest{Cookies: []string{WorkspaceKeyCookie + "=" + mockWorkspaceId + ".guess"}}, "Invalid workspace key"},
+		{"malformed", events.APIGatewayV2HTTPRequest{Headers: map[string]string{"Authorization": "Bearer guess"}}, "Invalid workspace key"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			db := &TestKeyedDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+			oauth := mockOAuthOptions("http://localhost:0")
+			handler := handleRequestWithOptions(oauth.Api, &selection.RandomPage{}, db, handlerOptions{OAuth: oauth})
+
+			// Act
+			result, err := handler(context.Background(), test.request)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, 401, result.StatusCode)
+			assert.Equal(t, test.body, result.Body)
+		})
+	}
+}
@@ -4,7 +4,7 @@ go 1.16
 
 require (
 	github.com/aws/aws-lambda-go v1.27.0
-	github.com/aws/aws-sdk-go v1.42.25 // indirect
+	github.com/aws/aws-sdk-go v1.42.25
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
 	github.com/rs/zerolog v1.26.1
 	github.com/stretchr/testify v1.7.0
@@ -0,0 +1,28 @@
+package pageselection
+
+import (
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Select only from pages created by one of the given users, e.g. on a shared team database
+type AuthorPages struct {
+	Selector  PageSelector
+	AuthorIds []string
+}
+
+func ByAuthor(selector PageSelector, authorIds ...string) *AuthorPages {
+	return &AuthorPages{Selector: selector, AuthorIds: authorIds}
+}
+
+func (a *AuthorPages) SelectPage(pages []notion.Page) *notion.Page {
+	authored := []notion.Page{}
+	for _, page := range pages {
+		if page.CreatedByAny(a.AuthorIds...) {
+			authored = append(authored, page)
+		}
+	}
+	if len(authored) == 0 {
+		return nil
+	}
+	return a.Selector.SelectPage(authored)
+}
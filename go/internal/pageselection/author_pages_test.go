@@ -0,0 +1,27 @@
+package pageselection
+
+import (
+	"testing"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectPageByAuthor(t *testing.T) {
+	// Arrange
+	ada := &notion.User{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed"}
+	grace := &notion.User{Id: "e79a0b74-3aba-4149-9f74-0bb5791a6ee6"}
+	pages := []notion.Page{
+		{Id: mockPageId, CreatedBy: ada},
+		{Id: mockPageId2, CreatedBy: grace},
+		{Id: mockPageId3},
+	}
+
+	// Act
+	byAda := ByAuthor(TestLastPage{}, ada.Id).SelectPage(pages)
+	byNobody := ByAuthor(TestLastPage{}, "1d4e9b2c-7f3a-4e8b-a5c6-2b9d0e1f3a4c").SelectPage(pages)
+
+	// Assert
+	assert.Equal(t, mockPageId, byAda.Id)
+	assert.Nil(t, byNobody)
+}
@@ -0,0 +1,121 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Properties captured pages are tagged and linked with, unless configured otherwise
+const (
+	DEFAULT_TAGS_PROPERTY = "Tags"
+	DEFAULT_URL_PROPERTY  = "URL"
+)
+
+var ErrCaptureUnsupported = errors.New("Capture not available")
+
+// Something to resurface later, to be added to a database as a new page
+type Capture struct {
+	Title string
+	Tags  []string
+	Url   string
+	Body  string // Plain text, with paragraphs separated by blank lines
+}
+
+type CaptureOptions struct {
+	DatabaseId   string // The first database of api if empty
+	TagsProperty string // Multi-select property for Capture.Tags; DEFAULT_TAGS_PROPERTY if empty
+	UrlProperty  string // URL property for Capture.Url; DEFAULT_URL_PROPERTY if empty
+}
+
+// Create a page in Notion from capture, and add it to the cached pages of its database
+// so that it can be selected straight away. The page is still returned if it can't be cached
+func CapturePage(ctx context.Context, api notion.PageGetter, db dynamodbiface.DynamoDBAPI,
+	capture Capture, opts CaptureOptions) (*notion.Page, error) {
+	creator, ok := api.(notion.PageCreator)
+	if !ok {
+		return nil, ErrCaptureUnsupported
+	}
+	if strings.TrimSpace(capture.Title) == "" {
+		return nil, fmt.Errorf("Unable to capture page: no title given")
+	}
+	databaseId := opts.DatabaseId
+	if databaseId == "" {
+		if ids := DatabaseIds(api); len(ids) > 0 {
+			databaseId = ids[0]
+		}
+	}
+
+	newPage := capturedPage(capture, opts)
+	newPage.DatabaseId = databaseId
+	page, err := creator.CreatePage(ctx, newPage)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to capture page: %w", err)
+	}
+	if err := CachePage(db, page); err != nil {
+		logging.GetLoggerWithContext(ctx).Err(err).Str("page_id", page.Id).Msg("Unable to cache captured page in DynamoDb")
+	}
+	return page, nil
+}
+
+// Return the page to create for capture, with a paragraph per paragraph of its body.
+// Paragraphs beyond the most Notion accepts are combined into the last one
+func capturedPage(capture Capture, opts CaptureOptions) notion.NewPage {
+	tagsProperty, urlProperty := opts.TagsProperty, opts.UrlProperty
+	if tagsProperty == "" {
+		tagsProperty = DEFAULT_TAGS_PROPERTY
+	}
+	if urlProperty == "" {
+		urlProperty = DEFAULT_URL_PROPERTY
+	}
+
+	properties := map[string]notion.PropertyValue{
+		"title": {Title: []notion.RichText{notion.NewRichText(strings.TrimSpace(capture.Title))}},
+	}
+	if len(capture.Tags) > 0 {
+		tags := make([]notion.SelectOption, len(capture.Tags))
+		for i, tag := range capture.Tags {
+			tags[i] = notion.SelectOption{Name: tag}
+		}
+		properties[tagsProperty] = notion.PropertyValue{MultiSelect: tags}
+	}
+	if capture.Url != "" {
+		url := capture.Url
+		properties[urlProperty] = notion.PropertyValue{Url: &url}
+	}
+
+	paragraphs := []string{}
+	for _, paragraph := range strings.Split(strings.ReplaceAll(capture.Body, "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	if len(paragraphs) > notion.MAX_NEW_PAGE_CHILDREN {
+		last := notion.MAX_NEW_PAGE_CHILDREN - 1
+		paragraphs = append(paragraphs[:last], strings.Join(paragraphs[last:], "\n\n"))
+	}
+	children := make([]notion.Block, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		children[i] = notion.NewParagraph(paragraph)
+	}
+
+	return notion.NewPage{Properties: properties, Children: children}
+}
+
+// Add a page to the cached pages of its database. Unlike RefreshPages,
+// the cache isn't written if it can't be read, so that it isn't overwritten
+func CachePage(db dynamodbiface.DynamoDBAPI, page *notion.Page) error {
+	databaseId := page.SourceDatabaseId
+	dto, err := persistence.GetPages(db, &databaseId)
+	if err != nil {
+		return err
+	}
+	MergePages(dto, []notion.Page{*page})
+	return persistence.PutPages(db, dto)
+}
@@ -0,0 +1,89 @@
+package pageselection
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates pages in the first of TestMultiApi's databases
+type TestCreatorApi struct {
+	TestMultiApi
+	created []notion.NewPage
+}
+
+func (api *TestCreatorApi) CreatePage(ctx context.Context, page notion.NewPage) (*notion.Page, error) {
+	api.created = append(api.created, page)
+	return &notion.Page{Id: mockPageId2, SourceDatabaseId: page.DatabaseId}, nil
+}
+
+func TestCapturePage(t *testing.T) {
+	// Arrange
+	cached, _ := dynamodbattribute.MarshalMap(persistence.NotionDTO{
+		DatabaseId: "reading-list",
+		Pages:      []notion.Page{{Id: mockPageId, SourceDatabaseId: "reading-list"}},
+		LastQuery:  mockLastQueryTime,
+	})
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{"reading-list": cached}}
+	api := &TestCreatorApi{}
+
+	// Act
+	page, err := CapturePage(context.Background(), api, db, Capture{
+		Title: "Gödel, Escher, Bach",
+		Tags:  []string{"books", "maths"},
+		Url:   "https://example.com/geb",
+		Body:  "Strange loops.\r\n\r\nRecursion everywhere.\n",
+	}, CaptureOptions{})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, api.created, 1)
+	created := api.created[0]
+	assert.Equal(t, "reading-list", created.DatabaseId)
+	assert.Equal(t, "Gödel, Escher, Bach", notion.PlainText(created.Properties["title"].Title))
+	assert.Equal(t, []notion.SelectOption{{Name: "books"}, {Name: "maths"}}, created.Properties["Tags"].MultiSelect)
+	assert.Equal(t, "https://example.com/geb", *created.Properties["URL"].Url)
+	require.Len(t, created.Children, 2)
+	assert.Equal(t, "Recursion everywhere.", notion.PlainText(created.Children[1].RichText()))
+
+	// The captured page is cached alongside the existing ones
+	dto := db.dto(t, "reading-list")
+	assert.Equal(t, []notion.Page{{Id: mockPageId, SourceDatabaseId: "reading-list"}, *page}, dto.Pages)
+	assert.Equal(t, mockLastQueryTime, dto.LastQuery)
+}
+
+func TestCapturePageWithoutTitle(t *testing.T) {
+	// Arrange
+	api := &TestCreatorApi{}
+
+	// Act
+	_, err := CapturePage(context.Background(), api, &TestDynamoDb{}, Capture{Title: " "}, CaptureOptions{})
+
+	// Assert
+	assert.EqualError(t, err, "Unable to capture page: no title given")
+	assert.Empty(t, api.created)
+}
+
+func TestCapturedPageCombinesExtraParagraphs(t *testing.T) {
+	// Arrange
+	paragraphs := make([]string, notion.MAX_NEW_PAGE_CHILDREN+2)
+	for i := range paragraphs {
+		paragraphs[i] = "Line"
+	}
+
+	// Act
+	page := capturedPage(Capture{Title: "Notes", Body: strings.Join(paragraphs, "\n\n")}, CaptureOptions{TagsProperty: "Topics"})
+
+	// Assert
+	require.Len(t, page.Children, notion.MAX_NEW_PAGE_CHILDREN)
+	assert.Equal(t, "Line\n\nLine\n\nLine", notion.PlainText(page.Children[notion.MAX_NEW_PAGE_CHILDREN-1].RichText()))
+	assert.NotContains(t, page.Properties, "Topics")
+	assert.NotContains(t, page.Properties, "URL")
+}
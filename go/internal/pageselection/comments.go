@@ -0,0 +1,67 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+var ErrCommentsUnsupported = errors.New("Comments not available")
+
+// The page isn't cached from any of the databases pages are selected from
+var ErrUnknownPage = errors.New("Unknown page")
+
+// Return the comment thread on a page with the names of its authors filled in, if api can look them up
+func PageComments(ctx context.Context, api notion.PageGetter, pageId string) ([]notion.Comment, error) {
+	commenter, ok := api.(notion.Commenter)
+	if !ok {
+		return nil, ErrCommentsUnsupported
+	}
+	comments, err := commenter.ListComments(ctx, pageId)
+	if err != nil {
+		return nil, err
+	}
+	if resolver, ok := api.(notion.UserResolver); ok {
+		if err := resolver.ResolveCommentUsers(ctx, comments); err != nil {
+			logging.GetLoggerWithContext(ctx).Warn().Err(err).Str("page_id", pageId).Msg("Unable to resolve users of comments")
+		}
+	}
+	return comments, nil
+}
+
+// Post a comment onto a resurfaced page, e.g. to record what it prompted
+func Reflect(ctx context.Context, api notion.PageGetter, pageId string, text string) (*notion.Comment, error) {
+	commenter, ok := api.(notion.Commenter)
+	if !ok {
+		return nil, ErrCommentsUnsupported
+	}
+	return commenter.CreateComment(ctx, notion.NewComment{
+		PageId:   pageId,
+		RichText: []notion.RichText{notion.NewRichText(text)},
+	})
+}
+
+// Return ErrUnknownPage unless the page is cached from one of the databases of api,
+// e.g. so that callers can't comment with its token on any other page it has access to.
+// Notion accepts IDs with or without dashes, so they're compared without
+func CheckCachedPage(api notion.PageGetter, db dynamodbiface.DynamoDBAPI, pageId string) error {
+	id := notion.NormalizeId(pageId)
+	for _, databaseId := range DatabaseIds(api) {
+		databaseId := databaseId
+		dto, err := persistence.GetPages(db, &databaseId)
+		if err != nil {
+			return fmt.Errorf("Unable to read cached pages: %w", err)
+		}
+		for _, page := range dto.Pages {
+			if notion.NormalizeId(page.Id) == id {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownPage, pageId)
+}
@@ -0,0 +1,101 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Most pages SelectLivePage picks before giving up on finding one that still exists
+const MAX_PICK_ATTEMPTS = 3
+
+// Remove cached pages that aren't among live, the complete set of pages in the database,
+// returning how many were removed
+func PrunePages(dto *persistence.NotionDTO, live []notion.Page) int {
+	ids := make(map[string]struct{}, len(live))
+	for _, page := range live {
+		if !page.Removed() {
+			ids[page.Id] = struct{}{}
+		}
+	}
+	return prunePages(dto, func(page notion.Page) bool {
+		_, exists := ids[page.Id]
+		return exists
+	})
+}
+
+// Remove cached pages that Notion reports as archived or trashed
+func pruneRemovedPages(dto *persistence.NotionDTO) int {
+	return prunePages(dto, func(page notion.Page) bool { return !page.Removed() })
+}
+
+// Remove the page with the given ID from the DTO, returning whether it was cached
+func RemovePage(dto *persistence.NotionDTO, pageId string) bool {
+	return prunePages(dto, func(page notion.Page) bool { return page.Id != pageId }) > 0
+}
+
+func prunePages(dto *persistence.NotionDTO, keep func(notion.Page) bool) int {
+	kept := dto.Pages[:0]
+	for _, page := range dto.Pages {
+		if keep(page) {
+			kept = append(kept, page)
+		}
+	}
+	removed := len(dto.Pages) - len(kept)
+	dto.Pages = kept
+	return removed
+}
+
+// Remove the page with the given ID from whichever database cached it,
+// saving the database's pages back to DynamoDb and returning whether it was cached
+func (r RefreshResult) RemovePage(db dynamodbiface.DynamoDBAPI, pageId string) (bool, error) {
+	for _, database := range r.Databases {
+		if RemovePage(database.Dto, pageId) {
+			return true, persistence.PutPages(db, database.Dto)
+		}
+	}
+	return false, nil
+}
+
+// Select a page from the refreshed pages, checking with Notion that it still exists.
+// Pages that have been deleted, archived or trashed are removed from the cache and another is picked.
+// The selected page is returned along with the page as just retrieved from Notion, e.g. for RecordSurfaced.
+// If api can't check pages, or the check fails for another reason, the selected page is returned as is
+// and the retrieved page is nil
+func SelectLivePage(ctx context.Context, api notion.PageGetter, selector PageSelector,
+	db dynamodbiface.DynamoDBAPI, result RefreshResult) (*notion.Page, *notion.Page, error) {
+	logger := logging.GetLoggerWithContext(ctx)
+	checker, canCheck := api.(notion.PageChecker)
+
+	for attempt := 1; attempt <= MAX_PICK_ATTEMPTS; attempt++ {
+		selectedPage := selector.SelectPage(result.Pages())
+		if selectedPage == nil || !canCheck {
+			return selectedPage, nil, nil
+		}
+
+		page, err := checker.GetPage(ctx, selectedPage.Id)
+		if err != nil && !errors.Is(err, notion.ErrObjectNotFound) {
+			logger.Warn().Err(err).Str("page_id", selectedPage.Id).Msg("Unable to check selected page, using it anyway")
+			return selectedPage, nil, nil
+		}
+		if err == nil && !page.Removed() {
+			return selectedPage, page, nil
+		}
+
+		logger.Info().
+			Str("page_id", selectedPage.Id).
+			Int("attempt", attempt).
+			Msg("Selected page no longer exists, removing it from the cache")
+		if _, err := result.RemovePage(db, selectedPage.Id); err != nil {
+			// The page is still removed from those selected from
+			logger.Err(err).Str("page_id", selectedPage.Id).Msg("Unable to remove page from the cache in DynamoDb")
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Unable to find a page that still exists after %d attempts: %w", MAX_PICK_ATTEMPTS, notion.ErrObjectNotFound)
+}
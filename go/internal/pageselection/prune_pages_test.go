@@ -0,0 +1,148 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Reports the pages in dead as deleted and those in trashed as in the trash
+type TestCheckedApi struct {
+	notion.PageGetter
+	dead    map[string]bool
+	trashed map[string]bool
+	checked []string
+}
+
+func (api *TestCheckedApi) GetPage(ctx context.Context, pageId string) (*notion.Page, error) {
+	api.checked = append(api.checked, pageId)
+	if api.dead[pageId] {
+		return nil, &notion.APIError{Status: 404, Code: notion.CODE_OBJECT_NOT_FOUND}
+	}
+	return &notion.Page{Id: pageId, InTrash: api.trashed[pageId]}, nil
+}
+
+// Fails to check any page
+type failingCheckApi struct {
+	notion.PageGetter
+}
+
+func (api *failingCheckApi) GetPage(ctx context.Context, pageId string) (*notion.Page, error) {
+	return nil, errors.New("Received invalid status: 503")
+}
+
+// Always selects the last page
+type TestLastPage struct{}
+
+func (TestLastPage) SelectPage(pages []notion.Page) *notion.Page {
+	if len(pages) == 0 {
+		return nil
+	}
+	return &pages[len(pages)-1]
+}
+
+func TestPrunePages(t *testing.T) {
+	// Arrange
+	input := persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages:      []notion.Page{{Id: mockPageId}, {Id: mockPageId2}, {Id: mockPageId3}},
+	}
+
+	// Act
+	removed := PrunePages(&input, []notion.Page{{Id: mockPageId}, {Id: mockPageId3, Archived: true}})
+
+	// Assert
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, []notion.Page{{Id: mockPageId}}, input.Pages)
+}
+
+func TestSelectLivePageRemovesDeadPages(t *testing.T) {
+	// Arrange
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	api := &TestCheckedApi{
+		dead:    map[string]bool{mockPageId3: true},
+		trashed: map[string]bool{mockPageId2: true},
+	}
+	result := RefreshResult{Databases: []DatabasePages{{Dto: &persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages:      []notion.Page{{Id: mockPageId}, {Id: mockPageId2}, {Id: mockPageId3}},
+	}}}}
+
+	// Act
+	page, live, err := SelectLivePage(context.Background(), api, TestLastPage{}, db, result)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, mockPageId, page.Id)
+	assert.Equal(t, mockPageId, live.Id)
+	assert.Equal(t, []string{mockPageId3, mockPageId2, mockPageId}, api.checked)
+	assert.Equal(t, []notion.Page{{Id: mockPageId}}, db.dto(t, mockDatabaseId).Pages)
+}
+
+func TestSelectLivePageGivesUp(t *testing.T) {
+	// Arrange
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	api := &TestCheckedApi{dead: map[string]bool{mockPageId: true, mockPageId2: true, mockPageId3: true}}
+	pages := []notion.Page{{Id: "4c5a7e2b-0d3f-4b8e-9a61-2f7d8c9e0a1b"}, {Id: mockPageId}, {Id: mockPageId2}, {Id: mockPageId3}}
+	result := RefreshResult{Databases: []DatabasePages{{Dto: &persistence.NotionDTO{DatabaseId: mockDatabaseId, Pages: pages}}}}
+
+	// Act
+	page, _, err := SelectLivePage(context.Background(), api, TestLastPage{}, db, result)
+
+	// Assert
+	assert.Nil(t, page)
+	assert.ErrorIs(t, err, notion.ErrObjectNotFound)
+	assert.Len(t, api.checked, MAX_PICK_ATTEMPTS)
+}
+
+func TestSelectLivePageWhenCheckFails(t *testing.T) {
+	// Arrange
+	api := &failingCheckApi{}
+	result := RefreshResult{Databases: []DatabasePages{{Dto: &persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages:      []notion.Page{{Id: mockPageId}},
+	}}}}
+
+	// Act
+	page, live, err := SelectLivePage(context.Background(), api, TestLastPage{}, nil, result)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, mockPageId, page.Id) // Picked anyway, since it might still exist
+	assert.Nil(t, live)
+}
+
+func TestRefreshPagesReconcilesCache(t *testing.T) {
+	// Arrange
+	cached, _ := dynamodbattribute.MarshalMap(persistence.NotionDTO{
+		DatabaseId: "reading-list",
+		Pages:      []notion.Page{{Id: mockPageId}, {Id: mockPageId2}},
+		LastQuery:  mockLastQueryTime,
+	})
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{"reading-list": cached}}
+	api := &TestMultiApi{results: map[string]notion.DatabaseResult{
+		"reading-list":  {DatabaseId: "reading-list", Pages: []notion.Page{{Id: mockPageId}, {Id: mockPageId3}}},
+		"zettelkasten":  {DatabaseId: "zettelkasten", Err: &notion.PartialResultError{Err: context.DeadlineExceeded}},
+		"meeting-notes": {DatabaseId: "meeting-notes"},
+	}}
+
+	// Act
+	result := RefreshPages(context.Background(), api, db, mockExecTime, RefreshOptions{ReconcileInterval: DEFAULT_RECONCILE_INTERVAL})
+
+	// Assert
+	require.Len(t, api.queries, 3)
+	assert.True(t, api.queries[0].Since.IsZero()) // Every page is retrieved
+	assert.Equal(t, 1, result.Pruned())
+
+	dto := db.dto(t, "reading-list")
+	assert.Equal(t, []string{mockPageId, mockPageId3}, pageIds(dto.Pages))
+	assert.Equal(t, mockExecTime, dto.LastReconcile)
+	assert.NotContains(t, db.items, "zettelkasten") // Partial, so nothing is known to be gone
+}
@@ -0,0 +1,243 @@
+package pageselection
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// How RefreshPages finds pages to update the cache with
+const (
+	SYNC_CREATED = "created" // Only pages created since the last query
+	SYNC_EDITED  = "edited"  // Pages created or edited since the last query, replacing cached copies
+)
+
+// How often the CLI and Lambda check every cached page against Notion by default
+const DEFAULT_RECONCILE_INTERVAL = 24 * time.Hour
+
+type RefreshOptions struct {
+	Mode string // SYNC_CREATED if empty
+	// How often to retrieve every page of a database, so that pages deleted,
+	// archived or trashed in Notion are removed from the cache; never if zero
+	ReconcileInterval time.Duration
+}
+
+// Pages of one database, combining those cached in DynamoDb with those retrieved from Notion
+type DatabasePages struct {
+	Dto     *persistence.NotionDTO
+	Added   int   // Pages retrieved from Notion that weren't cached
+	Updated int   // Cached pages replaced by a newer copy from Notion
+	Removed int   // Cached pages that no longer exist in Notion
+	Partial bool  // Whether the Notion API stopped before returning every page
+	Err     error // Error retrieving pages from the Notion API, other than a partial result
+	// Error saving the pages to DynamoDb, after which the cache is no longer updated
+	CacheErr error
+}
+
+type RefreshResult struct {
+	Databases []DatabasePages
+}
+
+// Return the pages of every database
+func (r RefreshResult) Pages() []notion.Page {
+	pages := []notion.Page{}
+	for _, database := range r.Databases {
+		pages = append(pages, database.Dto.Pages...)
+	}
+	return pages
+}
+
+// Return whether any database has only partial results from the Notion API
+func (r RefreshResult) Partial() bool {
+	for _, database := range r.Databases {
+		if database.Partial {
+			return true
+		}
+	}
+	return false
+}
+
+// Return how many pages were added to and updated in the cache across every database
+func (r RefreshResult) Counts() (added int, updated int) {
+	for _, database := range r.Databases {
+		added += database.Added
+		updated += database.Updated
+	}
+	return
+}
+
+// Return how many pages were removed from the cache across every database
+func (r RefreshResult) Pruned() (removed int) {
+	for _, database := range r.Databases {
+		removed += database.Removed
+	}
+	return
+}
+
+// Return the first error retrieving pages from the Notion API, if any
+func (r RefreshResult) Err() error {
+	for _, database := range r.Databases {
+		if database.Err != nil {
+			return database.Err
+		}
+	}
+	return nil
+}
+
+// Return the first error saving pages to DynamoDb, if any
+func (r RefreshResult) CacheErr() error {
+	for _, database := range r.Databases {
+		if database.CacheErr != nil {
+			return database.CacheErr
+		}
+	}
+	return nil
+}
+
+// Return the databases api retrieves pages from
+func DatabaseIds(api notion.PageGetter) []string {
+	if multi, ok := api.(notion.MultiPageGetter); ok {
+		return multi.GetDatabaseIds()
+	}
+	if id := api.GetDatabaseId(); id != "" {
+		return []string{id}
+	}
+	return []string{}
+}
+
+// Update the cached pages of each database with those created (or, in SYNC_EDITED mode, edited)
+// in Notion since it was last queried. Each database keeps its own watermark,
+// which only advances as far as the pages retrieved from it.
+// Every opts.ReconcileInterval, all of a database's pages are retrieved instead
+// and cached pages missing from them are removed
+func RefreshPages(ctx context.Context, api notion.PageGetter, db dynamodbiface.DynamoDBAPI,
+	execStartTime int64, opts RefreshOptions) RefreshResult {
+	logger := logging.GetLoggerWithContext(ctx)
+	databaseIds := DatabaseIds(api)
+	edited, canEdit := api.(notion.EditedPageGetter)
+	if opts.Mode == SYNC_EDITED && !canEdit {
+		logger.Warn().Msg("Unable to sync edited pages, syncing created pages instead")
+	}
+	syncEdited := opts.Mode == SYNC_EDITED && canEdit
+
+	// 1. Get cached pages from DynamoDb
+	result := RefreshResult{Databases: make([]DatabasePages, len(databaseIds))}
+	queries := make([]notion.DatabaseQuery, len(databaseIds))
+	reconcile := make([]bool, len(databaseIds))
+	for i, databaseId := range databaseIds {
+		databaseId := databaseId
+		dto, err := persistence.GetPages(db, &databaseId)
+		if dto == nil {
+			if err != nil {
+				logger.Err(err).Str("database_id", databaseId).Msg("Unable to read cached data from DynamoDb")
+			}
+			// We could still read from the API, so set dto to a stub and keep going
+			dto = &persistence.NotionDTO{
+				DatabaseId: databaseId,
+				Pages:      []notion.Page{},
+				LastQuery:  execStartTime,
+			}
+		}
+		result.Databases[i].Dto = dto
+		queries[i] = notion.DatabaseQuery{
+			DatabaseId: databaseId,
+			Since:      time.Unix(dto.LastQuery, 0),
+			Edited:     syncEdited,
+		}
+		if opts.ReconcileInterval > 0 && time.Unix(execStartTime, 0).Sub(time.Unix(dto.LastReconcile, 0)) >= opts.ReconcileInterval {
+			reconcile[i] = true
+			queries[i].Since = time.Time{}
+		}
+	}
+
+	// 2. Get additional pages from the Notion API
+	var apiResults []notion.DatabaseResult
+	if multi, ok := api.(notion.MultiPageGetter); ok && len(queries) > 1 {
+		apiResults = multi.GetPagesFromDatabases(ctx, queries)
+	} else if len(queries) == 1 {
+		var pages []notion.Page
+		var err error
+		if syncEdited {
+			pages, err = edited.GetPagesEditedSinceTimeWithContext(ctx, queries[0].Since)
+		} else {
+			pages, err = api.GetPagesSinceTimeWithContext(ctx, queries[0].Since)
+		}
+		apiResults = []notion.DatabaseResult{{DatabaseId: queries[0].DatabaseId, Pages: pages, Err: err}}
+	}
+
+	// 3. Dedup and combine both sources of pages, replacing stale copies when syncing edits
+	for i, apiResult := range apiResults {
+		database := &result.Databases[i]
+		apiPages := apiResult.Pages
+		if notion.IsPartial(apiResult.Err) {
+			// Keep whatever was retrieved before the deadline
+			logger.Warn().Err(apiResult.Err).Str("database_id", apiResult.DatabaseId).Msg("Using partial results from Notion API")
+			database.Partial = true
+		} else if apiResult.Err != nil {
+			logger.Err(apiResult.Err).Str("database_id", apiResult.DatabaseId).Msg("Unable to read pages from Notion API")
+			// We could still use the cached pages, so keep going
+			database.Err = apiResult.Err
+			apiPages = []notion.Page{}
+		}
+
+		logger.Debug().
+			Str("database_id", apiResult.DatabaseId).
+			Int("pages_cached", len(database.Dto.Pages)).
+			Int("pages_api", len(apiPages)).
+			Msg("Retrieved pages")
+
+		if syncEdited || reconcile[i] {
+			database.Added, database.Updated = MergePages(database.Dto, apiPages)
+			database.Removed = pruneRemovedPages(database.Dto)
+		} else {
+			cached := len(database.Dto.Pages)
+			UnionPages(database.Dto, apiPages)
+			database.Added = len(database.Dto.Pages) - cached
+		}
+		// Only a complete list of pages shows which cached pages are gone
+		reconciled := reconcile[i] && !database.Partial && database.Err == nil
+		if reconciled {
+			database.Removed += PrunePages(database.Dto, apiPages)
+			database.Dto.LastReconcile = execStartTime
+		}
+		logger.Info().
+			Str("database_id", apiResult.DatabaseId).
+			Int("pages_added", database.Added).
+			Int("pages_updated", database.Updated).
+			Int("pages_removed", database.Removed).
+			Bool("reconciled", reconciled).
+			Msg("Refreshed cached pages")
+
+		if database.Added > 0 || database.Updated > 0 || database.Removed > 0 || reconciled {
+			if !database.Partial {
+				database.Dto.LastQuery = execStartTime
+			} else if syncEdited {
+				// Edited pages arrive oldest first, so everything up to the last one is cached
+				database.Dto.LastQuery = lastEditedTime(apiPages, database.Dto.LastQuery)
+			}
+			// Otherwise don't advance the watermark past pages we haven't retrieved yet
+			if err := persistence.PutPages(db, database.Dto); err != nil {
+				logger.Err(err).Str("database_id", apiResult.DatabaseId).Msg("Unable to cache pages in DynamoDb")
+				database.CacheErr = err
+			}
+		}
+	}
+
+	return result
+}
+
+// Return when the last of pages was edited, or fallback if it can't be parsed
+func lastEditedTime(pages []notion.Page, fallback int64) int64 {
+	if len(pages) == 0 {
+		return fallback
+	}
+	edited, err := time.Parse(time.RFC3339, pages[len(pages)-1].LastEditedTime)
+	if err != nil || edited.Unix() < fallback {
+		return fallback
+	}
+	return edited.Unix()
+}
@@ -0,0 +1,183 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockExecTime int64 = 1640000000
+
+// Caches one DTO per database, as the DynamoDb table does
+type TestDynamoDb struct {
+	dynamodbiface.DynamoDBAPI
+	items  map[string]map[string]*dynamodb.AttributeValue
+	putErr error // Returned by PutItem instead of storing the item, if set
+}
+
+type TestMultiApi struct {
+	notion.PageGetter
+	results map[string]notion.DatabaseResult
+	queries []notion.DatabaseQuery
+}
+
+func (db *TestDynamoDb) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: db.items[*input.Key["database_id"].S]}, nil
+}
+
+func (db *TestDynamoDb) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if db.putErr != nil {
+		return nil, db.putErr
+	}
+	db.items[*input.Item["database_id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (db *TestDynamoDb) dto(t *testing.T, databaseId string) persistence.NotionDTO {
+	var dto persistence.NotionDTO
+	require.NoError(t, dynamodbattribute.UnmarshalMap(db.items[databaseId], &dto))
+	return dto
+}
+
+func (api *TestMultiApi) GetDatabaseIds() []string {
+	return []string{"reading-list", "zettelkasten", "meeting-notes"}
+}
+
+func (api *TestMultiApi) GetPagesFromDatabases(ctx context.Context, queries []notion.DatabaseQuery) []notion.DatabaseResult {
+	api.queries = queries
+	results := []notion.DatabaseResult{}
+	for _, query := range queries {
+		results = append(results, api.results[query.DatabaseId])
+	}
+	return results
+}
+
+func (api *TestMultiApi) GetPagesEditedSinceTimeWithContext(ctx context.Context, since time.Time) ([]notion.Page, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func TestRefreshPagesFromMultipleDatabases(t *testing.T) {
+	// Arrange
+	cached, _ := dynamodbattribute.MarshalMap(persistence.NotionDTO{
+		DatabaseId: "zettelkasten",
+		Pages:      []notion.Page{{Id: mockPageId, SourceDatabaseId: "zettelkasten"}},
+		LastQuery:  mockLastQueryTime,
+	})
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{"zettelkasten": cached}}
+	api := &TestMultiApi{results: map[string]notion.DatabaseResult{
+		"reading-list": {
+			DatabaseId: "reading-list",
+			Pages:      []notion.Page{{Id: mockPageId2, SourceDatabaseId: "reading-list"}},
+		},
+		"zettelkasten": {
+			DatabaseId: "zettelkasten",
+			Pages:      []notion.Page{{Id: mockPageId3, SourceDatabaseId: "zettelkasten"}},
+			Err:        &notion.PartialResultError{Err: context.DeadlineExceeded},
+		},
+		"meeting-notes": {
+			DatabaseId: "meeting-notes",
+			Err:        errors.New("Received invalid status: 503"),
+		},
+	}}
+
+	// Act
+	result := RefreshPages(context.Background(), api, db, mockExecTime, RefreshOptions{})
+
+	// Assert
+	require.Len(t, api.queries, 3)
+	assert.Equal(t, time.Unix(0, 0), api.queries[0].Since) // Never queried
+	assert.Equal(t, time.Unix(mockLastQueryTime, 0), api.queries[1].Since)
+
+	assert.ElementsMatch(t, []string{mockPageId, mockPageId2, mockPageId3}, pageIds(result.Pages()))
+	assert.True(t, result.Partial())
+	assert.EqualError(t, result.Err(), "Received invalid status: 503")
+
+	// Each database keeps its own watermark
+	assert.Equal(t, mockExecTime, db.dto(t, "reading-list").LastQuery)
+	assert.Equal(t, mockLastQueryTime, db.dto(t, "zettelkasten").LastQuery) // Partial, so not advanced
+	assert.Len(t, db.dto(t, "zettelkasten").Pages, 2)
+	assert.NotContains(t, db.items, "meeting-notes")
+}
+
+func TestRefreshPagesWithEditedPages(t *testing.T) {
+	// Arrange
+	cached, _ := dynamodbattribute.MarshalMap(persistence.NotionDTO{
+		DatabaseId: "reading-list",
+		Pages:      []notion.Page{{Id: mockPageId, LastEditedTime: "2021-12-10T07:00:00.000Z"}},
+		LastQuery:  mockLastQueryTime,
+	})
+	db := &TestDynamoDb{items: map[string]map[string]*dynamodb.AttributeValue{"reading-list": cached}}
+	api := &TestMultiApi{results: map[string]notion.DatabaseResult{
+		"reading-list": {
+			DatabaseId: "reading-list",
+			Pages: []notion.Page{
+				{Id: mockPageId, LastEditedTime: "2021-12-10T07:30:00.000Z"},
+				{Id: mockPageId2, LastEditedTime: "2021-12-10T08:00:00.000Z"},
+			},
+			Err: &notion.PartialResultError{Err: context.DeadlineExceeded},
+		},
+		"zettelkasten":  {DatabaseId: "zettelkasten"},
+		"meeting-notes": {DatabaseId: "meeting-notes"},
+	}}
+
+	// Act
+	result := RefreshPages(context.Background(), api, db, mockExecTime, RefreshOptions{Mode: SYNC_EDITED})
+
+	// Assert
+	require.Len(t, api.queries, 3)
+	assert.True(t, api.queries[0].Edited)
+	added, updated := result.Counts()
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, updated)
+
+	dto := db.dto(t, "reading-list")
+	assert.Equal(t, "2021-12-10T07:30:00.000Z", dto.Pages[0].LastEditedTime)
+	// Partial, so only advanced as far as the last page retrieved
+	assert.Equal(t, time.Date(2021, 12, 10, 8, 0, 0, 0, time.UTC).Unix(), dto.LastQuery)
+}
+
+func TestRefreshPagesReturnsCacheErrors(t *testing.T) {
+	// Arrange
+	db := &TestDynamoDb{
+		items:  map[string]map[string]*dynamodb.AttributeValue{},
+		putErr: errors.New("Item size has exceeded the maximum allowed size"),
+	}
+	api := &TestMultiApi{results: map[string]notion.DatabaseResult{
+		"reading-list":  {DatabaseId: "reading-list", Pages: []notion.Page{{Id: mockPageId}}},
+		"zettelkasten":  {DatabaseId: "zettelkasten"},
+		"meeting-notes": {DatabaseId: "meeting-notes"},
+	}}
+
+	// Act
+	result := RefreshPages(context.Background(), api, db, mockExecTime, RefreshOptions{})
+
+	// Assert
+	assert.Equal(t, []string{mockPageId}, pageIds(result.Pages()), "The pages retrieved can still be selected from")
+	assert.NoError(t, result.Err())
+	assert.EqualError(t, result.CacheErr(), "Error inserting to DynamoDb: Item size has exceeded the maximum allowed size")
+	assert.Error(t, result.Databases[0].CacheErr)
+	assert.NoError(t, result.Databases[1].CacheErr, "Databases without new pages aren't saved")
+}
+
+func TestDatabaseIdsOfSingleDatabase(t *testing.T) {
+	api := &notion.ApiConfig{DatabaseId: mockDatabaseId}
+
+	assert.Equal(t, []string{mockDatabaseId}, DatabaseIds(api))
+}
+
+func pageIds(pages []notion.Page) []string {
+	ids := []string{}
+	for _, page := range pages {
+		ids = append(ids, page.Id)
+	}
+	return ids
+}
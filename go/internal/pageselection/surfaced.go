@@ -0,0 +1,112 @@
+package pageselection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Properties written back to a page when it's surfaced, unless configured otherwise
+const (
+	DEFAULT_LAST_SURFACED_PROPERTY  = "Last Surfaced"
+	DEFAULT_TIMES_SURFACED_PROPERTY = "Times Surfaced"
+)
+
+var ErrWriteBackUnsupported = errors.New("Write-back not available")
+
+// A property to write back is missing from the database or has the wrong type
+var ErrMissingProperty = errors.New("Missing property")
+
+type SurfaceOptions struct {
+	LastSurfaced  string // Date property set to the day the page is surfaced; not set if empty
+	TimesSurfaced string // Number property incremented each time the page is surfaced; not set if empty
+	DryRun        bool   // Work out the properties to set without updating the page
+}
+
+// Return the default SurfaceOptions, which set both properties
+func DefaultSurfaceOptions() SurfaceOptions {
+	return SurfaceOptions{
+		LastSurfaced:  DEFAULT_LAST_SURFACED_PROPERTY,
+		TimesSurfaced: DEFAULT_TIMES_SURFACED_PROPERTY,
+	}
+}
+
+// Record in Notion that the page was surfaced at surfacedAt, returning the property values set,
+// or those that would be set in a dry run. The count is incremented from live, the page as just
+// retrieved from Notion, rather than the cached page. If live is nil, the page is retrieved again if api can
+func RecordSurfaced(ctx context.Context, api notion.PageGetter, page *notion.Page, live *notion.Page,
+	surfacedAt time.Time, opts SurfaceOptions) (map[string]notion.PropertyValue, error) {
+	logger := logging.GetLoggerWithContext(ctx)
+	updater, canUpdate := api.(notion.PageUpdater)
+	if !canUpdate && !opts.DryRun {
+		return nil, ErrWriteBackUnsupported
+	}
+
+	current := page
+	if live != nil {
+		current = live
+	} else if checker, ok := api.(notion.PageChecker); ok {
+		retrieved, err := checker.GetPage(ctx, page.Id)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to retrieve page %s: %w", page.Id, err)
+		}
+		current = retrieved
+	}
+
+	properties, err := surfacedProperties(current, surfacedAt, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun || len(properties) == 0 {
+		logger.Info().Str("page_id", page.Id).Interface("properties", properties).Msg("Skipping write-back of surfaced page")
+		return properties, nil
+	}
+
+	if _, err := updater.UpdatePage(ctx, page.Id, properties); err != nil {
+		return nil, fmt.Errorf("Unable to update page %s: %w", page.Id, err)
+	}
+	logger.Info().Str("page_id", page.Id).Interface("properties", properties).Msg("Recorded surfaced page in Notion")
+	return properties, nil
+}
+
+// Return the property values recording that page was surfaced at surfacedAt.
+// Notion returns every property of the database with a page, even empty ones,
+// so a property missing from page is missing from the database
+func surfacedProperties(page *notion.Page, surfacedAt time.Time, opts SurfaceOptions) (map[string]notion.PropertyValue, error) {
+	properties := map[string]notion.PropertyValue{}
+	if opts.LastSurfaced != "" {
+		if _, err := surfacedProperty(page, opts.LastSurfaced, notion.PROPERTY_DATE); err != nil {
+			return nil, err
+		}
+		properties[opts.LastSurfaced] = notion.PropertyValue{
+			Date: &notion.DateValue{Start: surfacedAt.Format("2006-01-02")},
+		}
+	}
+	if opts.TimesSurfaced != "" {
+		property, err := surfacedProperty(page, opts.TimesSurfaced, notion.PROPERTY_NUMBER)
+		if err != nil {
+			return nil, err
+		}
+		count := float64(1)
+		if property.Number != nil {
+			count += *property.Number
+		}
+		properties[opts.TimesSurfaced] = notion.PropertyValue{Number: &count}
+	}
+	return properties, nil
+}
+
+func surfacedProperty(page *notion.Page, name string, propertyType string) (notion.PropertyValue, error) {
+	property, ok := page.Property(name)
+	if !ok {
+		return property, fmt.Errorf("%w: the database has no %s property named %q", ErrMissingProperty, propertyType, name)
+	}
+	if property.Type != propertyType {
+		return property, fmt.Errorf("%w: %q is a %s property, expected %s", ErrMissingProperty, name, property.Type, propertyType)
+	}
+	return property, nil
+}
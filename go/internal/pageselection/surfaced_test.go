@@ -0,0 +1,173 @@
+package pageselection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/jeffrosenberg/random-notion/pkg/notiontest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Serves a single page as it is in Notion, and records updates to it
+type TestUpdatedApi struct {
+	notion.PageGetter
+	page    notion.Page
+	updates []map[string]notion.PropertyValue
+}
+
+func (api *TestUpdatedApi) GetPage(ctx context.Context, pageId string) (*notion.Page, error) {
+	return &api.page, nil
+}
+
+func (api *TestUpdatedApi) UpdatePage(ctx context.Context, pageId string, properties map[string]notion.PropertyValue) (*notion.Page, error) {
+	api.updates = append(api.updates, properties)
+	return &api.page, nil
+}
+
+var surfacedAt = time.Date(2022, 7, 15, 16, 52, 0, 0, time.UTC)
+
+func surfacedPage(times *float64) notion.Page {
+	return notion.Page{
+		Id: mockPageId,
+		Properties: map[string]notion.PropertyValue{
+			"Last Surfaced":  {Type: notion.PROPERTY_DATE},
+			"Times Surfaced": {Type: notion.PROPERTY_NUMBER, Number: times},
+		},
+	}
+}
+
+func TestRecordSurfaced(t *testing.T) {
+	// Arrange
+	times := float64(2)
+	api := &TestUpdatedApi{page: surfacedPage(&times)}
+	// The cached copy is out of date, so the count comes from the page in Notion
+	cached := surfacedPage(nil)
+
+	// Act
+	properties, err := RecordSurfaced(context.Background(), api, &cached, nil, surfacedAt, DefaultSurfaceOptions())
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, api.updates, 1)
+	assert.Equal(t, properties, api.updates[0])
+	assert.Equal(t, "2022-07-15", properties["Last Surfaced"].Date.Start)
+	assert.Equal(t, float64(3), *properties["Times Surfaced"].Number)
+}
+
+func TestRecordSurfacedInNotion(t *testing.T) {
+	// Arrange
+	server := notiontest.NewServer(notiontest.Options{})
+	defer server.Close()
+	db := server.AddDatabase(notion.Database{Properties: map[string]notion.PropertySchema{
+		"Last Surfaced":  {Type: notion.PROPERTY_DATE},
+		"Times Surfaced": {Type: notion.PROPERTY_NUMBER},
+	}})
+	times := float64(2)
+	page := server.AddPage(db.Id, notion.Page{Properties: map[string]notion.PropertyValue{
+		"Times Surfaced": {Number: &times},
+	}})
+
+	// Act
+	_, firstErr := RecordSurfaced(context.Background(), server.Api(), &page, nil, surfacedAt, DefaultSurfaceOptions())
+	_, secondErr := RecordSurfaced(context.Background(), server.Api(), &page, nil, surfacedAt, DefaultSurfaceOptions())
+
+	// Assert
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	updated, _ := server.Page(page.Id)
+	assert.Equal(t, "2022-07-15", updated.Properties["Last Surfaced"].Date.Start)
+	assert.Equal(t, float64(4), *updated.Properties["Times Surfaced"].Number)
+}
+
+func TestRecordSurfacedFromLivePage(t *testing.T) {
+	// Arrange
+	server := notiontest.NewServer(notiontest.Options{})
+	defer server.Close()
+	db := server.AddDatabase(notion.Database{Properties: map[string]notion.PropertySchema{
+		"Last Surfaced":  {Type: notion.PROPERTY_DATE},
+		"Times Surfaced": {Type: notion.PROPERTY_NUMBER},
+	}})
+	times := float64(2)
+	page := server.AddPage(db.Id, notion.Page{Properties: map[string]notion.PropertyValue{
+		"Times Surfaced": {Number: &times},
+	}})
+	cached := surfacedPage(nil)
+	cached.Id = page.Id
+
+	// Act
+	properties, err := RecordSurfaced(context.Background(), server.Api(), &cached, &page, surfacedAt, DefaultSurfaceOptions())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), *properties["Times Surfaced"].Number)
+	assert.Equal(t, 1, server.Requests(), "The live page isn't retrieved again")
+}
+
+func TestRecordSurfacedFirstTime(t *testing.T) {
+	// Arrange
+	api := &TestUpdatedApi{page: surfacedPage(nil)}
+
+	// Act
+	properties, err := RecordSurfaced(context.Background(), api, &api.page, nil, surfacedAt,
+		SurfaceOptions{TimesSurfaced: "Times Surfaced"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), *properties["Times Surfaced"].Number)
+	assert.NotContains(t, properties, "Last Surfaced")
+}
+
+func TestRecordSurfacedDryRun(t *testing.T) {
+	// Arrange
+	api := &TestUpdatedApi{page: surfacedPage(nil)}
+	opts := DefaultSurfaceOptions()
+	opts.DryRun = true
+
+	// Act
+	properties, err := RecordSurfaced(context.Background(), api, &api.page, nil, surfacedAt, opts)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, properties, 2)
+	assert.Empty(t, api.updates)
+}
+
+func TestRecordSurfacedWithMissingProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     SurfaceOptions
+		expected string
+	}{
+		{"missing", SurfaceOptions{LastSurfaced: "Last Seen"}, `Missing property: the database has no date property named "Last Seen"`},
+		{"wrong type", SurfaceOptions{TimesSurfaced: "Last Surfaced"}, `Missing property: "Last Surfaced" is a date property, expected number`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			api := &TestUpdatedApi{page: surfacedPage(nil)}
+
+			// Act
+			_, err := RecordSurfaced(context.Background(), api, &api.page, nil, surfacedAt, test.opts)
+
+			// Assert
+			assert.ErrorIs(t, err, ErrMissingProperty)
+			assert.EqualError(t, err, test.expected)
+			assert.Empty(t, api.updates)
+		})
+	}
+}
+
+func TestRecordSurfacedUnsupported(t *testing.T) {
+	// Arrange
+	page := surfacedPage(nil)
+
+	// Act
+	_, err := RecordSurfaced(context.Background(), &TestCheckedApi{}, &page, nil, surfacedAt, DefaultSurfaceOptions())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrWriteBackUnsupported)
+}
@@ -22,23 +22,83 @@ func UnionPages(dto *persistence.NotionDTO, addl []notion.Page) (pagesAdded bool
 		return
 	}
 
-	// Store IDs in a map for deduping
-	ids := make(map[string]struct{})
-	for _, page := range dto.Pages {
-		ids[page.Id] = struct{}{}
-	}
-
-	// Dedup incoming slice of pages
-	pagesToAppend := make([]notion.Page, 0, len(addl))
+	union := NewPageUnion(dto)
 	for _, page := range addl {
-		_, exists := ids[page.Id]
-		if !exists {
-			pagesToAppend = append(pagesToAppend, page)
+		if union.Add(page) {
 			pagesAdded = true
 		}
 	}
+	return
+}
+
+// Add new pages to the DTO and replace cached pages whose content has changed,
+// returning how many pages were added and updated
+func MergePages(dto *persistence.NotionDTO, addl []notion.Page) (added int, updated int) {
+	defer logging.LogFunction(
+		"pageselection.MergePages", time.Now(), "Merging pages",
+		map[string]interface{}{
+			"pages_cached": len(dto.Pages),
+			"pages_api":    len(addl),
+		},
+	)
 
-	// Update NotionPages DTO with deduped additional pages
-	dto.Pages = append(dto.Pages, pagesToAppend...)
+	union := NewPageUnion(dto)
+	for _, page := range addl {
+		pageAdded, pageUpdated := union.Merge(page)
+		if pageAdded {
+			added++
+		}
+		if pageUpdated {
+			updated++
+		}
+	}
 	return
 }
+
+// PageUnion adds pages to a DTO one at a time, skipping those it already has,
+// so that pages can be merged as they're streamed from the Notion API
+type PageUnion struct {
+	dto *persistence.NotionDTO
+	ids map[string]int // Index of each page in dto.Pages
+}
+
+func NewPageUnion(dto *persistence.NotionDTO) *PageUnion {
+	// Store IDs in a map for deduping
+	ids := make(map[string]int, len(dto.Pages))
+	for i, page := range dto.Pages {
+		ids[page.Id] = i
+	}
+	return &PageUnion{dto: dto, ids: ids}
+}
+
+// Add page to the DTO unless it's already there, returning whether it was added
+func (u *PageUnion) Add(page notion.Page) bool {
+	if _, exists := u.ids[page.Id]; exists {
+		return false
+	}
+	u.ids[page.Id] = len(u.dto.Pages)
+	u.dto.Pages = append(u.dto.Pages, page)
+	return true
+}
+
+// Add page to the DTO, or replace the cached copy if it's been edited,
+// returning whether the page was added or updated
+func (u *PageUnion) Merge(page notion.Page) (added bool, updated bool) {
+	i, exists := u.ids[page.Id]
+	if !exists {
+		return u.Add(page), false
+	}
+	if !changed(u.dto.Pages[i], page) {
+		return false, false
+	}
+	u.dto.Pages[i] = page
+	return false, true
+}
+
+// Cached pages are stored in a compact form, so they're compared by edit time
+// and status rather than by content
+func changed(cached notion.Page, page notion.Page) bool {
+	return cached.LastEditedTime != page.LastEditedTime ||
+		cached.Archived != page.Archived ||
+		cached.InTrash != page.InTrash
+}
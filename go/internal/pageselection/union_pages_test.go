@@ -169,3 +169,90 @@ func TestNoPagesAdded(t *testing.T) {
 	assert.Equal(t, false, pagesAdded)
 	assert.Equal(t, expected, input)
 }
+
+func TestPageUnionAddsPagesIncrementally(t *testing.T) {
+	// Arrange
+	input := persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages: []notion.Page{
+			{Id: mockPageId, Url: mockPageUrl},
+		},
+		LastQuery: mockLastQueryTime,
+	}
+	union := NewPageUnion(&input)
+
+	// Act
+	added := []bool{
+		union.Add(notion.Page{Id: mockPageId2, Url: mockPageUrl2}),
+		union.Add(notion.Page{Id: mockPageId, Url: mockPageUrl}),
+		union.Add(notion.Page{Id: mockPageId2, Url: mockPageUrl2}),
+		union.Add(notion.Page{Id: mockPageId3, Url: mockPageUrl3}),
+	}
+
+	// Assert
+	assert.Equal(t, []bool{true, false, false, true}, added)
+	assert.Equal(t, []notion.Page{
+		{Id: mockPageId, Url: mockPageUrl},
+		{Id: mockPageId2, Url: mockPageUrl2},
+		{Id: mockPageId3, Url: mockPageUrl3},
+	}, input.Pages)
+}
+
+func TestMergePagesReplacesChangedPages(t *testing.T) {
+	// Arrange
+	input := persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages: []notion.Page{
+			{Id: mockPageId, LastEditedTime: mockCreatedTime, Url: mockPageUrl},
+			{Id: mockPageId2, LastEditedTime: mockCreatedTime, Url: mockPageUrl2},
+		},
+		LastQuery: mockLastQueryTime,
+	}
+	edited := "2021-12-10T08:00:00.000Z"
+
+	// Act
+	added, updated := MergePages(&input, []notion.Page{
+		{Id: mockPageId2, LastEditedTime: mockCreatedTime, Url: mockPageUrl2},
+		{Id: mockPageId, LastEditedTime: edited, Url: mockPageUrl},
+		{Id: mockPageId3, LastEditedTime: edited, Url: mockPageUrl3},
+	})
+
+	// Assert
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, []notion.Page{
+		{Id: mockPageId, LastEditedTime: edited, Url: mockPageUrl},
+		{Id: mockPageId2, LastEditedTime: mockCreatedTime, Url: mockPageUrl2},
+		{Id: mockPageId3, LastEditedTime: edited, Url: mockPageUrl3},
+	}, input.Pages)
+}
+
+func TestMergePagesComparesEditTimeAndStatus(t *testing.T) {
+	// Arrange
+	input := persistence.NotionDTO{
+		DatabaseId: mockDatabaseId,
+		Pages: []notion.Page{
+			{Id: mockPageId, LastEditedTime: mockCreatedTime, Url: mockPageUrl},
+			{Id: mockPageId2, LastEditedTime: mockCreatedTime, Url: mockPageUrl2},
+		},
+		LastQuery: mockLastQueryTime,
+	}
+	fetched := notion.Page{
+		Id:             mockPageId,
+		LastEditedTime: mockCreatedTime,
+		Url:            mockPageUrl,
+		Properties: map[string]notion.PropertyValue{
+			"Name": {Title: []notion.RichText{notion.NewRichText("Initial goals")}},
+		},
+	}
+	archived := notion.Page{Id: mockPageId2, LastEditedTime: mockCreatedTime, Url: mockPageUrl2, Archived: true}
+
+	// Act
+	added, updated := MergePages(&input, []notion.Page{fetched, archived})
+
+	// Assert
+	assert.Equal(t, 0, added)
+	assert.Equal(t, 1, updated, "Only the archived page counts as updated")
+	assert.Nil(t, input.Pages[0].Properties, "The cached copy of an unedited page is kept")
+	assert.True(t, input.Pages[1].Archived)
+}
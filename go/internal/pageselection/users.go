@@ -0,0 +1,59 @@
+package pageselection
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/internal/persistence"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Seed cache with the users persisted in DynamoDb, so they needn't be requested from Notion again
+func LoadUsers(ctx context.Context, db dynamodbiface.DynamoDBAPI, cache *notion.UserCache) {
+	users, err := persistence.GetUsers(db)
+	if err != nil {
+		logging.GetLoggerWithContext(ctx).Err(err).Msg("Unable to read cached users from DynamoDb")
+	}
+	cache.Add(users...)
+}
+
+// Persist the users in cache if it holds more than were loaded from DynamoDb
+func SaveUsers(ctx context.Context, db dynamodbiface.DynamoDBAPI, cache *notion.UserCache, loaded int) {
+	if cache.Len() <= loaded {
+		return
+	}
+	if err := persistence.PutUsers(db, cache.Users()); err != nil {
+		logging.GetLoggerWithContext(ctx).Err(err).Msg("Unable to cache users in DynamoDb")
+	}
+}
+
+// Fill in the names of the users referenced by page, if api can look them up.
+// Pages are still worth showing without names, so errors are only logged
+func ResolveUsers(ctx context.Context, api notion.PageGetter, page *notion.Page) {
+	resolver, ok := api.(notion.UserResolver)
+	if !ok || page == nil {
+		return
+	}
+	if err := resolver.ResolveUsers(ctx, page); err != nil {
+		logging.GetLoggerWithContext(ctx).Warn().Err(err).Str("page_id", page.Id).Msg("Unable to resolve users of page")
+	}
+}
+
+// Return the IDs of the users whose name or ID is author.
+// If api can't look users up, author is taken to be an ID
+func AuthorIds(ctx context.Context, api notion.PageGetter, author string) ([]string, error) {
+	resolver, ok := api.(notion.UserResolver)
+	if !ok {
+		return []string{author}, nil
+	}
+	users, err := resolver.FindUsers(ctx, author)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.Id
+	}
+	return ids, nil
+}
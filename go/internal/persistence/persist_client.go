@@ -22,6 +22,8 @@ type NotionDTO struct {
 	DatabaseId string        `dynamodbav:"database_id"`
 	Pages      []notion.Page `dynamodbav:"pages"`
 	LastQuery  int64         `dynamodbav:"last_query,omitempty"`
+	// When every cached page was last checked against Notion
+	LastReconcile int64 `dynamodbav:"last_reconcile,omitempty"`
 }
 
 func GetPages(client dynamodbiface.DynamoDBAPI, databaseId *string) (dto *NotionDTO, err error) {
@@ -65,7 +67,12 @@ func PutPages(client dynamodbiface.DynamoDBAPI, dto *NotionDTO) (err error) {
 		},
 	)
 
-	inputItem, err := dynamodbattribute.MarshalMap(dto)
+	cached := *dto
+	cached.Pages = make([]notion.Page, len(dto.Pages))
+	for i, page := range dto.Pages {
+		cached.Pages[i] = cachedPage(page)
+	}
+	inputItem, err := dynamodbattribute.MarshalMap(cached)
 	if err != nil {
 		logging.GetLogger().Err(err)
 		return fmt.Errorf("Unable to generate DynamoDb input: %w", err)
@@ -86,6 +93,100 @@ func PutPages(client dynamodbiface.DynamoDBAPI, dto *NotionDTO) (err error) {
 	return nil
 }
 
+// Longest text of a title or rich text property kept in the cache, in characters
+const MAX_CACHED_TEXT_LENGTH = 200
+
+// Return page as it's cached. Every page of a database is cached in one item, which DynamoDb
+// limits to 400 KB, so each property keeps its typed value without what selection doesn't use:
+// text is kept as plain text of at most MAX_CACHED_TEXT_LENGTH characters, users by ID and name,
+// options by name and files by name, as Notion's file URLs expire anyway. That leaves room for
+// about 700 pages with a title and a few short properties; larger databases fail to be cached
+func cachedPage(page notion.Page) notion.Page {
+	cached := notion.Page{
+		Id:             page.Id,
+		CreatedTime:    page.CreatedTime,
+		LastEditedTime: page.LastEditedTime,
+		Url:            page.Url,
+		Archived:       page.Archived,
+		InTrash:        page.InTrash,
+		CreatedBy:      cachedUser(page.CreatedBy),
+
+		SourceDatabaseId: page.SourceDatabaseId,
+	}
+	if page.Properties != nil {
+		cached.Properties = make(map[string]notion.PropertyValue, len(page.Properties))
+		for name, property := range page.Properties {
+			cached.Properties[name] = cachedProperty(property)
+		}
+	}
+	return cached
+}
+
+func cachedProperty(value notion.PropertyValue) notion.PropertyValue {
+	value.Title = cachedText(value.Title)
+	value.RichText = cachedText(value.RichText)
+	value.Select = cachedOption(value.Select)
+	value.Status = cachedOption(value.Status)
+	if value.MultiSelect != nil {
+		options := make([]notion.SelectOption, len(value.MultiSelect))
+		for i := range value.MultiSelect {
+			options[i] = *cachedOption(&value.MultiSelect[i])
+		}
+		value.MultiSelect = options
+	}
+	if value.People != nil {
+		people := make([]notion.User, len(value.People))
+		for i := range value.People {
+			people[i] = *cachedUser(&value.People[i])
+		}
+		value.People = people
+	}
+	if value.Files != nil {
+		files := make([]notion.FileValue, len(value.Files))
+		for i, file := range value.Files {
+			files[i] = notion.FileValue{Name: file.Name, Type: file.Type}
+		}
+		value.Files = files
+	}
+	value.CreatedBy = cachedUser(value.CreatedBy)
+	value.LastEditedBy = cachedUser(value.LastEditedBy)
+	if value.Rollup != nil && value.Rollup.Array != nil {
+		rollup := *value.Rollup
+		rollup.Array = make([]notion.PropertyValue, len(value.Rollup.Array))
+		for i, element := range value.Rollup.Array {
+			rollup.Array[i] = cachedProperty(element)
+		}
+		value.Rollup = &rollup
+	}
+	return value
+}
+
+// Return the text as a single plain text segment, shortened to MAX_CACHED_TEXT_LENGTH characters
+func cachedText(segments []notion.RichText) []notion.RichText {
+	if len(segments) == 0 {
+		return segments
+	}
+	text := []rune(notion.PlainText(segments))
+	if len(text) > MAX_CACHED_TEXT_LENGTH {
+		text = text[:MAX_CACHED_TEXT_LENGTH]
+	}
+	return []notion.RichText{{Type: "text", PlainText: string(text)}}
+}
+
+func cachedOption(option *notion.SelectOption) *notion.SelectOption {
+	if option == nil {
+		return nil
+	}
+	return &notion.SelectOption{Name: option.Name}
+}
+
+func cachedUser(user *notion.User) *notion.User {
+	if user == nil {
+		return nil
+	}
+	return &notion.User{Id: user.Id, Name: user.Name}
+}
+
 func getTableName() string {
 	if tableName == "" {
 		t := os.Getenv("CACHE_TABLE_NAME")
@@ -96,3 +197,71 @@ func getTableName() string {
 	}
 	return tableName
 }
+
+// Key of the item caching Notion users, which can't clash with a database ID
+const USERS_KEY string = "#users"
+
+type UsersDTO struct {
+	Key   string        `dynamodbav:"database_id"`
+	Users []notion.User `dynamodbav:"users"`
+}
+
+func GetUsers(client dynamodbiface.DynamoDBAPI) (users []notion.User, err error) {
+	defer logging.LogFunction(
+		"persistence.GetUsers", time.Now(), "Getting users from DynamoDb",
+		map[string]interface{}{"table_name": getTableName()},
+	)
+
+	req := &dynamodb.GetItemInput{
+		TableName: aws.String(getTableName()),
+		Key:       map[string]*dynamodb.AttributeValue{"database_id": {S: aws.String(USERS_KEY)}},
+	}
+
+	output, err := client.GetItem(req)
+	if err != nil {
+		logging.GetLogger().Err(err).Send()
+		return []notion.User{}, err
+	}
+
+	dto := UsersDTO{Users: []notion.User{}}
+	if len(output.Item) > 0 {
+		err = dynamodbattribute.UnmarshalMap(output.Item, &dto)
+	}
+	return dto.Users, err
+}
+
+func PutUsers(client dynamodbiface.DynamoDBAPI, users []notion.User) error {
+	defer logging.LogFunction(
+		"persistence.PutUsers", time.Now(), "Putting users to DynamoDb",
+		map[string]interface{}{
+			"table_name": getTableName(),
+			"users":      len(users),
+		},
+	)
+
+	// Only the ID and name are needed to resolve users, so personal details like emails aren't stored
+	cached := make([]notion.User, len(users))
+	for i := range users {
+		cached[i] = *cachedUser(&users[i])
+	}
+
+	inputItem, err := dynamodbattribute.MarshalMap(UsersDTO{Key: USERS_KEY, Users: cached})
+	if err != nil {
+		logging.GetLogger().Err(err)
+		return fmt.Errorf("Unable to generate DynamoDb input: %w", err)
+	}
+
+	req := &dynamodb.PutItemInput{
+		Item:         inputItem,
+		ReturnValues: aws.String("NONE"),
+		TableName:    aws.String(getTableName()),
+	}
+
+	_, err = client.PutItem(req)
+	if err != nil {
+		logging.GetLogger().Err(err)
+		return fmt.Errorf("Error inserting to DynamoDb: %w", err)
+	}
+
+	return nil
+}
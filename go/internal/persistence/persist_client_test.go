@@ -1,7 +1,9 @@
 package persistence
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -77,6 +79,161 @@ func TestGetReturnsNotionPages(t *testing.T) {
 	assert.Equal(t, mockTimestamp, result.LastQuery)
 }
 
+func TestPutPagesKeepsTypedProperties(t *testing.T) {
+	// Arrange
+	title := "Initial goals"
+	rating := 4.5
+	read := true
+	page := notion.Page{
+		Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+		CreatedTime:    "2021-11-05T12:54:00.000Z",
+		LastEditedTime: "2021-11-05T12:55:00.000Z",
+		Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+		CreatedBy:      &notion.User{Object: "user", Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada Lovelace", AvatarUrl: "https://example.com/ada.png"},
+		Properties: map[string]notion.PropertyValue{
+			"Name": {
+				Id:    "title",
+				Type:  notion.PROPERTY_TITLE,
+				Title: []notion.RichText{{Type: "text", PlainText: title, Text: &notion.Text{Content: title}, Annotations: &notion.Annotations{Bold: true}}},
+			},
+			"Rating": {Id: "c%3Ad", Type: notion.PROPERTY_NUMBER, Number: &rating},
+			"Tags": {
+				Id:          "g%3Ah",
+				Type:        notion.PROPERTY_MULTI_SELECT,
+				MultiSelect: []notion.SelectOption{{Id: "2", Name: "go", Color: "red"}},
+			},
+			"Read":    {Id: "r%3Ad", Type: notion.PROPERTY_CHECKBOX, Checkbox: &read},
+			"Due":     {Id: "d%3Ae", Type: notion.PROPERTY_DATE, Date: &notion.DateValue{Start: "2021-12-01"}},
+			"Owner":   {Id: "o%3Aw", Type: notion.PROPERTY_PEOPLE, People: []notion.User{{Object: "user", Id: "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", Name: "Grace Hopper", Person: &notion.Person{Email: "grace@example.com"}}}},
+			"Related": {Id: "r%3Ae", Type: notion.PROPERTY_RELATION, Relation: []notion.ObjectRef{{Id: "5331da24-6597-4f2d-a684-fd94a0f3278a"}}},
+		},
+	}
+	dto := &NotionDTO{DatabaseId: databaseId, Pages: []notion.Page{page}, LastQuery: mockTimestamp}
+	mockClient := MockDynamoDb{}
+	mockClient.On("PutItem", mock.Anything).Run(func(args mock.Arguments) {
+		mockClient.MockDbContents = args.Get(0).(*dynamodb.PutItemInput).Item
+	})
+
+	// Act
+	err := PutPages(&mockClient, dto)
+	require.NoError(t, err)
+	result, err := GetPages(&mockClient, &databaseId)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, result.Pages, 1)
+	cached := result.Pages[0]
+	assert.Equal(t, title, cached.Title())
+	assert.ElementsMatch(t, propertyNames(page), propertyNames(cached))
+	assert.Equal(t, rating, cached.Properties["Rating"].Value())
+	assert.Equal(t, []notion.SelectOption{{Name: "go"}}, cached.Properties["Tags"].MultiSelect)
+	assert.Equal(t, true, cached.Properties["Read"].Value())
+	assert.Equal(t, "2021-12-01", cached.Properties["Due"].Date.Start)
+	assert.Equal(t, []notion.User{{Id: "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", Name: "Grace Hopper"}}, cached.Properties["Owner"].People)
+	assert.Equal(t, page.Properties["Related"].Relation, cached.Properties["Related"].Relation)
+	assert.Equal(t, &notion.User{Id: page.CreatedBy.Id, Name: "Ada Lovelace"}, cached.CreatedBy)
+	assert.Equal(t, page.Url, cached.Url)
+	assert.Equal(t, page.LastEditedTime, cached.LastEditedTime)
+	assert.NotNil(t, dto.Pages[0].Properties["Name"].Title[0].Annotations, "The pages in memory are left as they were")
+}
+
+func TestPutPagesShortensLongText(t *testing.T) {
+	// Arrange
+	summary := strings.Repeat("é", MAX_CACHED_TEXT_LENGTH+50)
+	page := notion.Page{Id: "3350ba04-48b1-43e3-8726-1b1e9828b2b3", Properties: map[string]notion.PropertyValue{
+		"Summary": {Type: notion.PROPERTY_RICH_TEXT, RichText: []notion.RichText{
+			{Type: "text", PlainText: summary[:100]},
+			{Type: "text", PlainText: summary[100:]},
+		}},
+	}}
+	mockClient := MockDynamoDb{}
+	mockClient.On("PutItem", mock.Anything).Run(func(args mock.Arguments) {
+		mockClient.MockDbContents = args.Get(0).(*dynamodb.PutItemInput).Item
+	})
+
+	// Act
+	err := PutPages(&mockClient, &NotionDTO{DatabaseId: databaseId, Pages: []notion.Page{page}})
+	require.NoError(t, err)
+	result, err := GetPages(&mockClient, &databaseId)
+
+	// Assert
+	require.NoError(t, err)
+	text := result.Pages[0].Properties["Summary"].RichText
+	require.Len(t, text, 1, "Segments are joined into one")
+	assert.Equal(t, strings.Repeat("é", MAX_CACHED_TEXT_LENGTH), text[0].PlainText)
+}
+
+func TestPutPagesFitsManyPagesInOneItem(t *testing.T) {
+	// Arrange: as many pages as cachedPage documents fitting
+	rating := 4.5
+	read := false
+	dto := &NotionDTO{DatabaseId: databaseId, LastQuery: mockTimestamp}
+	for i := 0; i < 700; i++ {
+		title := fmt.Sprintf("A reasonably long page title, number %d", i)
+		dto.Pages = append(dto.Pages, notion.Page{
+			Id:               fmt.Sprintf("3350ba04-48b1-43e3-8726-%012d", i),
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              fmt.Sprintf("https://www.notion.so/A-reasonably-long-page-title-3350ba0448b143e38726%012d", i),
+			CreatedBy:        &notion.User{Object: "user", Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed"},
+			SourceDatabaseId: databaseId,
+			Properties: map[string]notion.PropertyValue{
+				"Name":   {Id: "title", Type: notion.PROPERTY_TITLE, Title: []notion.RichText{{Type: "text", PlainText: title, Text: &notion.Text{Content: title}}}},
+				"Rating": {Id: "c%3Ad", Type: notion.PROPERTY_NUMBER, Number: &rating},
+				"Tags":   {Id: "g%3Ah", Type: notion.PROPERTY_MULTI_SELECT, MultiSelect: []notion.SelectOption{{Id: "2", Name: "go", Color: "red"}}},
+				"Read":   {Id: "r%3Ad", Type: notion.PROPERTY_CHECKBOX, Checkbox: &read},
+			},
+		})
+	}
+	mockClient := MockDynamoDb{}
+	var item map[string]*dynamodb.AttributeValue
+	mockClient.On("PutItem", mock.Anything).Run(func(args mock.Arguments) {
+		item = args.Get(0).(*dynamodb.PutItemInput).Item
+	})
+
+	// Act
+	err := PutPages(&mockClient, dto)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Less(t, itemSize(item), 400*1024, "DynamoDb rejects items over 400 KB")
+}
+
+func propertyNames(page notion.Page) []string {
+	names := []string{}
+	for name := range page.Properties {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Return roughly how DynamoDb sizes an item: the lengths of its attribute names and values
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeSize(value)
+	}
+	return size
+}
+
+func attributeSize(value *dynamodb.AttributeValue) int {
+	size := 1
+	switch {
+	case value.S != nil:
+		size = len(*value.S)
+	case value.N != nil:
+		size = len(*value.N)
+	case value.M != nil:
+		size = 3 + itemSize(value.M)
+	case value.L != nil:
+		size = 3
+		for _, element := range value.L {
+			size += 1 + attributeSize(element)
+		}
+	}
+	return size
+}
+
 func TestPutPagesCalled(t *testing.T) {
 	// Arrange
 	mockClient := MockDynamoDb{}
@@ -133,3 +290,41 @@ var testDataStruct *NotionDTO = &NotionDTO{
 	},
 	LastQuery: mockTimestamp,
 }
+
+func TestUsersRoundTrip(t *testing.T) {
+	// Arrange
+	users := []notion.User{{
+		Object: "user", Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Type: "person", Name: "Ada Lovelace",
+		Person: &notion.Person{Email: "ada@example.com"},
+	}}
+	mockClient := MockDynamoDb{}
+	var item map[string]*dynamodb.AttributeValue
+	mockClient.On("PutItem", mock.Anything).Run(func(args mock.Arguments) {
+		item = args.Get(0).(*dynamodb.PutItemInput).Item
+	})
+
+	// Act
+	err := PutUsers(&mockClient, users)
+	require.NoError(t, err)
+	mockClient.MockDbContents = item
+	result, err := GetUsers(&mockClient)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, USERS_KEY, *item["database_id"].S)
+	assert.Equal(t, []notion.User{{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada Lovelace"}}, result)
+	assert.NotContains(t, item["users"].String(), "ada@example.com", "Emails aren't stored")
+	assert.NotNil(t, users[0].Person, "The users passed in are left as they were")
+}
+
+func TestGetNoUsersFound(t *testing.T) {
+	// Arrange
+	mockClient := MockDynamoDb{MockDbContents: map[string]*dynamodb.AttributeValue{}}
+
+	// Act
+	result, err := GetUsers(&mockClient)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
@@ -0,0 +1,55 @@
+package persistence
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// Length of the keys NewTokenCipher accepts, for AES-256
+const TOKEN_KEY_LENGTH = 32
+
+// TokenCipher encrypts access tokens before they're stored in DynamoDb, with AES-GCM
+type TokenCipher struct {
+	aead cipher.AEAD
+}
+
+func NewTokenCipher(key []byte) (*TokenCipher, error) {
+	if len(key) != TOKEN_KEY_LENGTH {
+		return nil, fmt.Errorf("Unable to create token cipher: key is %d bytes, expected %d", len(key), TOKEN_KEY_LENGTH)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create token cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create token cipher: %w", err)
+	}
+	return &TokenCipher{aead: aead}, nil
+}
+
+// Encrypt token, binding it to owner (e.g. a workspace ID) so that it can't be
+// decrypted as anyone else's. The random nonce is prepended to the result
+func (c *TokenCipher) Seal(token string, owner string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("Unable to encrypt token: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, []byte(token), []byte(owner)), nil
+}
+
+// Decrypt a token encrypted by Seal for the same owner
+func (c *TokenCipher) Open(sealed []byte, owner string) (string, error) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", fmt.Errorf("Unable to decrypt token: too short")
+	}
+	token, err := c.aead.Open(nil, sealed[:size], sealed[size:], []byte(owner))
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt token: %w", err)
+	}
+	return string(token), nil
+}
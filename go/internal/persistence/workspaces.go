@@ -0,0 +1,121 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Prefix of the keys of items holding workspaces, which can't clash with a database ID
+const WORKSPACE_KEY_PREFIX string = "#workspace/"
+
+// A workspace that authorized the integration through OAuth
+type Workspace struct {
+	Id          string
+	Name        string
+	BotId       string
+	AccessToken string
+	DatabaseIds []string // Databases shared with the integration, to pick pages from
+	KeyHash     []byte   // SHA-256 of the secret part of the key that identifies callers from the workspace
+}
+
+// Workspace as stored in DynamoDb, with its access token encrypted
+type WorkspaceDTO struct {
+	Key            string   `dynamodbav:"database_id"`
+	WorkspaceId    string   `dynamodbav:"workspace_id"`
+	WorkspaceName  string   `dynamodbav:"workspace_name,omitempty"`
+	BotId          string   `dynamodbav:"bot_id"`
+	EncryptedToken []byte   `dynamodbav:"encrypted_token"`
+	DatabaseIds    []string `dynamodbav:"database_ids,omitempty"`
+	KeyHash        []byte   `dynamodbav:"key_hash"`
+	Authorized     int64    `dynamodbav:"authorized"`
+}
+
+// Return the workspace with the given ID, or nil if it hasn't authorized the integration
+func GetWorkspace(client dynamodbiface.DynamoDBAPI, tokens *TokenCipher, workspaceId string) (*Workspace, error) {
+	defer logging.LogFunction(
+		"persistence.GetWorkspace", time.Now(), "Getting workspace from DynamoDb",
+		map[string]interface{}{
+			"table_name":   getTableName(),
+			"workspace_id": workspaceId,
+		},
+	)
+
+	req := &dynamodb.GetItemInput{
+		TableName: aws.String(getTableName()),
+		Key:       map[string]*dynamodb.AttributeValue{"database_id": {S: aws.String(WORKSPACE_KEY_PREFIX + workspaceId)}},
+	}
+	output, err := client.GetItem(req)
+	if err != nil {
+		logging.GetLogger().Err(err).Send()
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	var dto WorkspaceDTO
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &dto); err != nil {
+		return nil, fmt.Errorf("Unable to read workspace %s: %w", workspaceId, err)
+	}
+	token, err := tokens.Open(dto.EncryptedToken, dto.WorkspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read workspace %s: %w", workspaceId, err)
+	}
+	return &Workspace{
+		Id:          dto.WorkspaceId,
+		Name:        dto.WorkspaceName,
+		BotId:       dto.BotId,
+		AccessToken: token,
+		DatabaseIds: dto.DatabaseIds,
+		KeyHash:     dto.KeyHash,
+	}, nil
+}
+
+// Store a workspace, replacing any earlier authorization of it
+func PutWorkspace(client dynamodbiface.DynamoDBAPI, tokens *TokenCipher, workspace Workspace) error {
+	defer logging.LogFunction(
+		"persistence.PutWorkspace", time.Now(), "Putting workspace to DynamoDb",
+		map[string]interface{}{
+			"table_name":   getTableName(),
+			"workspace_id": workspace.Id,
+			"databases":    len(workspace.DatabaseIds),
+		},
+	)
+
+	encrypted, err := tokens.Seal(workspace.AccessToken, workspace.Id)
+	if err != nil {
+		return err
+	}
+	inputItem, err := dynamodbattribute.MarshalMap(WorkspaceDTO{
+		Key:            WORKSPACE_KEY_PREFIX + workspace.Id,
+		WorkspaceId:    workspace.Id,
+		WorkspaceName:  workspace.Name,
+		BotId:          workspace.BotId,
+		EncryptedToken: encrypted,
+		DatabaseIds:    workspace.DatabaseIds,
+		KeyHash:        workspace.KeyHash,
+		Authorized:     time.Now().Unix(),
+	})
+	if err != nil {
+		logging.GetLogger().Err(err)
+		return fmt.Errorf("Unable to generate DynamoDb input: %w", err)
+	}
+
+	req := &dynamodb.PutItemInput{
+		Item:         inputItem,
+		ReturnValues: aws.String("NONE"),
+		TableName:    aws.String(getTableName()),
+	}
+	_, err = client.PutItem(req)
+	if err != nil {
+		logging.GetLogger().Err(err)
+		return fmt.Errorf("Error inserting to DynamoDb: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package persistence
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var mockTokenKey = bytes.Repeat([]byte{7}, TOKEN_KEY_LENGTH)
+
+func TestWorkspaceRoundTrip(t *testing.T) {
+	// Arrange
+	tokens, err := NewTokenCipher(mockTokenKey)
+	require.NoError(t, err)
+	workspace := Workspace{
+		Id:          "2f9d5a07-6a1c-4b8e-8e43-7f5c3b0a9d11",
+		Name:        "Ada's Notion",
+		BotId:       "b3414d65-1224-4a6c-9f4d-1c1d6a1a4b2e",
+		AccessToken: "secret_workspace",
+		DatabaseIds: []string{databaseId},
+		KeyHash:     []byte{1, 2, 3},
+	}
+	mockClient := &MockDynamoDb{}
+	var item map[string]*dynamodb.AttributeValue
+	mockClient.On("PutItem", mock.Anything).Run(func(args mock.Arguments) {
+		item = args.Get(0).(*dynamodb.PutItemInput).Item
+	})
+
+	// Act
+	err = PutWorkspace(mockClient, tokens, workspace)
+	require.NoError(t, err)
+	mockClient.MockDbContents = item
+	result, err := GetWorkspace(mockClient, tokens, workspace.Id)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, WORKSPACE_KEY_PREFIX+workspace.Id, *item["database_id"].S)
+	assert.NotContains(t, string(item["encrypted_token"].B), "secret_workspace")
+	assert.Equal(t, &workspace, result)
+}
+
+func TestGetNoWorkspaceFound(t *testing.T) {
+	// Arrange
+	tokens, _ := NewTokenCipher(mockTokenKey)
+	mockClient := &MockDynamoDb{MockDbContents: map[string]*dynamodb.AttributeValue{}}
+
+	// Act
+	result, err := GetWorkspace(mockClient, tokens, "2f9d5a07-6a1c-4b8e-8e43-7f5c3b0a9d11")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestTokenCipherIsBoundToOwner(t *testing.T) {
+	// Arrange
+	tokens, _ := NewTokenCipher(mockTokenKey)
+	sealed, err := tokens.Seal("secret_workspace", "ada")
+	require.NoError(t, err)
+
+	// Act
+	token, err := tokens.Open(sealed, "ada")
+	_, wrongOwnerErr := tokens.Open(sealed, "grace")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "secret_workspace", token)
+	assert.Error(t, wrongOwnerErr)
+}
+
+func TestTokenCipherRejectsShortKey(t *testing.T) {
+	_, err := NewTokenCipher([]byte("too short"))
+
+	assert.EqualError(t, err, "Unable to create token cipher: key is 9 bytes, expected 32")
+}
@@ -0,0 +1,341 @@
+// Package cassette records HTTP interactions with the Notion API to files,
+// and replays them offline so that tests run against real responses without network access
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Format of the cassette files written by Recorder; Load rejects any other
+const VERSION = 1
+
+// Replaces scrubbed values in cassettes
+const REDACTED = "REDACTED"
+
+// JSON fields scrubbed from request and response bodies unless Options.Fields is set:
+// credentials, and the personal content of users and pages
+var DEFAULT_SCRUBBED_FIELDS = []string{
+	"access_token", "refresh_token", "client_secret",
+	"email", "avatar_url", "workspace_name", "workspace_icon",
+	"plain_text", "content", "href", "title",
+}
+
+type Options struct {
+	// Values replaced wherever they appear in paths, queries and bodies, e.g. an integration token
+	Secrets []string
+	// JSON fields whose string values are replaced; DEFAULT_SCRUBBED_FIELDS if nil.
+	// The names of users, select options and files are always replaced, as are
+	// URLs outside Notion and the title slugs of Notion URLs
+	Fields []string
+}
+
+// ===============================================================
+// Cassette file format
+// ---------------------------------------------------------------
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"` // Encoded with its keys sorted
+	Body   json.RawMessage `json:"body,omitempty"`  // Normalized JSON
+}
+
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// ===============================================================
+
+// Response headers kept in cassettes, as the client relies on them
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// Matches the URL of a Notion page, which is kept without its slug so that it still identifies the page
+var notionUrl = regexp.MustCompile(`^https://[\w.-]*notion\.(?:so|site)/`)
+
+// Matches the title slug before the ID in a Notion URL, e.g. https://www.notion.so/Initial-goals-<id>
+var urlSlug = regexp.MustCompile(`(https://[\w.-]*notion\.(?:so|site)/(?:[^/?#\s"]+/)?)[^/?#\s"]*-([0-9a-f]{32})`)
+
+// Read a cassette file, checking it's in the current format
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("Unable to parse cassette %s: %w", path, err)
+	}
+	if c.Version != VERSION {
+		return nil, fmt.Errorf("Unable to load cassette %s: version %d, expected %d", path, c.Version, VERSION)
+	}
+	return &c, nil
+}
+
+// Write the cassette to path, creating its directory if needed
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("Unable to save cassette: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("Unable to save cassette: %w", err)
+	}
+	return nil
+}
+
+// Recorder passes requests on to a real transport, recording each interaction with secrets scrubbed
+type Recorder struct {
+	next     http.RoundTripper
+	opts     Options
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func NewRecorder(next http.RoundTripper, opts Options) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, opts: opts, cassette: Cassette{Version: VERSION, Interactions: []Interaction{}}}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := r.opts.request(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to record response: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	response := Response{Status: res.StatusCode, Headers: map[string]string{}, Body: r.opts.body(body)}
+	for _, key := range recordedHeaders {
+		if value := res.Header.Get(key); value != "" {
+			response.Headers[key] = value
+		}
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: request, Response: response})
+	r.mu.Unlock()
+	return res, nil
+}
+
+// Return the interactions recorded so far
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := Cassette{Version: r.cassette.Version, Interactions: append([]Interaction{}, r.cassette.Interactions...)}
+	return &c
+}
+
+// Replayer responds to requests from a cassette without any network access.
+// Requests match an interaction on method, path, query and normalized JSON body,
+// after scrubbing as when recording. Each interaction is replayed once, in order,
+// so that repeated requests (e.g. retries) get successive responses
+type Replayer struct {
+	opts     Options
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func NewReplayer(c *Cassette, opts Options) *Replayer {
+	return &Replayer{opts: opts, cassette: c, used: make([]bool, len(c.Interactions))}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := r.opts.request(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matches(interaction.Request, request) {
+			continue
+		}
+		r.used[i] = true
+		res := &http.Response{
+			Status:     fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode: interaction.Response.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       io.NopCloser(bytes.NewReader(replayed(interaction.Response.Body))),
+			Request:    req,
+		}
+		for key, value := range interaction.Response.Headers {
+			res.Header.Set(key, value)
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("No cassette interaction matches %s %s", request.Method, request.Path)
+}
+
+// Return whether every interaction has been replayed
+func (r *Replayer) Done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, used := range r.used {
+		if !used {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(recorded Request, request Request) bool {
+	return recorded.Method == request.Method &&
+		recorded.Path == request.Path &&
+		recorded.Query == request.Query &&
+		bytes.Equal(normalize(recorded.Body), request.Body)
+}
+
+// Return the scrubbed, normalized form of req, leaving its body readable
+func (o Options) request(req *http.Request) (Request, error) {
+	request := Request{
+		Method: req.Method,
+		Path:   o.scrubSecrets(req.URL.Path),
+		Query:  o.scrubSecrets(req.URL.Query().Encode()),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return request, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return request, fmt.Errorf("Unable to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	request.Body = o.body(body)
+	return request, nil
+}
+
+// Return body scrubbed and normalized, with object keys sorted and insignificant whitespace removed.
+// Bodies that aren't JSON are kept as JSON strings
+func (o Options) body(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		encoded, _ := json.Marshal(o.scrubSecrets(string(body)))
+		return encoded
+	}
+	encoded, _ := json.Marshal(o.scrub(value, ""))
+	return encoded
+}
+
+func (o Options) scrub(value interface{}, key string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == "name" && named(v) {
+				if _, ok := child.(string); ok {
+					v[k] = REDACTED
+					continue
+				}
+			}
+			v[k] = o.scrub(child, k)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = o.scrub(child, key)
+		}
+		return v
+	case string:
+		if o.scrubbed(key) || (key == "url" && !notionUrl.MatchString(v)) {
+			return REDACTED
+		}
+		return urlSlug.ReplaceAllString(o.scrubSecrets(v), "$1$2")
+	}
+	return value
+}
+
+// Return whether the name of object is personal content: the name of a user,
+// a select option (which has a color) or a file (which is hosted by Notion or external)
+func named(object map[string]interface{}) bool {
+	if object["object"] == "user" {
+		return true
+	}
+	if _, ok := object["color"]; ok {
+		return true
+	}
+	return object["type"] == "file" || object["type"] == "external"
+}
+
+func (o Options) scrubbed(key string) bool {
+	fields := o.Fields
+	if fields == nil {
+		fields = DEFAULT_SCRUBBED_FIELDS
+	}
+	for _, field := range fields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) scrubSecrets(s string) string {
+	for _, secret := range o.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, REDACTED)
+		}
+	}
+	return s
+}
+
+// Return a recorded body as it was sent: compact JSON, or the text of a body that wasn't JSON
+func replayed(raw json.RawMessage) []byte {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []byte(text)
+	}
+	return normalize(raw)
+}
+
+// Return raw JSON in the normalized form Options.body produces
+func normalize(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return raw
+	}
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
@@ -0,0 +1,166 @@
+package cassette
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockToken = "secret_abc123"
+
+func mockServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "not-recorded")
+		w.Write([]byte(`{
+			"object": "list",
+			"results": [{
+				"object": "page",
+				"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				"created_by": {"object": "user", "id": "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", "name": "Ada Lovelace", "person": {"email": "ada@example.com"}},
+				"properties": {"Name": {"type": "title", "title": [{"type": "text", "text": {"content": "Diary"}, "plain_text": "Diary"}]}}
+			}],
+			"next_cursor": null,
+			"has_more": false
+		}`))
+	}))
+}
+
+func query(t *testing.T, transport http.RoundTripper, url string, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url+"/databases/99999999abcdefgh1234000000000000/query?token="+mockToken, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+mockToken)
+	res, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	return res
+}
+
+func TestRecordScrubsSecretsAndPersonalContent(t *testing.T) {
+	// Arrange
+	ts := mockServer()
+	defer ts.Close()
+	recorder := NewRecorder(http.DefaultTransport, Options{Secrets: []string{mockToken}})
+
+	// Act
+	res := query(t, recorder, ts.URL, `{"page_size": 100, "filter": {"property": "Name", "rich_text": {"contains": "Diary"}}}`)
+	body, _ := io.ReadAll(res.Body)
+	saved, err := json.Marshal(recorder.Cassette())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Ada Lovelace", "The response itself is passed on unchanged")
+	interaction := recorder.Cassette().Interactions[0]
+	assert.Equal(t, "token=REDACTED", interaction.Request.Query)
+	assert.JSONEq(t, `{"filter": {"property": "Name", "rich_text": {"contains": "Diary"}}, "page_size": 100}`, string(interaction.Request.Body))
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, interaction.Response.Headers)
+	assert.NotContains(t, string(saved), mockToken)
+	for _, personal := range []string{"Ada Lovelace", "ada@example.com", "Diary"} {
+		assert.NotContains(t, string(interaction.Response.Body), personal)
+	}
+}
+
+func TestReplayRecordedCassette(t *testing.T) {
+	// Arrange
+	ts := mockServer()
+	recorder := NewRecorder(http.DefaultTransport, Options{Secrets: []string{mockToken}})
+	query(t, recorder, ts.URL, `{"page_size": 100}`)
+	ts.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "query.json")
+	require.NoError(t, recorder.Cassette().Save(path))
+	c, err := Load(path)
+	require.NoError(t, err)
+	replayer := NewReplayer(c, Options{Secrets: []string{mockToken}})
+
+	// Act: the server is gone, and the body is formatted differently
+	res := query(t, replayer, "http://localhost:0", "{\n  \"page_size\": 100\n}")
+	body, _ := io.ReadAll(res.Body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `"has_more":false`)
+	assert.True(t, replayer.Done())
+}
+
+func TestReplayRejectsUnmatchedRequests(t *testing.T) {
+	// Arrange
+	replayer := NewReplayer(&Cassette{Version: VERSION, Interactions: []Interaction{{
+		Request:  Request{Method: http.MethodPost, Path: "/databases/99999999abcdefgh1234000000000000/query", Query: "token=REDACTED", Body: json.RawMessage(`{"page_size":100}`)},
+		Response: Response{Status: http.StatusOK, Body: json.RawMessage(`{}`)},
+	}}}, Options{Secrets: []string{mockToken}})
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:0/databases/99999999abcdefgh1234000000000000/query?token="+mockToken, strings.NewReader(`{"page_size": 50}`))
+
+	// Act
+	_, err := replayer.RoundTrip(req)
+
+	// Assert
+	assert.EqualError(t, err, "No cassette interaction matches POST /databases/99999999abcdefgh1234000000000000/query")
+	assert.False(t, replayer.Done())
+}
+
+func TestLoadRejectsOtherVersions(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "old.json")
+	require.NoError(t, (&Cassette{Version: VERSION + 1}).Save(path))
+
+	// Act
+	_, err := Load(path)
+
+	// Assert
+	assert.EqualError(t, err, "Unable to load cassette "+path+": version 2, expected 1")
+}
+
+func TestRecordScrubsPageContent(t *testing.T) {
+	// Arrange
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"object": "page",
+			"id": "5331da24-6597-4f2d-a684-fd94a0f3278a",
+			"url": "https://www.notion.so/Chicken-korma-recipe-5331da2465974f2da684fd94a0f3278a",
+			"public_url": "https://jeff.notion.site/Chicken-korma-recipe-5331da2465974f2da684fd94a0f3278a",
+			"properties": {
+				"Name": {"id": "title", "type": "title", "title": [{
+					"type": "text",
+					"text": {"content": "Chicken korma", "link": {"url": "https://swasthisrecipes.com/chicken-korma"}},
+					"annotations": {"bold": false, "color": "default"},
+					"plain_text": "Chicken korma",
+					"href": "https://swasthisrecipes.com/chicken-korma"
+				}]},
+				"Tags": {"id": "Tg%3D", "type": "multi_select", "multi_select": [{"id": "a1b2", "name": "Dinner party", "color": "red"}]},
+				"Status": {"id": "St%3D", "type": "status", "status": {"id": "c3d4", "name": "Cooked for Sam", "color": "green"}},
+				"Photos": {"id": "Ph%3D", "type": "files", "files": [
+					{"name": "korma.jpg", "type": "file", "file": {"url": "https://prod-files-secure.s3.us-west-2.amazonaws.com/korma.jpg", "expiry_time": "2021-11-05T13:54:00.000Z"}},
+					{"name": "Swasthi's photo", "type": "external", "external": {"url": "https://swasthisrecipes.com/korma.jpg"}}
+				]},
+				"Related": {"id": "Rl%3D", "type": "relation", "relation": [{"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3"}]}
+			}
+		}`))
+	}))
+	defer ts.Close()
+	recorder := NewRecorder(http.DefaultTransport, Options{})
+
+	// Act
+	res, err := recorder.RoundTrip(httptest.NewRequest(http.MethodGet, ts.URL+"/pages/5331da24-6597-4f2d-a684-fd94a0f3278a", nil))
+	require.NoError(t, err)
+	res.Body.Close()
+
+	// Assert
+	body := string(recorder.Cassette().Interactions[0].Response.Body)
+	for _, personal := range []string{"Chicken", "korma.jpg", "Dinner party", "Cooked for Sam", "Swasthi's photo"} {
+		assert.NotContains(t, body, personal)
+	}
+	assert.Contains(t, body, `"url":"https://www.notion.so/5331da2465974f2da684fd94a0f3278a"`, "Notion URLs keep their IDs")
+	assert.Contains(t, body, `"public_url":"https://jeff.notion.site/5331da2465974f2da684fd94a0f3278a"`)
+	assert.NotContains(t, body, "swasthisrecipes.com", "URLs outside Notion are replaced")
+	assert.Contains(t, body, `"Tags":{`, "Property names are kept")
+	assert.Contains(t, body, `"color":"red"`)
+	assert.Contains(t, body, `"id":"3350ba04-48b1-43e3-8726-1b1e9828b2b3"`)
+}
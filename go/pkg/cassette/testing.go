@@ -0,0 +1,38 @@
+package cassette
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+// Set to record cassettes against the real Notion API instead of replaying them
+const RECORD_ENV = "RECORD_CASSETTES"
+
+// Return a transport for a test that replays the cassette at path, or if RECORD_ENV is set,
+// records a new one through http.DefaultTransport and saves it when the test finishes.
+// The test fails if replayed interactions are left over
+func Transport(t testing.TB, path string, opts Options) http.RoundTripper {
+	t.Helper()
+	if os.Getenv(RECORD_ENV) != "" {
+		recorder := NewRecorder(http.DefaultTransport, opts)
+		t.Cleanup(func() {
+			if err := recorder.Cassette().Save(path); err != nil {
+				t.Error(err)
+			}
+		})
+		return recorder
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := NewReplayer(c, opts)
+	t.Cleanup(func() {
+		if !t.Failed() && !replayer.Done() {
+			t.Errorf("Cassette %s has interactions that weren't replayed", path)
+		}
+	})
+	return replayer
+}
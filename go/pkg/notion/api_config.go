@@ -1,21 +1,105 @@
 package notion
 
+import (
+	"net/http"
+	"time"
+)
+
 const API_URI = "https://api.notion.com/v1"
 const ISO_TIME = "2006-01-02T15:04:05-0700"
 const DEFAULT_PAGE_SIZE = uint8(100)
+const DEFAULT_DEADLINE_BUFFER = 750 * time.Millisecond
+const DEFAULT_SYNC_OVERLAP = 2 * time.Minute
+const DEFAULT_MAX_PAGES = 10000
+
+// Notion API versions, per https://developers.notion.com/reference/changes-by-version
+const (
+	VERSION_2021_08_16 = "2021-08-16"
+	VERSION_2022_06_28 = "2022-06-28"
+	VERSION_2025_09_03 = "2025-09-03" // Databases are split into data sources
+)
+const DEFAULT_NOTION_VERSION = VERSION_2021_08_16
 
 type ApiConfig struct {
 	Url         string
 	DatabaseId  string
+	DatabaseIds []string // Further databases to retrieve pages from along with DatabaseId
 	SecretToken string
 	PageSize    uint8
+	Version     string // Notion-Version header; DEFAULT_NOTION_VERSION if empty
+	// Data sources of DatabaseId to query on versions that have them.
+	// If empty, every data source of the database is queried
+	DataSourceIds []string
+	// Time reserved before a context deadline, during which no further
+	// requests are made so that callers can still respond with partial results
+	DeadlineBuffer time.Duration
+	Retry          RetryPolicy
+	// Shared by every request made with this config, including copies of it;
+	// requests are not limited if nil
+	RateLimiter *RateLimiter
+	// Users already retrieved, shared by copies of this config; users aren't cached if nil
+	Users *UserCache
+	// Data sources of the databases already retrieved, shared by copies of this config;
+	// the database is retrieved on every query if nil
+	DataSources *DataSourceCache
+	Parallelism int     // Most databases queried at once; DEFAULT_PARALLELISM if zero
+	Filter      *Filter // Applied to every database query, e.g. to skip archived content
+	Sorts       []Sort  // Default order for database queries
+	// Date property GetPagesSinceTime filters on; DEFAULT_CREATED_PROPERTY if empty.
+	// Checked by ValidateSchema, which falls back to the built-in created_time if it's missing
+	CreatedProperty string
+	// How far before the last sync to look for edited pages; DEFAULT_SYNC_OVERLAP if zero
+	SyncOverlap time.Duration
+	// Most pages a query or search retrieves before stopping with a *PartialResultError,
+	// so that a sync ends even if Notion keeps returning pages; DEFAULT_MAX_PAGES if zero
+	MaxPages int
+	// Whether queries retrieve the complete values of properties Notion truncates,
+	// at a further request for each truncated property of each page.
+	// Properties that can't be retrieved are left truncated
+	CompleteTruncated bool
+	// Client used to send requests; DefaultHttpClient if nil.
+	// Set its Transport to replace the underlying http.RoundTripper
+	HttpClient  *http.Client
+	Middlewares []Middleware // Applied to each attempt, the first being outermost
+	UserAgent   string       // DEFAULT_USER_AGENT if empty
+
+	// Type of CreatedProperty per normalized database ID, as found by ValidateSchema.
+	// Shared by copies, so that those made by WithDatabase use the validated types
+	createdTypes map[string]string
 }
 
 func NewApiConfig() *ApiConfig {
 	return &ApiConfig{
-		Url:         API_URI,
-		DatabaseId:  "",
-		SecretToken: "",
-		PageSize:    DEFAULT_PAGE_SIZE,
+		Url:            API_URI,
+		DatabaseId:     "",
+		SecretToken:    "",
+		PageSize:       DEFAULT_PAGE_SIZE,
+		Version:        DEFAULT_NOTION_VERSION,
+		DeadlineBuffer: DEFAULT_DEADLINE_BUFFER,
+		Retry:          DefaultRetryPolicy(),
+		RateLimiter:    NewRateLimiter(DEFAULT_RATE_LIMIT, DEFAULT_RATE_BURST),
+		Users:          NewUserCache(),
+		DataSources:    NewDataSourceCache(),
+		createdTypes:   map[string]string{},
+	}
+}
+
+func (api *ApiConfig) maxPages() int {
+	if api.MaxPages <= 0 {
+		return DEFAULT_MAX_PAGES
 	}
+	return api.MaxPages
+}
+
+func (api *ApiConfig) version() string {
+	if api.Version == "" {
+		return DEFAULT_NOTION_VERSION
+	}
+	return api.Version
+}
+
+// Return whether the configured API version queries data sources rather than databases.
+// Versions are dates, so they compare correctly as strings
+func (api *ApiConfig) usesDataSources() bool {
+	return api.version() >= VERSION_2025_09_03
 }
@@ -0,0 +1,324 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+const MAX_BLOCK_DEPTH = 8
+
+// Notion block types, per https://developers.notion.com/reference/block
+const (
+	BLOCK_PARAGRAPH          = "paragraph"
+	BLOCK_HEADING_1          = "heading_1"
+	BLOCK_HEADING_2          = "heading_2"
+	BLOCK_HEADING_3          = "heading_3"
+	BLOCK_BULLETED_LIST_ITEM = "bulleted_list_item"
+	BLOCK_NUMBERED_LIST_ITEM = "numbered_list_item"
+	BLOCK_TO_DO              = "to_do"
+	BLOCK_TOGGLE             = "toggle"
+	BLOCK_QUOTE              = "quote"
+	BLOCK_CALLOUT            = "callout"
+	BLOCK_CODE               = "code"
+	BLOCK_EQUATION           = "equation"
+	BLOCK_DIVIDER            = "divider"
+	BLOCK_IMAGE              = "image"
+	BLOCK_VIDEO              = "video"
+	BLOCK_FILE               = "file"
+	BLOCK_PDF                = "pdf"
+	BLOCK_BOOKMARK           = "bookmark"
+	BLOCK_EMBED              = "embed"
+	BLOCK_LINK_PREVIEW       = "link_preview"
+	BLOCK_TABLE              = "table"
+	BLOCK_TABLE_ROW          = "table_row"
+	BLOCK_COLUMN_LIST        = "column_list"
+	BLOCK_COLUMN             = "column"
+	BLOCK_SYNCED_BLOCK       = "synced_block"
+	BLOCK_CHILD_PAGE         = "child_page"
+	BLOCK_CHILD_DATABASE     = "child_database"
+)
+
+// ===============================================================
+// Notion block objects. Only the field matching Type is set
+// ---------------------------------------------------------------
+type Block struct {
+	Object         string `json:"object,omitempty"`
+	Id             string `json:"id,omitempty"`
+	Type           string `json:"type"`
+	CreatedTime    string `json:"created_time,omitempty"`
+	LastEditedTime string `json:"last_edited_time,omitempty"`
+	HasChildren    bool   `json:"has_children,omitempty"`
+	Archived       bool   `json:"archived,omitempty"`
+
+	Paragraph        *TextBlock      `json:"paragraph,omitempty"`
+	Heading1         *HeadingBlock   `json:"heading_1,omitempty"`
+	Heading2         *HeadingBlock   `json:"heading_2,omitempty"`
+	Heading3         *HeadingBlock   `json:"heading_3,omitempty"`
+	BulletedListItem *TextBlock      `json:"bulleted_list_item,omitempty"`
+	NumberedListItem *TextBlock      `json:"numbered_list_item,omitempty"`
+	ToDo             *ToDoBlock      `json:"to_do,omitempty"`
+	Toggle           *TextBlock      `json:"toggle,omitempty"`
+	Quote            *TextBlock      `json:"quote,omitempty"`
+	Callout          *CalloutBlock   `json:"callout,omitempty"`
+	Code             *CodeBlock      `json:"code,omitempty"`
+	Equation         *Equation       `json:"equation,omitempty"`
+	Divider          *struct{}       `json:"divider,omitempty"`
+	Image            *FileBlock      `json:"image,omitempty"`
+	Video            *FileBlock      `json:"video,omitempty"`
+	File             *FileBlock      `json:"file,omitempty"`
+	Pdf              *FileBlock      `json:"pdf,omitempty"`
+	Bookmark         *LinkBlock      `json:"bookmark,omitempty"`
+	Embed            *LinkBlock      `json:"embed,omitempty"`
+	LinkPreview      *LinkBlock      `json:"link_preview,omitempty"`
+	Table            *TableBlock     `json:"table,omitempty"`
+	TableRow         *TableRowBlock  `json:"table_row,omitempty"`
+	ColumnList       *struct{}       `json:"column_list,omitempty"`
+	Column           *struct{}       `json:"column,omitempty"`
+	SyncedBlock      *SyncedBlock    `json:"synced_block,omitempty"`
+	ChildPage        *ChildPageBlock `json:"child_page,omitempty"`
+	ChildDatabase    *ChildPageBlock `json:"child_database,omitempty"`
+
+	// Populated by GetBlockChildren for blocks that have children
+	Children []Block `json:"children,omitempty"`
+}
+
+// Paragraphs, list items, toggles and quotes
+type TextBlock struct {
+	RichText []RichText `json:"rich_text"`
+	Color    string     `json:"color,omitempty"`
+}
+
+type HeadingBlock struct {
+	RichText     []RichText `json:"rich_text"`
+	Color        string     `json:"color,omitempty"`
+	IsToggleable bool       `json:"is_toggleable,omitempty"`
+}
+
+type ToDoBlock struct {
+	RichText []RichText `json:"rich_text"`
+	Checked  bool       `json:"checked"`
+	Color    string     `json:"color,omitempty"`
+}
+
+type CalloutBlock struct {
+	RichText []RichText `json:"rich_text"`
+	Icon     *Icon      `json:"icon,omitempty"`
+	Color    string     `json:"color,omitempty"`
+}
+
+type Icon struct {
+	Type     string        `json:"type"` // "emoji", "external" or "file"
+	Emoji    string        `json:"emoji,omitempty"`
+	External *ExternalFile `json:"external,omitempty"`
+	File     *HostedFile   `json:"file,omitempty"`
+}
+
+type CodeBlock struct {
+	RichText []RichText `json:"rich_text"`
+	Caption  []RichText `json:"caption,omitempty"`
+	Language string     `json:"language"`
+}
+
+// Images, videos, files and PDFs, either hosted by Notion or external
+type FileBlock struct {
+	Type     string        `json:"type"` // "file" or "external"
+	File     *HostedFile   `json:"file,omitempty"`
+	External *ExternalFile `json:"external,omitempty"`
+	Caption  []RichText    `json:"caption,omitempty"`
+	Name     string        `json:"name,omitempty"`
+}
+
+// Return the URL the file can be downloaded from
+func (f FileBlock) Url() string {
+	if f.External != nil {
+		return f.External.Url
+	}
+	if f.File != nil {
+		return f.File.Url
+	}
+	return ""
+}
+
+// Bookmarks, embeds and link previews
+type LinkBlock struct {
+	Url     string     `json:"url"`
+	Caption []RichText `json:"caption,omitempty"`
+}
+
+type TableBlock struct {
+	TableWidth      int  `json:"table_width"`
+	HasColumnHeader bool `json:"has_column_header"`
+	HasRowHeader    bool `json:"has_row_header"`
+}
+
+type TableRowBlock struct {
+	Cells [][]RichText `json:"cells"`
+}
+
+// A synced block is an original when SyncedFrom is nil,
+// otherwise a duplicate whose children mirror the original's
+type SyncedBlock struct {
+	SyncedFrom *SyncedFrom `json:"synced_from"`
+}
+
+type SyncedFrom struct {
+	Type    string `json:"type"`
+	BlockId string `json:"block_id"`
+}
+
+type ChildPageBlock struct {
+	Title string `json:"title"`
+}
+
+// ===============================================================
+
+// Build a paragraph block of plain text, e.g. for the content of a new page.
+// Text longer than Notion allows in one rich text object is split across several
+func NewParagraph(content string) Block {
+	richText := []RichText{}
+	runes := []rune(content)
+	for len(runes) > MAX_TEXT_LENGTH {
+		richText = append(richText, NewRichText(string(runes[:MAX_TEXT_LENGTH])))
+		runes = runes[MAX_TEXT_LENGTH:]
+	}
+	if len(runes) > 0 {
+		richText = append(richText, NewRichText(string(runes)))
+	}
+	return Block{
+		Object:    "block",
+		Type:      BLOCK_PARAGRAPH,
+		Paragraph: &TextBlock{RichText: richText},
+	}
+}
+
+// Return the rich text content of the block, if its type has any
+func (b Block) RichText() []RichText {
+	switch {
+	case b.Paragraph != nil:
+		return b.Paragraph.RichText
+	case b.Heading1 != nil:
+		return b.Heading1.RichText
+	case b.Heading2 != nil:
+		return b.Heading2.RichText
+	case b.Heading3 != nil:
+		return b.Heading3.RichText
+	case b.BulletedListItem != nil:
+		return b.BulletedListItem.RichText
+	case b.NumberedListItem != nil:
+		return b.NumberedListItem.RichText
+	case b.ToDo != nil:
+		return b.ToDo.RichText
+	case b.Toggle != nil:
+		return b.Toggle.RichText
+	case b.Quote != nil:
+		return b.Quote.RichText
+	case b.Callout != nil:
+		return b.Callout.RichText
+	case b.Code != nil:
+		return b.Code.RichText
+	}
+	return nil
+}
+
+type ContentGetter interface {
+	GetPageContent(context.Context, string) ([]Block, error)
+}
+
+type blockResponse struct {
+	Object  string  `json:"object"`
+	Results []Block `json:"results"`
+	Next    string  `json:"next_cursor"`
+	HasMore bool    `json:"has_more"`
+}
+
+// Return the content of a page as a tree of blocks
+func (api *ApiConfig) GetPageContent(ctx context.Context, pageId string) ([]Block, error) {
+	defer logging.LogFunction(
+		"blocks.GetPageContent", time.Now(), "Getting page content",
+		map[string]interface{}{
+			"page_id": pageId,
+		},
+	)
+	return api.GetBlockChildren(ctx, pageId)
+}
+
+// Return the children of a block, recursively populating
+// the Children of any that have their own
+func (api *ApiConfig) GetBlockChildren(ctx context.Context, blockId string) ([]Block, error) {
+	return api.getBlockChildren(ctx, blockId, 1)
+}
+
+func (api *ApiConfig) getBlockChildren(ctx context.Context, blockId string, depth int) ([]Block, error) {
+	logger := logging.GetLoggerWithContext(ctx)
+	blocks := []Block{}
+	cursor := ""
+	hasMore := true
+	seen := cursors{}
+
+	for hasMore {
+		response, err := api.queryBlockChildren(ctx, blockId, cursor)
+		if err == nil {
+			err = seen.check(response.HasMore, response.Next)
+		}
+		if err != nil {
+			logger.Err(err).Str("block_id", blockId).Msg("Unable to retrieve block children")
+			return nil, err
+		}
+		blocks = append(blocks, response.Results...)
+		hasMore = response.HasMore
+		cursor = response.Next
+	}
+
+	for i := range blocks {
+		block := &blocks[i]
+		// Child pages and databases are separate documents rather than nested content
+		if !block.HasChildren || block.ChildPage != nil || block.ChildDatabase != nil {
+			continue
+		}
+		if depth >= MAX_BLOCK_DEPTH {
+			logger.Warn().
+				Str("block_id", block.Id).
+				Int("depth", depth).
+				Msg("Not retrieving children nested beyond the maximum depth")
+			continue
+		}
+		children, err := api.getBlockChildren(ctx, block.Id, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		block.Children = children
+	}
+
+	return blocks, nil
+}
+
+func (api *ApiConfig) queryBlockChildren(ctx context.Context, blockId string, cursor string) (blockResponse, error) {
+	params := url.Values{}
+	if api.PageSize > 0 {
+		params.Set("page_size", strconv.Itoa(int(api.PageSize)))
+	}
+	if cursor != "" {
+		params.Set("start_cursor", cursor)
+	}
+
+	body, err := api.do(ctx, apiRequest{
+		Method:     "GET",
+		Path:       fmt.Sprintf("/blocks/%s/children?%s", blockId, params.Encode()),
+		Idempotent: true,
+	})
+	if err != nil {
+		return blockResponse{}, err
+	}
+	logging.GetLoggerWithContext(ctx).Trace().RawJSON("block_response_json", body).Msg("Receieved Notion API response")
+
+	var blockResponse blockResponse
+	if err := decodeResponse(body, "list", &blockResponse); err != nil {
+		return blockResponse, err
+	}
+	return blockResponse, nil
+}
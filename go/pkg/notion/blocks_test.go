@@ -0,0 +1,183 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockPageId = "3350ba04-48b1-43e3-8726-1b1e9828b2b3"
+const mockToggleId = "a1b2c3d4-0000-0000-0000-000000000001"
+const mockChildPageId = "a1b2c3d4-0000-0000-0000-000000000002"
+
+// Serve block children by block ID and start cursor
+func mockNotionBlockServer(responses map[string]string) (*httptest.Server, *ApiConfig) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if notionHeaderIsValid(w, r) {
+			key := fmt.Sprintf("%s?%s", r.URL.Path, r.URL.Query().Get("start_cursor"))
+			response, ok := responses[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Not found"}`))
+				return
+			}
+			w.Write([]byte(response))
+		}
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		DatabaseId:  mockDatabaseId,
+		SecretToken: mockApiToken,
+		PageSize:    DEFAULT_PAGE_SIZE,
+	}
+
+	return server, api
+}
+
+func TestRetrievePageContent(t *testing.T) {
+	responses := map[string]string{
+		"/blocks/" + mockPageId + "/children?": `{
+			"object": "list",
+			"results": [
+				{
+					"object": "block",
+					"id": "a1b2c3d4-0000-0000-0000-000000000000",
+					"type": "heading_1",
+					"has_children": false,
+					"heading_1": {"rich_text": [{"type": "text", "text": {"content": "Goals"}, "plain_text": "Goals"}], "is_toggleable": false, "color": "default"}
+				},
+				{
+					"object": "block",
+					"id": "` + mockToggleId + `",
+					"type": "toggle",
+					"has_children": true,
+					"toggle": {"rich_text": [{"type": "text", "text": {"content": "Details"}, "plain_text": "Details"}], "color": "default"}
+				}
+			],
+			"next_cursor": "` + mockCursor + `",
+			"has_more": true
+		}`,
+		"/blocks/" + mockPageId + "/children?" + mockCursor: `{
+			"object": "list",
+			"results": [
+				{
+					"object": "block",
+					"id": "` + mockChildPageId + `",
+					"type": "child_page",
+					"has_children": true,
+					"child_page": {"title": "Subpage"}
+				},
+				{
+					"object": "block",
+					"id": "a1b2c3d4-0000-0000-0000-000000000003",
+					"type": "code",
+					"has_children": false,
+					"code": {"rich_text": [{"type": "text", "text": {"content": "fmt.Println()"}, "plain_text": "fmt.Println()"}], "caption": [], "language": "go"}
+				}
+			],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+		"/blocks/" + mockToggleId + "/children?": `{
+			"object": "list",
+			"results": [
+				{
+					"object": "block",
+					"id": "a1b2c3d4-0000-0000-0000-000000000004",
+					"type": "to_do",
+					"has_children": false,
+					"to_do": {"rich_text": [{"type": "text", "text": {"content": "Write tests"}, "plain_text": "Write tests"}], "checked": true, "color": "default"}
+				}
+			],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+	}
+
+	ts, api := mockNotionBlockServer(responses)
+	defer ts.Close()
+
+	blocks, err := api.GetPageContent(context.Background(), mockPageId)
+
+	require.NoError(t, err)
+	require.Len(t, blocks, 4)
+	assert.Equal(t, BLOCK_HEADING_1, blocks[0].Type)
+	assert.Equal(t, "Goals", PlainText(blocks[0].RichText()))
+	assert.Equal(t, BLOCK_TOGGLE, blocks[1].Type)
+	if assert.Len(t, blocks[1].Children, 1) {
+		assert.Equal(t, BLOCK_TO_DO, blocks[1].Children[0].Type)
+		assert.True(t, blocks[1].Children[0].ToDo.Checked)
+	}
+	assert.Equal(t, "Subpage", blocks[2].ChildPage.Title)
+	assert.Nil(t, blocks[2].Children) // Subpages aren't retrieved as nested content
+	assert.Equal(t, "go", blocks[3].Code.Language)
+}
+
+func TestRetrievePageContentNotFound(t *testing.T) {
+	ts, api := mockNotionBlockServer(map[string]string{})
+	defer ts.Close()
+
+	blocks, err := api.GetPageContent(context.Background(), mockPageId)
+
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	assert.Nil(t, blocks)
+}
+
+func TestDecodeBlockTypes(t *testing.T) {
+	mockData := `[
+		{"object": "block", "id": "1", "type": "image", "image": {"type": "external", "external": {"url": "https://example.com/cat.png"}, "caption": [{"type": "text", "text": {"content": "A cat"}, "plain_text": "A cat"}]}},
+		{"object": "block", "id": "2", "type": "callout", "callout": {"rich_text": [], "icon": {"type": "emoji", "emoji": "💡"}, "color": "gray_background"}},
+		{"object": "block", "id": "3", "type": "table_row", "table_row": {"cells": [[{"type": "text", "text": {"content": "a"}, "plain_text": "a"}], []]}},
+		{"object": "block", "id": "4", "type": "synced_block", "has_children": true, "synced_block": {"synced_from": {"type": "block_id", "block_id": "5"}}},
+		{"object": "block", "id": "6", "type": "column_list", "has_children": true, "column_list": {}},
+		{"object": "block", "id": "7", "type": "bookmark", "bookmark": {"url": "https://example.com", "caption": []}}
+	]`
+
+	var blocks []Block
+	require.NoError(t, json.Unmarshal([]byte(mockData), &blocks))
+
+	assert.Equal(t, "https://example.com/cat.png", blocks[0].Image.Url())
+	assert.Equal(t, "A cat", PlainText(blocks[0].Image.Caption))
+	assert.Equal(t, "💡", blocks[1].Callout.Icon.Emoji)
+	assert.Len(t, blocks[2].TableRow.Cells, 2)
+	assert.Equal(t, "5", blocks[3].SyncedBlock.SyncedFrom.BlockId)
+	assert.NotNil(t, blocks[4].ColumnList)
+	assert.Equal(t, "https://example.com", blocks[5].Bookmark.Url)
+}
+
+func TestNewParagraphSplitsLongText(t *testing.T) {
+	content := strings.Repeat("é", MAX_TEXT_LENGTH) + "and more"
+
+	block := NewParagraph(content)
+
+	require.Len(t, block.Paragraph.RichText, 2)
+	assert.Equal(t, MAX_TEXT_LENGTH, len([]rune(block.Paragraph.RichText[0].Text.Content)))
+	assert.Equal(t, "and more", block.Paragraph.RichText[1].Text.Content)
+	assert.Equal(t, content, PlainText(block.RichText()))
+}
+
+func TestRetrievePageContentRejectsMissingCursor(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{
+		"object": "list",
+		"results": [{"object": "block", "id": "a1b2c3", "type": "divider", "divider": {}}],
+		"next_cursor": null,
+		"has_more": true
+	}`, http.StatusOK)
+	defer ts.Close()
+
+	// Act
+	blocks, err := api.GetPageContent(context.Background(), "3350ba04-48b1-43e3-8726-1b1e9828b2b3")
+
+	// Assert
+	assert.Nil(t, blocks)
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+}
@@ -0,0 +1,39 @@
+package notion
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jeffrosenberg/random-notion/pkg/cassette"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrievePageCollectionFromCassette(t *testing.T) {
+	// Arrange
+	api := &ApiConfig{
+		Url:         "https://api.notion.com/v1",
+		DatabaseId:  mockDatabaseId,
+		SecretToken: mockApiToken,
+		HttpClient: &http.Client{
+			Transport: cassette.Transport(t, "testdata/cassettes/query_database.json", cassette.Options{Secrets: []string{mockApiToken}}),
+		},
+	}
+
+	// Act
+	pages, err := api.GetPages()
+
+	// Assert
+	if assert.NoError(t, err) {
+		ids := []string{}
+		for _, page := range pages {
+			ids = append(ids, page.Id)
+			assert.Equal(t, mockDatabaseId, page.SourceDatabaseId)
+			assert.Equal(t, cassette.REDACTED, page.Title())
+		}
+		assert.Equal(t, []string{
+			"3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			"5331da24-6597-4f2d-a684-fd94a0f3278a",
+			"240c0dcf-8334-43e5-9a01-a914c21de7e4",
+		}, ids)
+	}
+}
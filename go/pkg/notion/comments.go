@@ -0,0 +1,152 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Commenter reads and writes the discussion on a page
+type Commenter interface {
+	ListComments(context.Context, string) ([]Comment, error)
+	CreateComment(context.Context, NewComment) (*Comment, error)
+}
+
+// ===============================================================
+// Notion comment object,
+// per https://developers.notion.com/reference/comment-object
+// ---------------------------------------------------------------
+type Comment struct {
+	Object         string        `json:"object,omitempty"`
+	Id             string        `json:"id"`
+	Parent         CommentParent `json:"parent"`
+	DiscussionId   string        `json:"discussion_id"`
+	CreatedTime    string        `json:"created_time"`
+	LastEditedTime string        `json:"last_edited_time"`
+	CreatedBy      *User         `json:"created_by,omitempty"`
+	RichText       []RichText    `json:"rich_text"`
+}
+
+type CommentParent struct {
+	Type    string `json:"type"` // "page_id" or "block_id"
+	PageId  string `json:"page_id,omitempty"`
+	BlockId string `json:"block_id,omitempty"`
+}
+
+// Return the plain text of the comment
+func (c Comment) Text() string {
+	return PlainText(c.RichText)
+}
+
+// ===============================================================
+
+// A comment to post, either as a new discussion on a page
+// or as a reply to an existing discussion
+type NewComment struct {
+	PageId       string // Ignored if DiscussionId is set
+	DiscussionId string
+	RichText     []RichText
+}
+
+type commentRequest struct {
+	Parent       *CommentParent `json:"parent,omitempty"`
+	DiscussionId string         `json:"discussion_id,omitempty"`
+	RichText     []RichText     `json:"rich_text"`
+}
+
+type commentResponse struct {
+	Object  string    `json:"object"`
+	Results []Comment `json:"results"`
+	Next    string    `json:"next_cursor"`
+	HasMore bool      `json:"has_more"`
+}
+
+// Return the unresolved comments on a page or block, oldest first
+func (api *ApiConfig) ListComments(ctx context.Context, blockId string) ([]Comment, error) {
+	defer logging.LogFunction(
+		"comments.ListComments", time.Now(), "Listing comments",
+		map[string]interface{}{"block_id": blockId},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+
+	comments := []Comment{}
+	cursor := ""
+	seen := cursors{}
+	for hasMore := true; hasMore; {
+		params := url.Values{"block_id": {blockId}}
+		if api.PageSize > 0 {
+			params.Set("page_size", strconv.Itoa(int(api.PageSize)))
+		}
+		if cursor != "" {
+			params.Set("start_cursor", cursor)
+		}
+		body, err := api.do(ctx, apiRequest{
+			Method:     "GET",
+			Path:       "/comments?" + params.Encode(),
+			Idempotent: true,
+		})
+		if err != nil {
+			logger.Err(err).Msg("Unable to list comments")
+			return nil, err
+		}
+		logger.Trace().RawJSON("comments_response_json", body).Msg("Receieved Notion API response")
+
+		var response commentResponse
+		if err = decodeResponse(body, "list", &response); err == nil {
+			err = seen.check(response.HasMore, response.Next)
+		}
+		if err != nil {
+			logger.Err(err).Msg("Unable to list comments")
+			return nil, err
+		}
+		comments = append(comments, response.Results...)
+		hasMore = response.HasMore
+		cursor = response.Next
+	}
+
+	return comments, nil
+}
+
+// Post a comment, returning it as created by Notion.
+// Requires the integration to have the insert comments capability
+func (api *ApiConfig) CreateComment(ctx context.Context, comment NewComment) (*Comment, error) {
+	defer logging.LogFunction(
+		"comments.CreateComment", time.Now(), "Creating comment",
+		map[string]interface{}{
+			"page_id":       comment.PageId,
+			"discussion_id": comment.DiscussionId,
+		},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+
+	request := commentRequest{DiscussionId: comment.DiscussionId, RichText: comment.RichText}
+	if comment.DiscussionId == "" {
+		if comment.PageId == "" {
+			return nil, fmt.Errorf("Unable to create comment: no page or discussion given")
+		}
+		request.Parent = &CommentParent{Type: "page_id", PageId: comment.PageId}
+	}
+
+	// Not retried, since a retry after a lost response would post the comment twice
+	body, err := api.do(ctx, apiRequest{
+		Method: "POST",
+		Path:   "/comments",
+		Body:   request,
+	})
+	if err != nil {
+		logger.Err(err).Msg("Unable to create comment")
+		return nil, err
+	}
+	logger.Trace().RawJSON("comment_response_json", body).Msg("Receieved Notion API response")
+
+	var created Comment
+	if err := decodeResponse(body, "comment", &created); err != nil {
+		logger.Err(err).Send()
+		return nil, err
+	}
+	return &created, nil
+}
@@ -0,0 +1,150 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListComments(t *testing.T) {
+	// Arrange
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.URL.Query().Get("start_cursor") == "" {
+			w.Write([]byte(`{
+				"object": "list",
+				"results": [{
+					"object": "comment",
+					"id": "94cc56ab-9f02-409d-9f99-1037e9fe502f",
+					"parent": {"type": "page_id", "page_id": "5c6a2821-6bb1-4a7e-b6e1-c50111515c3d"},
+					"discussion_id": "f1407351-36f5-4c49-a13c-49f8ba11776d",
+					"created_time": "2022-07-15T16:52:00.000Z",
+					"last_edited_time": "2022-07-15T19:16:00.000Z",
+					"created_by": {"object": "user", "id": "9b15170a-9941-4297-8ee6-83fa7649a87a"},
+					"rich_text": [{"type": "text", "text": {"content": "Single comment"}, "plain_text": "Single comment"}]
+				}],
+				"next_cursor": "cursor-2",
+				"has_more": true
+			}`))
+			return
+		}
+		w.Write([]byte(`{"object": "list", "results": [{"object": "comment", "id": "a0e1bc8c-3d2b-4b1e-9a3f-5e6d7c8b9a01", "rich_text": [{"type": "text", "text": {"content": "Reply"}, "plain_text": "Reply"}]}], "next_cursor": null, "has_more": false}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, SecretToken: mockApiToken, PageSize: 1}
+
+	// Act
+	comments, err := api.ListComments(context.Background(), "5c6a2821-6bb1-4a7e-b6e1-c50111515c3d")
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "Single comment", comments[0].Text())
+	assert.Equal(t, "f1407351-36f5-4c49-a13c-49f8ba11776d", comments[0].DiscussionId)
+	assert.Equal(t, CommentParent{Type: "page_id", PageId: "5c6a2821-6bb1-4a7e-b6e1-c50111515c3d"}, comments[0].Parent)
+	assert.Equal(t, "Reply", comments[1].Text())
+	assert.Equal(t, []string{
+		"/comments?block_id=5c6a2821-6bb1-4a7e-b6e1-c50111515c3d&page_size=1",
+		"/comments?block_id=5c6a2821-6bb1-4a7e-b6e1-c50111515c3d&page_size=1&start_cursor=cursor-2",
+	}, requests)
+}
+
+func TestListCommentsRejectsInvalidResponses(t *testing.T) {
+	tests := map[string]string{
+		"non-JSON body": "<html><body>502 Bad Gateway</body></html>",
+		"repeated cursor": `{"object": "list", "results": [{"object": "comment", "id": "94cc56ab-9f02-409d-9f99-1037e9fe502f"}],
+			"next_cursor": "cursor-2", "has_more": true}`,
+	}
+
+	for name, body := range tests {
+		// Arrange
+		ts, api := mockNotionServer(body, http.StatusOK)
+
+		// Act
+		comments, err := api.ListComments(context.Background(), "5c6a2821-6bb1-4a7e-b6e1-c50111515c3d")
+		ts.Close()
+
+		// Assert
+		assert.Nil(t, comments, name)
+		assert.ErrorIs(t, err, ErrInvalidResponse, name)
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		comment  NewComment
+		expected string
+	}{
+		{
+			"on page",
+			NewComment{PageId: mockPageId, RichText: []RichText{NewRichText("Worth revisiting")}},
+			`{"parent": {"type": "page_id", "page_id": "` + mockPageId + `"}, "rich_text": [{"type": "text", "text": {"content": "Worth revisiting"}}]}`,
+		},
+		{
+			"in discussion",
+			NewComment{PageId: mockPageId, DiscussionId: "f1407351-36f5-4c49-a13c-49f8ba11776d", RichText: []RichText{NewRichText("Agreed")}},
+			`{"discussion_id": "f1407351-36f5-4c49-a13c-49f8ba11776d", "rich_text": [{"type": "text", "text": {"content": "Agreed"}}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var method string
+			var body []byte
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				body, _ = io.ReadAll(r.Body)
+				w.Write([]byte(`{"object": "comment", "id": "94cc56ab-9f02-409d-9f99-1037e9fe502f", "discussion_id": "f1407351-36f5-4c49-a13c-49f8ba11776d", "rich_text": []}`))
+			}))
+			defer ts.Close()
+			api := &ApiConfig{Url: ts.URL, SecretToken: mockApiToken}
+
+			// Act
+			comment, err := api.CreateComment(context.Background(), test.comment)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, http.MethodPost, method)
+			assert.JSONEq(t, test.expected, string(body))
+			assert.Equal(t, "94cc56ab-9f02-409d-9f99-1037e9fe502f", comment.Id)
+		})
+	}
+}
+
+func TestCreateCommentIsNotRetried(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 503, "code": "service_unavailable", "message": "Notion is unavailable"}`, http.StatusServiceUnavailable)
+	defer ts.Close()
+	requests := 0
+	api.Retry = RetryPolicy{MaxAttempts: 3}
+	api.Middlewares = []Middleware{MetricsMiddleware(func(RequestMetrics) { requests++ })}
+
+	// Act
+	_, err := api.CreateComment(context.Background(), NewComment{PageId: mockPageId, RichText: []RichText{NewRichText("Once")}})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrServiceUnavailable)
+	assert.Equal(t, 1, requests)
+}
+
+func TestResolveCommentUsers(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionUsersServer()
+	defer ts.Close()
+	comments := []Comment{{Id: "94cc56ab-9f02-409d-9f99-1037e9fe502f", CreatedBy: &User{Id: mockUserId}}}
+
+	// Act
+	err := api.ResolveCommentUsers(context.Background(), comments)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", comments[0].CreatedBy.Name)
+}
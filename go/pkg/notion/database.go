@@ -1,69 +1,140 @@
 package notion
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jeffrosenberg/random-notion/pkg/logging"
 )
 
 type Database struct {
-	Id             string `json:"id"`
-	CreatedTime    string `json:"created_time"`
-	LastEditedTime string `json:"last_edited_time"`
-	Url            string `json:"url"`
+	Id             string                    `json:"id"`
+	CreatedTime    string                    `json:"created_time"`
+	LastEditedTime string                    `json:"last_edited_time"`
+	Url            string                    `json:"url"`
+	Title          []RichText                `json:"title,omitempty"`
+	Description    []RichText                `json:"description,omitempty"`
+	Properties     map[string]PropertySchema `json:"properties,omitempty"`   // Moved to data sources in VERSION_2025_09_03
+	DataSources    []DataSourceRef           `json:"data_sources,omitempty"` // Only returned by VERSION_2025_09_03 and later
+}
+
+// Return the plain text of the database's title
+func (db Database) Name() string {
+	return PlainText(db.Title)
+}
+
+type DataSourceRef struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
 }
 
 func (api *ApiConfig) GetDatabase() (*Database, error) {
+	return api.GetDatabaseWithContext(context.Background())
+}
+
+func (api *ApiConfig) GetDatabaseWithContext(ctx context.Context) (*Database, error) {
 	defer logging.LogFunction(
 		"pages.GetDatabase", time.Now(), "Getting database", map[string]interface{}{},
 	)
-	logger := logging.GetLogger()
-	url, err := url.Parse(fmt.Sprintf("%s/databases/%s", api.Url, api.DatabaseId))
+	logger := logging.GetLoggerWithContext(ctx)
+	body, err := api.do(ctx, apiRequest{
+		Method:     "GET",
+		Path:       fmt.Sprintf("/databases/%s", api.DatabaseId),
+		Idempotent: true,
+	})
 	if err != nil {
-		logger.Err(err).Msg("Unable to parse URL")
-		return nil, fmt.Errorf("Unable to parse URL: %w", err)
+		logger.Err(err).Msg("Unable to retrieve database")
+		return nil, err
 	}
+	logger.Trace().RawJSON("db_response_json", body).Msg("Receieved Notion API response")
 
-	client := &http.Client{}
-	logger.Trace().
-		Str("request_verb", "GET").
-		Str("request_url", url.String()).
-		Msg("Prepared Notion API request")
-	req, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		logger.Err(err).Msg("Unable to create request")
-		return nil, fmt.Errorf("Unable to create request: %w", err)
+	var db Database
+	if err := decodeResponse(body, "database", &db); err != nil {
+		logger.Err(err).Str("database_id", api.DatabaseId).Send()
+		return nil, err
 	}
-	req.Header.Set("Notion-Version", "2021-08-16")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", api.SecretToken))
 
-	res, err := client.Do(req)
-	if err != nil {
-		logger.Err(err).Msg("Unable to retrieve response")
-		return nil, fmt.Errorf("Unable to retrieve response: %w", err)
+	return &db, nil
+}
+
+// Return the paths to query for pages in the database:
+// the database itself on older API versions, otherwise each of its data sources
+func (api *ApiConfig) queryPaths(ctx context.Context) ([]string, error) {
+	if !api.usesDataSources() {
+		return []string{fmt.Sprintf("/databases/%s/query", api.DatabaseId)}, nil
 	}
 
-	if res.StatusCode != http.StatusOK {
-		err = fmt.Errorf("Received invalid status: %s", res.Status)
-		logger.Err(err).Msg("Received invalid status")
+	ids, err := api.dataSourceIds(ctx)
+	if err != nil {
 		return nil, err
 	}
+	paths := make([]string, len(ids))
+	for i, id := range ids {
+		paths[i] = fmt.Sprintf("/data_sources/%s/query", id)
+	}
+	return paths, nil
+}
 
-	body, err := io.ReadAll(res.Body)
-	defer res.Body.Close()
+// Return DataSourceIds, or the IDs of every data source of the database if it's empty.
+// The IDs are cached in api.DataSources, so that the database is only retrieved once
+func (api *ApiConfig) dataSourceIds(ctx context.Context) ([]string, error) {
+	if len(api.DataSourceIds) > 0 {
+		return api.DataSourceIds, nil
+	}
+	if ids, ok := api.DataSources.Get(api.DatabaseId); ok {
+		return ids, nil
+	}
+
+	db, err := api.GetDatabaseWithContext(ctx)
 	if err != nil {
-		logger.Err(err).Msg("Unable to read response body")
-		return nil, fmt.Errorf("Unable to read response body: %w", err)
+		return nil, err
 	}
-	logger.Trace().RawJSON("db_response_json", body).Msg("Receieved Notion API response")
+	ids := []string{}
+	for _, ds := range db.DataSources {
+		ids = append(ids, ds.Id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("Database %s has no data sources", api.DatabaseId)
+	}
+	api.DataSources.Add(api.DatabaseId, ids)
+	return ids, nil
+}
 
-	var db Database
-	json.Unmarshal(body, &db)
+// DataSourceCache holds the data source IDs of databases already retrieved, keyed by database ID.
+// It is safe for concurrent use and shared by copies of an ApiConfig
+type DataSourceCache struct {
+	mu  sync.RWMutex
+	ids map[string][]string
+}
 
-	return &db, nil
+func NewDataSourceCache() *DataSourceCache {
+	return &DataSourceCache{ids: map[string][]string{}}
+}
+
+func (c *DataSourceCache) Add(databaseId string, ids []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[NormalizeId(databaseId)] = ids
+}
+
+func (c *DataSourceCache) Get(databaseId string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids, ok := c.ids[NormalizeId(databaseId)]
+	return ids, ok
+}
+
+// Return a page or database ID in the form it's compared in,
+// as Notion accepts IDs with or without dashes
+func NormalizeId(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
 }
@@ -77,6 +77,25 @@ func TestRetrieveDatabase(t *testing.T) {
 		CreatedTime:    "2021-02-27T04:04:00.000Z",
 		LastEditedTime: "2021-11-10T00:58:00.000Z",
 		Url:            "https://www.notion.so/45d3242e5c6d4a3bb99e4aa4db83f015",
+		Title: []RichText{
+			{
+				Type:        "text",
+				PlainText:   "Content",
+				Annotations: &Annotations{Color: "default"},
+				Text:        &Text{Content: "Content"},
+			},
+		},
+		Properties: map[string]PropertySchema{
+			"Created": {Id: "MEdb", Name: "Created", Type: PROPERTY_CREATED_TIME},
+			"Sort Order": {
+				Id:      "v:yF",
+				Name:    "Sort Order",
+				Type:    PROPERTY_FORMULA,
+				Formula: &FormulaSchema{Expression: "empty(prop(\"Tag Sort Order\")) ? 100 : prop(\"Tag Sort Order\")"},
+			},
+			"Name": {Id: "title", Name: "Name", Type: PROPERTY_TITLE},
+			"URL":  {Id: "80d87f08-e3b5-41cd-9166-abd3604ea26f", Name: "URL", Type: PROPERTY_URL},
+		},
 	}
 
 	ts, api := mockNotionServer(mockData, http.StatusOK)
@@ -138,6 +157,17 @@ func TestRetrieveDatabaseWithMissingUrl(t *testing.T) {
 		CreatedTime:    "2021-02-27T04:04:00.000Z",
 		LastEditedTime: "2021-11-10T00:58:00.000Z",
 		Url:            "",
+		Title: []RichText{
+			{
+				Type:        "text",
+				PlainText:   "Content",
+				Annotations: &Annotations{Color: "default"},
+				Text:        &Text{Content: "Content"},
+			},
+		},
+		Properties: map[string]PropertySchema{
+			"Created": {Id: "MEdb", Name: "Created", Type: PROPERTY_CREATED_TIME},
+		},
 	}
 
 	ts, api := mockNotionServer(mockData, http.StatusOK)
@@ -181,3 +211,17 @@ func TestRetrieveDatabaseNotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, db)
 }
+
+func TestRetrieveDatabaseRejectsOtherObjects(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3"}`, http.StatusOK)
+	defer ts.Close()
+
+	// Act
+	db, err := api.GetDatabase()
+
+	// Assert
+	assert.Nil(t, db)
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	assert.EqualError(t, err, `Invalid Notion API response: expected a database object, received "page"`)
+}
@@ -0,0 +1,111 @@
+package notion
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Most databases queried at once; requests are also bounded by api.RateLimiter
+const DEFAULT_PARALLELISM = 3
+
+// MultiPageGetter retrieves pages from several databases at once
+type MultiPageGetter interface {
+	GetDatabaseIds() []string
+	GetPagesFromDatabases(context.Context, []DatabaseQuery) []DatabaseResult
+}
+
+// Pages to retrieve from one database
+type DatabaseQuery struct {
+	DatabaseId string
+	Since      time.Time // Only pages created after this time; all pages if zero
+	Edited     bool      // Find pages edited rather than created since Since
+}
+
+// Pages retrieved from one database. Err may be a *PartialResultError,
+// in which case Pages holds what was retrieved before it
+type DatabaseResult struct {
+	DatabaseId string
+	Pages      []Page
+	Err        error
+}
+
+// Return DatabaseId followed by DatabaseIds, without duplicates
+func (api *ApiConfig) GetDatabaseIds() []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range append([]string{api.DatabaseId}, api.DatabaseIds...) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Return a copy of api that queries databaseId.
+// The copy shares the rate limiter and HTTP client of api
+func (api *ApiConfig) WithDatabase(databaseId string) *ApiConfig {
+	copy := *api
+	if databaseId != api.DatabaseId {
+		copy.DataSourceIds = nil // Data sources belong to the original database
+	}
+	copy.DatabaseId = databaseId
+	copy.DatabaseIds = nil
+	return &copy
+}
+
+func (api *ApiConfig) parallelism() int {
+	if api.Parallelism < 1 {
+		return DEFAULT_PARALLELISM
+	}
+	return api.Parallelism
+}
+
+// Retrieve pages from each database concurrently, querying at most api.Parallelism at once.
+// Results are returned in the order of queries, each page tagged with its source database
+func (api *ApiConfig) GetPagesFromDatabases(ctx context.Context, queries []DatabaseQuery) []DatabaseResult {
+	defer logging.LogFunction(
+		"pages.GetPagesFromDatabases", time.Now(), "Getting pages from databases",
+		map[string]interface{}{
+			"databases":   len(queries),
+			"parallelism": api.parallelism(),
+		},
+	)
+
+	results := make([]DatabaseResult, len(queries))
+	semaphore := make(chan struct{}, api.parallelism())
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query DatabaseQuery) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i] = DatabaseResult{
+					DatabaseId: query.DatabaseId,
+					Pages:      []Page{},
+					Err:        &PartialResultError{Err: ctx.Err()},
+				}
+				return
+			}
+
+			database := api.WithDatabase(query.DatabaseId)
+			var pages []Page
+			var err error
+			if query.Edited {
+				pages, err = database.GetPagesEditedSinceTimeWithContext(ctx, query.Since)
+			} else {
+				pages, err = database.GetPagesSinceTimeWithContext(ctx, query.Since)
+			}
+			results[i] = DatabaseResult{DatabaseId: query.DatabaseId, Pages: pages, Err: err}
+		}(i, query)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,120 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Serve one page per database, named after the database in the request path
+func mockNotionServerWithDatabases(delay time.Duration) (*httptest.Server, *ApiConfig, func() int) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		time.Sleep(delay)
+		databaseId := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/databases/"), "/query")
+		if databaseId == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find database"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"object": "list", "results": [{"object": "page", "id": "page-in-%s"}], "next_cursor": null, "has_more": false}`, databaseId)
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		SecretToken: mockApiToken,
+	}
+	return server, api, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxActive
+	}
+}
+
+func TestGetDatabaseIds(t *testing.T) {
+	api := &ApiConfig{DatabaseId: "reading-list", DatabaseIds: []string{"zettelkasten", "reading-list", "", "meeting-notes"}}
+
+	assert.Equal(t, []string{"reading-list", "zettelkasten", "meeting-notes"}, api.GetDatabaseIds())
+}
+
+func TestWithDatabaseSharesRateLimiter(t *testing.T) {
+	// Arrange
+	api := NewApiConfig()
+	api.DatabaseId = "reading-list"
+	api.DataSourceIds = []string{"ds-reading"}
+
+	// Act
+	copy := api.WithDatabase("zettelkasten")
+
+	// Assert
+	assert.Equal(t, "zettelkasten", copy.DatabaseId)
+	assert.Nil(t, copy.DataSourceIds)
+	assert.Same(t, api.RateLimiter, copy.RateLimiter)
+	assert.Equal(t, "reading-list", api.DatabaseId)
+}
+
+func TestGetPagesFromDatabases(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionServerWithDatabases(0)
+	defer ts.Close()
+	queries := []DatabaseQuery{
+		{DatabaseId: "reading-list"},
+		{DatabaseId: "missing"},
+		{DatabaseId: "meeting-notes", Since: time.Now()},
+	}
+
+	// Act
+	results := api.GetPagesFromDatabases(context.Background(), queries)
+
+	// Assert
+	require.Len(t, results, 3)
+	assert.Equal(t, "reading-list", results[0].DatabaseId)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, []Page{{Id: "page-in-reading-list", SourceDatabaseId: "reading-list"}}, results[0].Pages)
+	assert.Equal(t, "missing", results[1].DatabaseId)
+	assert.ErrorIs(t, results[1].Err, ErrObjectNotFound)
+	assert.Equal(t, "meeting-notes", results[2].DatabaseId)
+	assert.Equal(t, []Page{{Id: "page-in-meeting-notes", SourceDatabaseId: "meeting-notes"}}, results[2].Pages)
+}
+
+func TestGetPagesFromDatabasesBoundsParallelism(t *testing.T) {
+	// Arrange
+	ts, api, maxActive := mockNotionServerWithDatabases(20 * time.Millisecond)
+	defer ts.Close()
+	api.Parallelism = 2
+	queries := []DatabaseQuery{}
+	for i := 0; i < 6; i++ {
+		queries = append(queries, DatabaseQuery{DatabaseId: fmt.Sprintf("database-%d", i)})
+	}
+
+	// Act
+	results := api.GetPagesFromDatabases(context.Background(), queries)
+
+	// Assert
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.Len(t, result.Pages, 1)
+	}
+	assert.Equal(t, 2, maxActive())
+}
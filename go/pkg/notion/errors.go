@@ -0,0 +1,147 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PartialResultError is returned alongside the pages retrieved so far
+// when paging stops early because the context was cancelled or its deadline is near
+type PartialResultError struct {
+	Cursor string // Cursor to resume paging from
+	Pages  int    // Number of pages retrieved before stopping
+	Err    error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("Partial results: stopped after %d pages: %v", e.Pages, e.Err)
+}
+
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
+
+// Returned, wrapped with the details, when a successful response from the Notion API can't be used
+var ErrInvalidResponse = errors.New("Invalid Notion API response")
+
+// The Err of a *PartialResultError when a query stops at ApiConfig.MaxPages
+var ErrPageLimit = errors.New("Page limit reached")
+
+// Returned, wrapped with the timeout, when an attempt at a request takes longer than the HttpClient's Timeout
+var ErrAttemptTimeout = errors.New("Notion API request timed out")
+
+// retryAbandonedError is returned when the context ends before a failed request could be retried.
+// It unwraps to the error of the last attempt, such as an *APIError, and also matches the context's error
+type retryAbandonedError struct {
+	Err   error // Error of the last attempt
+	Cause error // Why it wasn't retried
+}
+
+func (e *retryAbandonedError) Error() string {
+	return fmt.Sprintf("%v; not retrying: %v", e.Err, e.Cause)
+}
+
+func (e *retryAbandonedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *retryAbandonedError) Is(target error) bool {
+	return errors.Is(e.Cause, target)
+}
+
+func (e *retryAbandonedError) As(target interface{}) bool {
+	return errors.As(e.Cause, target)
+}
+
+// Return true if err indicates that the returned pages are incomplete
+func IsPartial(err error) bool {
+	var partial *PartialResultError
+	return errors.As(err, &partial)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Error codes returned by the Notion API,
+// per https://developers.notion.com/reference/errors
+const (
+	CODE_INVALID_JSON                    = "invalid_json"
+	CODE_INVALID_REQUEST_URL             = "invalid_request_url"
+	CODE_INVALID_REQUEST                 = "invalid_request"
+	CODE_VALIDATION_ERROR                = "validation_error"
+	CODE_MISSING_VERSION                 = "missing_version"
+	CODE_UNAUTHORIZED                    = "unauthorized"
+	CODE_RESTRICTED_RESOURCE             = "restricted_resource"
+	CODE_OBJECT_NOT_FOUND                = "object_not_found"
+	CODE_CONFLICT_ERROR                  = "conflict_error"
+	CODE_RATE_LIMITED                    = "rate_limited"
+	CODE_INTERNAL_SERVER_ERROR           = "internal_server_error"
+	CODE_SERVICE_UNAVAILABLE             = "service_unavailable"
+	CODE_DATABASE_CONNECTION_UNAVAILABLE = "database_connection_unavailable"
+	CODE_GATEWAY_TIMEOUT                 = "gateway_timeout"
+)
+
+// Sentinels for use with errors.Is, which match any *APIError with the same code
+var (
+	ErrInvalidJson                   = &APIError{Code: CODE_INVALID_JSON}
+	ErrInvalidRequestUrl             = &APIError{Code: CODE_INVALID_REQUEST_URL}
+	ErrInvalidRequest                = &APIError{Code: CODE_INVALID_REQUEST}
+	ErrValidation                    = &APIError{Code: CODE_VALIDATION_ERROR}
+	ErrMissingVersion                = &APIError{Code: CODE_MISSING_VERSION}
+	ErrUnauthorized                  = &APIError{Code: CODE_UNAUTHORIZED}
+	ErrRestrictedResource            = &APIError{Code: CODE_RESTRICTED_RESOURCE}
+	ErrObjectNotFound                = &APIError{Code: CODE_OBJECT_NOT_FOUND}
+	ErrConflict                      = &APIError{Code: CODE_CONFLICT_ERROR}
+	ErrRateLimited                   = &APIError{Code: CODE_RATE_LIMITED}
+	ErrInternalServer                = &APIError{Code: CODE_INTERNAL_SERVER_ERROR}
+	ErrServiceUnavailable            = &APIError{Code: CODE_SERVICE_UNAVAILABLE}
+	ErrDatabaseConnectionUnavailable = &APIError{Code: CODE_DATABASE_CONNECTION_UNAVAILABLE}
+	ErrGatewayTimeout                = &APIError{Code: CODE_GATEWAY_TIMEOUT}
+)
+
+// APIError is an error response from the Notion API.
+// Status is always the HTTP status of the response;
+// Code and Message are empty if the body wasn't a Notion error object
+type APIError struct {
+	Status     int           `json:"status"`
+	Code       string        `json:"code"`
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"-"` // Parsed from the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("Received invalid status: %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("Received invalid status: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Match sentinel errors by code, e.g. errors.Is(err, notion.ErrObjectNotFound)
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// Build an *APIError from an unsuccessful response and its body
+func newAPIError(res *http.Response, body []byte) *APIError {
+	var apiErr APIError
+	var parsed struct {
+		Object string `json:"object"`
+		APIError
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Object == "error" {
+		apiErr = parsed.APIError
+	}
+
+	// Notion's header status is authoritative over the one in the body
+	apiErr.Status = res.StatusCode
+	if d, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+		apiErr.RetryAfter = d
+	}
+	return &apiErr
+}
@@ -0,0 +1,75 @@
+package notion
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiErrorFromResponseBody(t *testing.T) {
+	mockData := `{
+		"object": "error",
+		"status": 404,
+		"code": "object_not_found",
+		"message": "Could not find database with ID: 99999999-abcd-efgh-1234-000000000000."
+	}`
+
+	ts, api := mockNotionServer(mockData, http.StatusNotFound)
+	defer ts.Close()
+
+	_, err := api.GetDatabase()
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.Status)
+		assert.Equal(t, CODE_OBJECT_NOT_FOUND, apiErr.Code)
+		assert.Equal(t, "Could not find database with ID: 99999999-abcd-efgh-1234-000000000000.", apiErr.Message)
+	}
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestApiErrorUnauthorized(t *testing.T) {
+	ts, api := mockNotionServer(`{}`, http.StatusOK)
+	defer ts.Close()
+	api.SecretToken = "invalid_token"
+
+	pages, err := api.GetPages()
+
+	assert.Nil(t, pages)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestApiErrorWithoutNotionBody(t *testing.T) {
+	ts, api := mockNotionServer(`<html><body>Bad Gateway</body></html>`, http.StatusBadGateway)
+	defer ts.Close()
+
+	_, err := api.GetDatabase()
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusBadGateway, apiErr.Status)
+		assert.Equal(t, "", apiErr.Code)
+		assert.Equal(t, "Received invalid status: 502 Bad Gateway", apiErr.Error())
+	}
+}
+
+func TestApiErrorRetryAfter(t *testing.T) {
+	ts, api := mockNotionServer(`{"object": "error", "status": 429, "code": "rate_limited", "message": "Slow down"}`, http.StatusTooManyRequests)
+	defer ts.Close()
+	handler := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		handler.ServeHTTP(w, r)
+	})
+
+	_, err := api.GetPages()
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, float64(30), apiErr.RetryAfter.Seconds())
+	}
+}
@@ -0,0 +1,321 @@
+package notion
+
+// ===============================================================
+// Notion database query filters and sorts,
+// per https://developers.notion.com/reference/post-database-query-filter
+// and https://developers.notion.com/reference/post-database-query-sort.
+// Empty string conditions are omitted, so an empty value can't be matched
+// with e.g. Equals: ""; use IsEmpty instead
+// ---------------------------------------------------------------
+
+// Filter is a single property or timestamp condition, or a compound
+// And/Or of other filters. Build one with Property, CreatedTime,
+// LastEditedTime, And and Or rather than by hand
+type Filter struct {
+	Property  string `json:"property,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"` // "created_time" or "last_edited_time"
+
+	Title          *TextCondition        `json:"title,omitempty"`
+	RichText       *TextCondition        `json:"rich_text,omitempty"`
+	Url            *TextCondition        `json:"url,omitempty"`
+	Email          *TextCondition        `json:"email,omitempty"`
+	PhoneNumber    *TextCondition        `json:"phone_number,omitempty"`
+	Number         *NumberCondition      `json:"number,omitempty"`
+	Checkbox       *CheckboxCondition    `json:"checkbox,omitempty"`
+	Select         *SelectCondition      `json:"select,omitempty"`
+	MultiSelect    *MultiSelectCondition `json:"multi_select,omitempty"`
+	Status         *SelectCondition      `json:"status,omitempty"`
+	Date           *DateCondition        `json:"date,omitempty"`
+	People         *ContainsCondition    `json:"people,omitempty"`
+	Files          *EmptyCondition       `json:"files,omitempty"`
+	Relation       *ContainsCondition    `json:"relation,omitempty"`
+	Formula        *FormulaCondition     `json:"formula,omitempty"`
+	Rollup         *RollupCondition      `json:"rollup,omitempty"`
+	UniqueId       *NumberCondition      `json:"unique_id,omitempty"`
+	CreatedTime    *DateCondition        `json:"created_time,omitempty"`
+	CreatedBy      *ContainsCondition    `json:"created_by,omitempty"`
+	LastEditedTime *DateCondition        `json:"last_edited_time,omitempty"`
+	LastEditedBy   *ContainsCondition    `json:"last_edited_by,omitempty"`
+
+	And []Filter `json:"and,omitempty"`
+	Or  []Filter `json:"or,omitempty"`
+}
+
+type TextCondition struct {
+	Equals         string `json:"equals,omitempty"`
+	DoesNotEqual   string `json:"does_not_equal,omitempty"`
+	Contains       string `json:"contains,omitempty"`
+	DoesNotContain string `json:"does_not_contain,omitempty"`
+	StartsWith     string `json:"starts_with,omitempty"`
+	EndsWith       string `json:"ends_with,omitempty"`
+	IsEmpty        bool   `json:"is_empty,omitempty"`
+	IsNotEmpty     bool   `json:"is_not_empty,omitempty"`
+}
+
+type NumberCondition struct {
+	Equals               *float64 `json:"equals,omitempty"`
+	DoesNotEqual         *float64 `json:"does_not_equal,omitempty"`
+	GreaterThan          *float64 `json:"greater_than,omitempty"`
+	LessThan             *float64 `json:"less_than,omitempty"`
+	GreaterThanOrEqualTo *float64 `json:"greater_than_or_equal_to,omitempty"`
+	LessThanOrEqualTo    *float64 `json:"less_than_or_equal_to,omitempty"`
+	IsEmpty              bool     `json:"is_empty,omitempty"`
+	IsNotEmpty           bool     `json:"is_not_empty,omitempty"`
+}
+
+type CheckboxCondition struct {
+	Equals       *bool `json:"equals,omitempty"`
+	DoesNotEqual *bool `json:"does_not_equal,omitempty"`
+}
+
+// Condition for select and status properties
+type SelectCondition struct {
+	Equals       string `json:"equals,omitempty"`
+	DoesNotEqual string `json:"does_not_equal,omitempty"`
+	IsEmpty      bool   `json:"is_empty,omitempty"`
+	IsNotEmpty   bool   `json:"is_not_empty,omitempty"`
+}
+
+type MultiSelectCondition struct {
+	Contains       string `json:"contains,omitempty"`
+	DoesNotContain string `json:"does_not_contain,omitempty"`
+	IsEmpty        bool   `json:"is_empty,omitempty"`
+	IsNotEmpty     bool   `json:"is_not_empty,omitempty"`
+}
+
+// Condition for date, created_time and last_edited_time values.
+// Dates are ISO 8601 strings; relative conditions are set with Relative()
+type DateCondition struct {
+	Equals     string    `json:"equals,omitempty"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+	OnOrBefore string    `json:"on_or_before,omitempty"`
+	OnOrAfter  string    `json:"on_or_after,omitempty"`
+	PastWeek   *struct{} `json:"past_week,omitempty"`
+	PastMonth  *struct{} `json:"past_month,omitempty"`
+	PastYear   *struct{} `json:"past_year,omitempty"`
+	ThisWeek   *struct{} `json:"this_week,omitempty"`
+	NextWeek   *struct{} `json:"next_week,omitempty"`
+	NextMonth  *struct{} `json:"next_month,omitempty"`
+	NextYear   *struct{} `json:"next_year,omitempty"`
+	IsEmpty    bool      `json:"is_empty,omitempty"`
+	IsNotEmpty bool      `json:"is_not_empty,omitempty"`
+}
+
+// Condition for people, relation, created_by and last_edited_by properties,
+// which are matched by user or page ID
+type ContainsCondition struct {
+	Contains       string `json:"contains,omitempty"`
+	DoesNotContain string `json:"does_not_contain,omitempty"`
+	IsEmpty        bool   `json:"is_empty,omitempty"`
+	IsNotEmpty     bool   `json:"is_not_empty,omitempty"`
+}
+
+type EmptyCondition struct {
+	IsEmpty    bool `json:"is_empty,omitempty"`
+	IsNotEmpty bool `json:"is_not_empty,omitempty"`
+}
+
+// Condition on the result type of a formula
+type FormulaCondition struct {
+	String   *TextCondition     `json:"string,omitempty"`
+	Checkbox *CheckboxCondition `json:"checkbox,omitempty"`
+	Number   *NumberCondition   `json:"number,omitempty"`
+	Date     *DateCondition     `json:"date,omitempty"`
+}
+
+// Condition on a rollup. Any, Every and None take a filter
+// without a Property, which is applied to each item in an array rollup
+type RollupCondition struct {
+	Any    *Filter          `json:"any,omitempty"`
+	Every  *Filter          `json:"every,omitempty"`
+	None   *Filter          `json:"none,omitempty"`
+	Number *NumberCondition `json:"number,omitempty"`
+	Date   *DateCondition   `json:"date,omitempty"`
+}
+
+const (
+	ASCENDING  = "ascending"
+	DESCENDING = "descending"
+)
+
+type Sort struct {
+	Property  string `json:"property,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Direction string `json:"direction"`
+}
+
+// Query selects and orders the pages returned from a database
+type Query struct {
+	Filter *Filter
+	Sorts  []Sort
+}
+
+// ===============================================================
+
+// PropertyFilter builds a filter on a single named database property
+type PropertyFilter struct {
+	name string
+}
+
+// Start a filter on the named database property,
+// e.g. Property("Type").Select(SelectCondition{Equals: "Article"})
+func Property(name string) PropertyFilter {
+	return PropertyFilter{name: name}
+}
+
+func (p PropertyFilter) Title(c TextCondition) Filter {
+	return Filter{Property: p.name, Title: &c}
+}
+
+func (p PropertyFilter) RichText(c TextCondition) Filter {
+	return Filter{Property: p.name, RichText: &c}
+}
+
+func (p PropertyFilter) Url(c TextCondition) Filter {
+	return Filter{Property: p.name, Url: &c}
+}
+
+func (p PropertyFilter) Email(c TextCondition) Filter {
+	return Filter{Property: p.name, Email: &c}
+}
+
+func (p PropertyFilter) PhoneNumber(c TextCondition) Filter {
+	return Filter{Property: p.name, PhoneNumber: &c}
+}
+
+func (p PropertyFilter) Number(c NumberCondition) Filter {
+	return Filter{Property: p.name, Number: &c}
+}
+
+func (p PropertyFilter) Checkbox(c CheckboxCondition) Filter {
+	return Filter{Property: p.name, Checkbox: &c}
+}
+
+func (p PropertyFilter) Select(c SelectCondition) Filter {
+	return Filter{Property: p.name, Select: &c}
+}
+
+func (p PropertyFilter) MultiSelect(c MultiSelectCondition) Filter {
+	return Filter{Property: p.name, MultiSelect: &c}
+}
+
+func (p PropertyFilter) Status(c SelectCondition) Filter {
+	return Filter{Property: p.name, Status: &c}
+}
+
+func (p PropertyFilter) Date(c DateCondition) Filter {
+	return Filter{Property: p.name, Date: &c}
+}
+
+func (p PropertyFilter) People(c ContainsCondition) Filter {
+	return Filter{Property: p.name, People: &c}
+}
+
+func (p PropertyFilter) Files(c EmptyCondition) Filter {
+	return Filter{Property: p.name, Files: &c}
+}
+
+func (p PropertyFilter) Relation(c ContainsCondition) Filter {
+	return Filter{Property: p.name, Relation: &c}
+}
+
+func (p PropertyFilter) Formula(c FormulaCondition) Filter {
+	return Filter{Property: p.name, Formula: &c}
+}
+
+func (p PropertyFilter) Rollup(c RollupCondition) Filter {
+	return Filter{Property: p.name, Rollup: &c}
+}
+
+func (p PropertyFilter) UniqueId(c NumberCondition) Filter {
+	return Filter{Property: p.name, UniqueId: &c}
+}
+
+func (p PropertyFilter) CreatedTime(c DateCondition) Filter {
+	return Filter{Property: p.name, CreatedTime: &c}
+}
+
+func (p PropertyFilter) CreatedBy(c ContainsCondition) Filter {
+	return Filter{Property: p.name, CreatedBy: &c}
+}
+
+func (p PropertyFilter) LastEditedTime(c DateCondition) Filter {
+	return Filter{Property: p.name, LastEditedTime: &c}
+}
+
+func (p PropertyFilter) LastEditedBy(c ContainsCondition) Filter {
+	return Filter{Property: p.name, LastEditedBy: &c}
+}
+
+// Filter on the page's built-in creation time, which every database has
+func CreatedTime(c DateCondition) Filter {
+	return Filter{Timestamp: PROPERTY_CREATED_TIME, CreatedTime: &c}
+}
+
+// Filter on the page's built-in last edited time, which every database has
+func LastEditedTime(c DateCondition) Filter {
+	return Filter{Timestamp: PROPERTY_LAST_EDITED_TIME, LastEditedTime: &c}
+}
+
+// Match pages that satisfy every one of filters
+func And(filters ...Filter) Filter {
+	return Filter{And: filters}
+}
+
+// Match pages that satisfy any one of filters
+func Or(filters ...Filter) Filter {
+	return Filter{Or: filters}
+}
+
+// Combine filters with And, skipping nil ones and
+// flattening when there is only one left. Filters that are themselves an And
+// are merged into the result, as Notion only allows two levels of nesting
+func allOf(filters ...*Filter) *Filter {
+	nonNil := make([]Filter, 0, len(filters))
+	for _, f := range filters {
+		switch {
+		case f == nil:
+		case isAnd(f):
+			nonNil = append(nonNil, f.And...)
+		default:
+			nonNil = append(nonNil, *f)
+		}
+	}
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return &nonNil[0]
+	}
+	combined := And(nonNil...)
+	return &combined
+}
+
+// Return whether f is an And of other filters, with no condition of its own
+func isAnd(f *Filter) bool {
+	return len(f.And) > 0 && len(f.Or) == 0 && f.Property == "" && f.Timestamp == ""
+}
+
+func SortByProperty(name string, direction string) Sort {
+	return Sort{Property: name, Direction: direction}
+}
+
+func SortByTimestamp(timestamp string, direction string) Sort {
+	return Sort{Timestamp: timestamp, Direction: direction}
+}
+
+// Return a pointer to b, for CheckboxCondition values
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Return a pointer to f, for NumberCondition values
+func Float(f float64) *float64 {
+	return &f
+}
+
+// Return a value for setting a relative DateCondition such as PastWeek
+func Relative() *struct{} {
+	return &struct{}{}
+}
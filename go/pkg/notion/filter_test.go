@@ -0,0 +1,126 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompoundFilterJson(t *testing.T) {
+	filter := And(
+		Property("Type").Select(SelectCondition{Equals: "Article"}),
+		Property("Archived").Checkbox(CheckboxCondition{Equals: Bool(false)}),
+		Or(
+			Property("Tags").MultiSelect(MultiSelectCondition{Contains: "go"}),
+			Property("Rating").Number(NumberCondition{GreaterThanOrEqualTo: Float(4)}),
+		),
+	)
+
+	result, err := json.Marshal(filter)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"and": [
+			{"property": "Type", "select": {"equals": "Article"}},
+			{"property": "Archived", "checkbox": {"equals": false}},
+			{"or": [
+				{"property": "Tags", "multi_select": {"contains": "go"}},
+				{"property": "Rating", "number": {"greater_than_or_equal_to": 4}}
+			]}
+		]
+	}`, string(result))
+}
+
+func TestTimestampFilterJson(t *testing.T) {
+	tests := []struct {
+		filter   Filter
+		expected string
+	}{
+		{
+			CreatedTime(DateCondition{After: "2021-12-10T01:00:00-0600"}),
+			`{"timestamp": "created_time", "created_time": {"after": "2021-12-10T01:00:00-0600"}}`,
+		},
+		{
+			LastEditedTime(DateCondition{PastWeek: Relative()}),
+			`{"timestamp": "last_edited_time", "last_edited_time": {"past_week": {}}}`,
+		},
+		{
+			Property("Summary").RichText(TextCondition{IsEmpty: true}),
+			`{"property": "Summary", "rich_text": {"is_empty": true}}`,
+		},
+		{
+			Property("Related Tags").Rollup(RollupCondition{
+				Any: &Filter{RichText: &TextCondition{Contains: "cooking"}},
+			}),
+			`{"property": "Related Tags", "rollup": {"any": {"rich_text": {"contains": "cooking"}}}}`,
+		},
+		{
+			Property("Sort Order").Formula(FormulaCondition{Number: &NumberCondition{LessThan: Float(100)}}),
+			`{"property": "Sort Order", "formula": {"number": {"less_than": 100}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := json.Marshal(test.filter)
+		require.NoError(t, err)
+		assert.JSONEq(t, test.expected, string(result))
+	}
+}
+
+func TestQueryDatabaseSendsFilterAndSorts(t *testing.T) {
+	var received map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.Write([]byte(`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`))
+	}))
+	defer ts.Close()
+	base := Property("Archived").Checkbox(CheckboxCondition{Equals: Bool(false)})
+	api := &ApiConfig{
+		Url:        ts.URL,
+		DatabaseId: mockDatabaseId,
+		PageSize:   DEFAULT_PAGE_SIZE,
+		Filter:     &base,
+		Sorts:      []Sort{SortByTimestamp(PROPERTY_CREATED_TIME, DESCENDING)},
+	}
+	query := Query{
+		Filter: &Filter{Property: "Type", Select: &SelectCondition{Equals: "Article"}},
+	}
+
+	_, err := api.QueryDatabase(context.Background(), query)
+
+	require.NoError(t, err)
+	expected, _ := json.Marshal(map[string]interface{}{
+		"filter":    And(base, *query.Filter),
+		"sorts":     api.Sorts,
+		"page_size": DEFAULT_PAGE_SIZE,
+	})
+	actual, _ := json.Marshal(received)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestAllOfFlattensAndFilters(t *testing.T) {
+	// Arrange
+	archived := Property("Archived").Checkbox(CheckboxCondition{Equals: Bool(false)})
+	article := Property("Type").Select(SelectCondition{Equals: "Article"})
+	tagged := Or(
+		Property("Tags").MultiSelect(MultiSelectCondition{Contains: "go"}),
+		Property("Tags").MultiSelect(MultiSelectCondition{Contains: "rust"}),
+	)
+	recent := CreatedTime(DateCondition{PastMonth: Relative()})
+	base := And(archived, tagged)
+	query := And(article, recent)
+
+	// Act
+	result := allOf(&base, nil, &query)
+
+	// Assert
+	assert.Equal(t, And(archived, tagged, article, recent), *result)
+	assert.Equal(t, &tagged, allOf(nil, &tagged), "Or filters are kept as they are")
+}
@@ -0,0 +1,125 @@
+//go:build go1.18
+// +build go1.18
+
+package notion
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// Response bodies the parsers are seeded with, including the malformed ones Notion has been seen to send
+var fuzzSeeds = []string{
+	`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`,
+	`{"object": "list", "results": [{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+		"properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Diary"}}]},
+		"Rating": {"type": "rollup", "rollup": {"type": "array", "array": [{"type": "number", "number": 4}]}}}}],
+		"next_cursor": "240c0dcf-8334-43e5-9a01-a914c21de7e4", "has_more": true}`,
+	`{"object": "list", "results": [{"object": "block", "type": "paragraph", "paragraph": {"rich_text": []}}], "has_more": false}`,
+	`{"object": "database", "id": "99999999-abcd-efgh-1234-000000000000", "data_sources": [{"id": "1", "name": "Pages"}]}`,
+	`{"object": "list", "results": [{"object": "property_item", "id": "rel", "type": "relation", "relation": {"id": "1"}}],
+		"next_cursor": null, "has_more": false, "type": "property_item", "property_item": {"id": "rel", "type": "relation"}}`,
+	`{"object": "list", "results": [{"object": "property_item", "type": "number", "number": 1}],
+		"next_cursor": null, "has_more": false, "type": "property_item", "property_item": {"id": "num", "type": "number"}}`,
+	`{"object": "list", "results": [], "type": "property_item", "property_item": {"id": "sum", "type": "rollup"}}`,
+	`{"object": "error", "status": 429, "code": "rate_limited", "message": "You have been rate limited."}`,
+	`<html><body>502 Bad Gateway</body></html>`,
+	`{"object": "list", "results": {}}`,
+	``,
+}
+
+func FuzzDecodeQueryResponse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var response pageResponse
+		if err := decodeResponse(body, "list", &response); err != nil {
+			if !errors.Is(err, ErrInvalidResponse) {
+				t.Fatalf("Unexpected error type: %v", err)
+			}
+			return
+		}
+		cursors{}.check(response.HasMore, response.Next)
+		for _, page := range response.Results {
+			page.Title()
+			for _, property := range page.Properties {
+				property.PlainText()
+			}
+		}
+	})
+}
+
+func FuzzDecodeBlockResponse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var response blockResponse
+		if err := decodeResponse(body, "list", &response); err != nil {
+			if !errors.Is(err, ErrInvalidResponse) {
+				t.Fatalf("Unexpected error type: %v", err)
+			}
+			return
+		}
+		for _, block := range response.Results {
+			PlainText(block.RichText())
+		}
+	})
+}
+
+func FuzzDecodeDatabase(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var db Database
+		if err := decodeResponse(body, "database", &db); err != nil {
+			if !errors.Is(err, ErrInvalidResponse) {
+				t.Fatalf("Unexpected error type: %v", err)
+			}
+			return
+		}
+		db.Name()
+		for _, property := range db.Properties {
+			property.OptionNames()
+		}
+	})
+}
+
+func FuzzDecodePropertyItems(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var response propertyItemResponse
+		err := decodeResponse(body, "list", &response)
+		if err == nil {
+			var value PropertyValue
+			err = value.addItems(response)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("Unexpected error type: %v", err)
+		}
+	})
+}
+
+func FuzzNewAPIError(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed), "2")
+	}
+	f.Fuzz(func(t *testing.T, body []byte, retryAfter string) {
+		res := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {retryAfter}}}
+		apiErr := newAPIError(res, body)
+		if apiErr.Status != http.StatusTooManyRequests {
+			t.Fatalf("Status %d doesn't match the response", apiErr.Status)
+		}
+		if apiErr.RetryAfter < 0 {
+			t.Fatalf("Negative Retry-After %s parsed from %q", apiErr.RetryAfter, retryAfter)
+		}
+		if apiErr.Error() == "" {
+			t.Fatal("Empty error message")
+		}
+	})
+}
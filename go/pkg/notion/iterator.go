@@ -0,0 +1,248 @@
+package notion
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// PageIterator returns the pages matching a database query one at a time,
+// requesting each response from the Notion API only once the previous one is used up:
+//
+//	it := api.IterateDatabase(ctx, query)
+//	defer it.Close()
+//	for it.Next() {
+//		page := it.Page()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type PageIterator struct {
+	api    *ApiConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	query  Query
+
+	paths     []string
+	pathIndex int
+	started   bool // Whether a response has been retrieved for the current path
+	hasMore   bool
+
+	buffer         []Page
+	index          int
+	page           Page
+	cursor         string // Start of the next response
+	responseCursor string // Start of the response in buffer
+	retrieved      int
+	seen           cursors // Cursors returned for the current path
+	err            error
+	done           bool // Whether no more responses will be retrieved
+	closed         bool
+}
+
+// Return an iterator over the pages matching query.
+// The query is combined with api.Filter and api.Sorts as in QueryDatabase
+func (api *ApiConfig) IterateDatabase(ctx context.Context, query Query) *PageIterator {
+	query.Filter = allOf(api.Filter, query.Filter)
+	if len(query.Sorts) == 0 {
+		query.Sorts = api.Sorts
+	}
+	return api.iterate(ctx, query, "")
+}
+
+func (api *ApiConfig) iterate(ctx context.Context, query Query, cursor string) *PageIterator {
+	ctx, cancel := api.pagingContext(ctx)
+	return &PageIterator{
+		api:            api,
+		ctx:            ctx,
+		cancel:         cancel,
+		query:          query,
+		cursor:         cursor,
+		responseCursor: cursor,
+	}
+}
+
+// Advance to the next page, returning false when there are no more pages or an error occurred.
+// If ctx is cancelled or its deadline is near, iteration stops and Err returns a *PartialResultError
+func (it *PageIterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	for !it.done && it.index >= len(it.buffer) {
+		it.fetch()
+	}
+	if it.index >= len(it.buffer) {
+		return false
+	}
+	it.page = it.buffer[it.index]
+	it.page.SourceDatabaseId = it.api.DatabaseId
+	it.index++
+	it.retrieved++
+	return true
+}
+
+// Return the page Next advanced to
+func (it *PageIterator) Page() Page {
+	return it.page
+}
+
+// Return the error that stopped iteration, if any
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// Return a cursor from which a new query would continue without skipping
+// any page not yet returned by Next, or an empty string once every page was returned.
+// Pages already returned from the current response may be repeated
+func (it *PageIterator) Cursor() string {
+	if it.index < len(it.buffer) {
+		return it.responseCursor
+	}
+	return it.cursor
+}
+
+// Release the iterator's resources; only needed when stopping before Next returns false
+func (it *PageIterator) Close() {
+	it.closed = true
+	it.finish(nil)
+}
+
+func (it *PageIterator) finish(err error) {
+	if it.done {
+		return
+	}
+	it.done = true
+	it.err = err
+	it.cancel()
+}
+
+// Retrieve the next response into the buffer, or finish iterating
+func (it *PageIterator) fetch() {
+	logger := logging.GetLoggerWithContext(it.ctx)
+
+	if it.paths == nil {
+		paths, err := it.api.queryPaths(it.ctx)
+		if err != nil {
+			it.fail(err)
+			return
+		}
+		it.paths = paths
+	}
+	if it.started && !it.hasMore {
+		it.pathIndex++
+		it.started = false
+		it.cursor = "" // A starting cursor only applies to the first data source
+		it.seen = nil
+	}
+	if it.pathIndex >= len(it.paths) {
+		it.cursor = ""
+		it.finish(nil)
+		return
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.fail(err)
+		return
+	}
+	if it.retrieved >= it.api.maxPages() {
+		it.fail(ErrPageLimit)
+		return
+	}
+
+	path := it.paths[it.pathIndex]
+	if it.seen == nil {
+		it.seen = cursors{it.cursor: true}
+	}
+	response, err := it.api.queryPages(it.ctx, path, it.query, it.cursor)
+	if err == nil {
+		err = it.seen.check(response.HasMore, response.Next)
+	}
+	if err != nil {
+		it.fail(err)
+		return
+	}
+	if it.api.CompleteTruncated {
+		for i := range response.Results {
+			// Pages are still worth returning with the first items of their properties
+			if err := it.api.CompleteProperties(it.ctx, &response.Results[i]); err != nil {
+				logger.Warn().Err(err).Str("page_id", response.Results[i].Id).Msg("Unable to complete truncated properties")
+			}
+		}
+	}
+	it.buffer = response.Results
+	it.index = 0
+	it.responseCursor = it.cursor
+	it.cursor = response.Next
+	it.hasMore = response.HasMore
+	it.started = true
+	logger.Debug().
+		Str("path", path).
+		Int("pages_retrieved", len(response.Results)).
+		Bool("has_more", response.HasMore).
+		Str("cursor", response.Next).
+		Msg("Processed Notion API response")
+}
+
+// Stop iterating because of err, reporting cancellation and the page limit as a partial result
+func (it *PageIterator) fail(err error) {
+	logger := logging.GetLoggerWithContext(it.ctx)
+	if !isContextError(err) && !errors.Is(err, ErrPageLimit) {
+		// Retryable failures have already been retried by the transport
+		logger.Err(err).Send()
+		it.finish(err)
+		return
+	}
+
+	logger.Warn().
+		Err(err).
+		Int("pages_retrieved", it.retrieved).
+		Str("cursor", it.cursor).
+		Msg("Stopped paging before all pages were retrieved")
+	it.finish(&PartialResultError{
+		Cursor: it.cursor,
+		Pages:  it.retrieved,
+		Err:    err,
+	})
+}
+
+// Send the pages matching query on the returned channel as they are retrieved.
+// The error channel receives the iterator's error, or nil, once the page channel is closed.
+// Cancel ctx to stop early
+func (api *ApiConfig) StreamDatabase(ctx context.Context, query Query) (<-chan Page, <-chan error) {
+	pages := make(chan Page)
+	errs := make(chan error, 1)
+	it := api.IterateDatabase(ctx, query)
+
+	go func() {
+		defer close(errs)
+		defer close(pages)
+		defer it.Close()
+		for it.Next() {
+			select {
+			case pages <- it.Page():
+			case <-ctx.Done():
+				// The current page wasn't received, so resume from the start of its response
+				errs <- &PartialResultError{Cursor: it.responseCursor, Pages: it.retrieved - 1, Err: ctx.Err()}
+				return
+			}
+		}
+		errs <- it.Err()
+	}()
+
+	return pages, errs
+}
+
+// Collect every page from it, returning the pages retrieved so far with a partial result
+func collectPages(it *PageIterator) ([]Page, error) {
+	defer it.Close()
+	pages := []Page{}
+	for it.Next() {
+		pages = append(pages, it.Page())
+	}
+	if err := it.Err(); err != nil {
+		if IsPartial(err) {
+			return pages, err
+		}
+		return nil, err
+	}
+	return pages, nil
+}
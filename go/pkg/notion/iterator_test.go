@@ -0,0 +1,158 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Serve responses of two pages each, using the index of the next response as its cursor
+func mockNotionServerWithResponses(responses int) (*httptest.Server, *ApiConfig, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		var request pageRequest
+		json.Unmarshal(body, &request)
+		index, _ := strconv.Atoi(request.StartCursor)
+
+		response := pageResponse{
+			Object: "list",
+			Results: []Page{
+				{Id: fmt.Sprintf("page-%d-a", index)},
+				{Id: fmt.Sprintf("page-%d-b", index)},
+			},
+		}
+		if index+1 < responses {
+			response.Next = strconv.Itoa(index + 1)
+			response.HasMore = true
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		DatabaseId:  mockDatabaseId,
+		SecretToken: mockApiToken,
+	}
+	return server, api, &requests
+}
+
+func TestIteratePages(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionServerWithResponses(3)
+	defer ts.Close()
+
+	// Act
+	ids := []string{}
+	it := api.IterateDatabase(context.Background(), Query{})
+	for it.Next() {
+		ids = append(ids, it.Page().Id)
+	}
+
+	// Assert
+	require.NoError(t, it.Err())
+	assert.Equal(t, []string{"page-0-a", "page-0-b", "page-1-a", "page-1-b", "page-2-a", "page-2-b"}, ids)
+	assert.Equal(t, 3, *requests)
+	assert.Equal(t, "", it.Cursor())
+}
+
+func TestIteratePagesStopsEarly(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionServerWithResponses(3)
+	defer ts.Close()
+
+	// Act
+	it := api.IterateDatabase(context.Background(), Query{})
+	for it.Next() {
+		if it.Page().Id == "page-1-a" {
+			break
+		}
+	}
+	cursor := it.Cursor()
+	it.Close()
+
+	// Assert
+	assert.NoError(t, it.Err())
+	assert.Equal(t, 2, *requests)
+	assert.Equal(t, "1", cursor) // Resuming repeats the current response rather than skip page-1-b
+	assert.False(t, it.Next())
+}
+
+func TestIteratePagesCursorBetweenResponses(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionServerWithResponses(3)
+	defer ts.Close()
+
+	// Act
+	it := api.IterateDatabase(context.Background(), Query{})
+	defer it.Close()
+	it.Next()
+	it.Next()
+
+	// Assert
+	assert.Equal(t, "page-0-b", it.Page().Id)
+	assert.Equal(t, "1", it.Cursor())
+}
+
+func TestIteratePagesError(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 400, "code": "validation_error", "message": "Invalid filter"}`, http.StatusBadRequest)
+	defer ts.Close()
+
+	// Act
+	it := api.IterateDatabase(context.Background(), Query{})
+	next := it.Next()
+
+	// Assert
+	assert.False(t, next)
+	assert.ErrorIs(t, it.Err(), ErrValidation)
+	assert.False(t, IsPartial(it.Err()))
+}
+
+func TestStreamPages(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionServerWithResponses(2)
+	defer ts.Close()
+
+	// Act
+	ids := []string{}
+	pages, errs := api.StreamDatabase(context.Background(), Query{})
+	for page := range pages {
+		ids = append(ids, page.Id)
+	}
+
+	// Assert
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"page-0-a", "page-0-b", "page-1-a", "page-1-b"}, ids)
+}
+
+func TestStreamPagesStopsWhenCancelled(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionServerWithResponses(10)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Act
+	pages, errs := api.StreamDatabase(ctx, Query{})
+	first := <-pages
+	cancel()
+	for range pages {
+	}
+	err := <-errs
+
+	// Assert
+	assert.Equal(t, "page-0-a", first.Id)
+	var partial *PartialResultError
+	require.ErrorAs(t, err, &partial)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, *requests, 10)
+}
@@ -0,0 +1,123 @@
+package notion
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Credentials of a public integration, which each workspace authorizes through OAuth,
+// per https://developers.notion.com/docs/authorization#public-integration-auth-flow-set-up
+type OAuthConfig struct {
+	ClientId     string
+	ClientSecret string
+	RedirectUri  string // Must match a redirect URI of the integration
+}
+
+// ===============================================================
+// Access token for a workspace that authorized a public integration,
+// per https://developers.notion.com/reference/create-a-token
+// ---------------------------------------------------------------
+type OAuthToken struct {
+	AccessToken          string      `json:"access_token"`
+	TokenType            string      `json:"token_type,omitempty"`
+	BotId                string      `json:"bot_id"`
+	WorkspaceId          string      `json:"workspace_id"`
+	WorkspaceName        string      `json:"workspace_name,omitempty"`
+	WorkspaceIcon        string      `json:"workspace_icon,omitempty"`
+	Owner                *OAuthOwner `json:"owner,omitempty"`
+	DuplicatedTemplateId string      `json:"duplicated_template_id,omitempty"`
+}
+
+type OAuthOwner struct {
+	Type      string `json:"type"` // "user" or "workspace"
+	User      *User  `json:"user,omitempty"`
+	Workspace bool   `json:"workspace,omitempty"`
+}
+
+// ===============================================================
+
+type oauthTokenRequest struct {
+	GrantType   string `json:"grant_type"`
+	Code        string `json:"code"`
+	RedirectUri string `json:"redirect_uri,omitempty"`
+}
+
+// Return the URL of Notion's authorization page, to which users are redirected
+// to choose the pages to share with the integration. state is returned to the redirect URI
+// unchanged, to check that the callback follows a request made by the same user
+func (api *ApiConfig) AuthorizeUrl(oauth OAuthConfig, state string) string {
+	params := url.Values{
+		"client_id":     {oauth.ClientId},
+		"response_type": {"code"},
+		"owner":         {"user"},
+		"state":         {state},
+	}
+	if oauth.RedirectUri != "" {
+		params.Set("redirect_uri", oauth.RedirectUri)
+	}
+	return fmt.Sprintf("%s/oauth/authorize?%s", api.Url, params.Encode())
+}
+
+// Exchange the code Notion passes to the redirect URI for an access token
+func (api *ApiConfig) ExchangeOAuthCode(ctx context.Context, oauth OAuthConfig, code string) (*OAuthToken, error) {
+	defer logging.LogFunction(
+		"oauth.ExchangeOAuthCode", time.Now(), "Exchanging OAuth code", map[string]interface{}{},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	credentials := base64.StdEncoding.EncodeToString([]byte(oauth.ClientId + ":" + oauth.ClientSecret))
+
+	// Not retried, since each code can only be exchanged once
+	body, err := api.do(ctx, apiRequest{
+		Method: "POST",
+		Path:   "/oauth/token",
+		Body: oauthTokenRequest{
+			GrantType:   "authorization_code",
+			Code:        code,
+			RedirectUri: oauth.RedirectUri,
+		},
+		Headers: map[string]string{"Authorization": "Basic " + credentials},
+	})
+	if err != nil {
+		logger.Err(err).Msg("Unable to exchange OAuth code")
+		return nil, err
+	}
+
+	// Token responses have no object type
+	var token OAuthToken
+	if err := decodeResponse(body, "", &token); err != nil {
+		logger.Err(err).Send()
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("Unable to exchange OAuth code: no access token returned")
+	}
+	logger.Info().Str("workspace_id", token.WorkspaceId).Str("bot_id", token.BotId).Msg("Exchanged OAuth code")
+	return &token, nil
+}
+
+// Return a copy of api that makes requests with the access token of a workspace
+// and retrieves pages from its databases. Users and schemas are cached separately, as they differ between workspaces
+func (api *ApiConfig) WithToken(accessToken string, databaseIds []string) *ApiConfig {
+	copy := *api
+	copy.SecretToken = accessToken
+	copy.DatabaseId = ""
+	copy.DatabaseIds = nil
+	if len(databaseIds) > 0 {
+		copy.DatabaseId = databaseIds[0]
+		copy.DatabaseIds = databaseIds[1:]
+	}
+	copy.DataSourceIds = nil
+	copy.createdTypes = map[string]string{}
+	if api.Users != nil {
+		copy.Users = NewUserCache()
+	}
+	if api.DataSources != nil {
+		copy.DataSources = NewDataSourceCache()
+	}
+	return &copy
+}
@@ -0,0 +1,101 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mockOAuth = OAuthConfig{
+	ClientId:     "463558a3-725e-4f37-b6d3-0889894f68de",
+	ClientSecret: "secret_oauth",
+	RedirectUri:  "https://example.com/oauth/callback",
+}
+
+func TestAuthorizeUrl(t *testing.T) {
+	// Arrange
+	api := NewApiConfig()
+
+	// Act
+	authorize, err := url.Parse(api.AuthorizeUrl(mockOAuth, "c2f6a5f8"))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.notion.com/v1/oauth/authorize", authorize.Scheme+"://"+authorize.Host+authorize.Path)
+	assert.Equal(t, url.Values{
+		"client_id":     {mockOAuth.ClientId},
+		"response_type": {"code"},
+		"owner":         {"user"},
+		"state":         {"c2f6a5f8"},
+		"redirect_uri":  {mockOAuth.RedirectUri},
+	}, authorize.Query())
+}
+
+func TestExchangeOAuthCode(t *testing.T) {
+	// Arrange
+	var authorization, path string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, path = r.Header.Get("Authorization"), r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{
+			"access_token": "secret_workspace",
+			"token_type": "bearer",
+			"bot_id": "b3414d65-1224-4a6c-9f4d-1c1d6a1a4b2e",
+			"workspace_id": "2f9d5a07-6a1c-4b8e-8e43-7f5c3b0a9d11",
+			"workspace_name": "Ada's Notion",
+			"owner": {"type": "user", "user": {"object": "user", "id": "` + mockUserId + `"}}
+		}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, SecretToken: mockApiToken}
+
+	// Act
+	token, err := api.ExchangeOAuthCode(context.Background(), mockOAuth, "a1b2c3")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/token", path)
+	assert.Equal(t, "Basic NDYzNTU4YTMtNzI1ZS00ZjM3LWI2ZDMtMDg4OTg5NGY2OGRlOnNlY3JldF9vYXV0aA==", authorization)
+	assert.JSONEq(t, `{"grant_type": "authorization_code", "code": "a1b2c3", "redirect_uri": "https://example.com/oauth/callback"}`, string(body))
+	assert.Equal(t, "secret_workspace", token.AccessToken)
+	assert.Equal(t, "2f9d5a07-6a1c-4b8e-8e43-7f5c3b0a9d11", token.WorkspaceId)
+	assert.Equal(t, mockUserId, token.Owner.User.Id)
+}
+
+func TestExchangeOAuthCodeRejected(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 400, "code": "invalid_grant", "message": "Invalid code."}`, http.StatusBadRequest)
+	defer ts.Close()
+
+	// Act
+	token, err := api.ExchangeOAuthCode(context.Background(), mockOAuth, "used")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, token)
+}
+
+func TestWithToken(t *testing.T) {
+	// Arrange
+	api := NewApiConfig()
+	api.SecretToken = mockApiToken
+	api.DatabaseId = mockDatabaseId
+	api.Users.Add(User{Id: mockUserId, Name: "Ada Lovelace"})
+
+	// Act
+	workspace := api.WithToken("secret_workspace", []string{"reading-list", "zettelkasten"})
+
+	// Assert
+	assert.Equal(t, "secret_workspace", workspace.SecretToken)
+	assert.Equal(t, []string{"reading-list", "zettelkasten"}, workspace.GetDatabaseIds())
+	assert.Equal(t, 0, workspace.Users.Len())
+	assert.Same(t, api.RateLimiter, workspace.RateLimiter)
+	assert.Equal(t, mockApiToken, api.SecretToken)
+}
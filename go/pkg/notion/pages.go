@@ -1,53 +1,79 @@
 package notion
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/jeffrosenberg/random-notion/pkg/logging"
-	"github.com/rs/zerolog"
 )
 
 type Page struct {
-	Id             string `json:"id"`
-	CreatedTime    string `json:"created_time"`
-	LastEditedTime string `json:"last_edited_time"`
-	Url            string `json:"url"`
+	Id             string                   `json:"id"`
+	CreatedTime    string                   `json:"created_time"`
+	LastEditedTime string                   `json:"last_edited_time"`
+	Url            string                   `json:"url"`
+	CreatedBy      *User                    `json:"created_by,omitempty"`
+	LastEditedBy   *User                    `json:"last_edited_by,omitempty"`
+	Properties     map[string]PropertyValue `json:"properties,omitempty"`
+	Archived       bool                     `json:"archived,omitempty"`
+	InTrash        bool                     `json:"in_trash,omitempty"`
+	// The database the page was retrieved from; not part of Notion's page object
+	SourceDatabaseId string `json:"source_database_id,omitempty"`
+}
+
+// Return the plain text of the page's title property
+func (p Page) Title() string {
+	for _, property := range p.Properties {
+		if property.Type == PROPERTY_TITLE {
+			return PlainText(property.Title)
+		}
+	}
+	return ""
+}
+
+// Return whether the page has been archived or moved to the trash
+func (p Page) Removed() bool {
+	return p.Archived || p.InTrash
+}
+
+// Return whether the page was created by one of the given users
+func (p Page) CreatedByAny(userIds ...string) bool {
+	if p.CreatedBy == nil {
+		return false
+	}
+	for _, id := range userIds {
+		if p.CreatedBy.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the named property and whether the page has it
+func (p Page) Property(name string) (PropertyValue, bool) {
+	property, ok := p.Properties[name]
+	return property, ok
 }
 
 type PageGetter interface {
 	GetPages() ([]Page, error)
 	GetPagesSinceTime(time.Time) ([]Page, error)
+	GetPagesWithContext(context.Context) ([]Page, error)
+	GetPagesSinceTimeWithContext(context.Context, time.Time) ([]Page, error)
 	GetDatabaseId() string
 }
 
 // ===============================================================
 // Notion query request body,
 // per https://developers.notion.com/reference/post-database-query
-// There are other properties, I've only defined what I need
 // ---------------------------------------------------------------
-type filterDateClause struct {
-	After      string `json:"after,omitempty"`
-	Before     string `json:"before,omitempty"`
-	Equals     string `json:"equals,omitempty"`
-	OnOrAfter  string `json:"on_or_after,omitempty"`
-	OnOrBefore string `json:"on_or_before,omitempty"`
-}
-
-type filterDef struct {
-	Property string           `json:"property"`
-	Date     filterDateClause `json:"date"`
-}
-
 type pageRequest struct {
-	Filter      filterDef `json:"filter,omitempty"`
-	PageSize    uint8     `json:"page_size"`
-	StartCursor string    `json:"start_cursor,omitempty"`
+	Filter      *Filter `json:"filter,omitempty"`
+	Sorts       []Sort  `json:"sorts,omitempty"`
+	PageSize    uint8   `json:"page_size"`
+	StartCursor string  `json:"start_cursor,omitempty"`
 }
 
 // ===============================================================
@@ -59,107 +85,279 @@ type pageResponse struct {
 	HasMore bool   `json:"has_more"`
 }
 
-var logger *zerolog.Logger
-
-// Return pages from the Notion API, filtered by time and starting at an optional cursor string
-func (api *ApiConfig) getPages(sinceTime *time.Time, cursor string) ([]Page, error) {
+// Return pages from the Notion API matching query and starting at an optional cursor string.
+// If ctx is cancelled or its deadline is near, the pages retrieved so far
+// are returned along with a *PartialResultError
+func (api *ApiConfig) getPages(ctx context.Context, query Query, cursor string) ([]Page, error) {
 	defer logging.LogFunction(
 		"pages.getPages", time.Now(), "Getting pages from API",
 		map[string]interface{}{
-			"since_time": sinceTime,
-			"cursor":     cursor,
+			"filter": query.Filter,
+			"sorts":  query.Sorts,
+			"cursor": cursor,
 		},
 	)
-	pages := []Page{}
-	hasMore := true
-	logger = logging.GetLogger()
+	return collectPages(api.iterate(ctx, query, cursor))
+}
 
-	for hasMore == true {
-		response, err := api.queryPages(sinceTime, cursor)
-		if err != nil {
-			logger.Err(err).Send()
-			return nil, err // More robust error handling would be nice, but skipping as this is a hobby project
-		}
-		pages = append(pages, response.Results...)
-		hasMore = response.HasMore
-		cursor = response.Next
-		logger.Debug().
-			Int("pages_retrieved", len(response.Results)).
-			Bool("has_more", response.HasMore).
-			Str("cursor", response.Next).
-			Msg("Processed Notion API response")
+// Derive a context that expires DeadlineBuffer before the deadline of ctx,
+// leaving the caller time to respond with whatever was retrieved
+func (api *ApiConfig) pagingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && api.DeadlineBuffer > 0 {
+		return context.WithDeadline(ctx, deadline.Add(-api.DeadlineBuffer))
 	}
-
-	return pages, nil
+	return context.WithCancel(ctx)
 }
 
 // Return all pages from the Notion API
 func (api *ApiConfig) GetPages() ([]Page, error) {
-	return api.getPages(nil, "")
+	return api.GetPagesWithContext(context.Background())
 }
 
 // Return pages from the Notion API, filtered by time
 func (api *ApiConfig) GetPagesSinceTime(sinceTime time.Time) ([]Page, error) {
+	return api.GetPagesSinceTimeWithContext(context.Background(), sinceTime)
+}
+
+// Return all pages from the Notion API, stopping early if ctx is done
+func (api *ApiConfig) GetPagesWithContext(ctx context.Context) ([]Page, error) {
+	return api.QueryDatabase(ctx, Query{})
+}
+
+// Return pages from the Notion API, filtered by time, stopping early if ctx is done
+func (api *ApiConfig) GetPagesSinceTimeWithContext(ctx context.Context, sinceTime time.Time) ([]Page, error) {
 	if sinceTime.IsZero() {
-		return api.GetPages()
+		return api.GetPagesWithContext(ctx)
 	}
-	return api.getPages(&sinceTime, "")
+	pages, err := api.QueryDatabase(ctx, Query{Filter: api.createdSinceFilter(sinceTime)})
+
+	// Without a validated schema, the created property may not exist
+	if _, validated := api.createdType(); !validated && errors.Is(err, ErrValidation) {
+		logging.GetLoggerWithContext(ctx).Warn().
+			Err(err).
+			Str("property", api.createdProperty()).
+			Msg("Created property filter was rejected, filtering on created_time instead")
+		filter := CreatedTime(DateCondition{After: sinceTime.Format(ISO_TIME)})
+		return api.QueryDatabase(ctx, Query{Filter: &filter})
+	}
+	return pages, err
 }
 
-func (api *ApiConfig) GetDatabaseId() string {
-	return api.DatabaseId
+// PageChecker retrieves a single page, to check it still exists
+type PageChecker interface {
+	GetPage(context.Context, string) (*Page, error)
 }
 
-func (api *ApiConfig) queryPages(sinceTime *time.Time, cursor string) (pageResponse, error) {
-	url, err := url.Parse(fmt.Sprintf("%s/databases/%s/query", api.Url, api.DatabaseId))
+// Return the page with the given ID. Pages that have been deleted,
+// or are no longer shared with the integration, return ErrObjectNotFound
+func (api *ApiConfig) GetPage(ctx context.Context, pageId string) (*Page, error) {
+	defer logging.LogFunction(
+		"pages.GetPage", time.Now(), "Getting page",
+		map[string]interface{}{"page_id": pageId},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	body, err := api.do(ctx, apiRequest{
+		Method:     "GET",
+		Path:       fmt.Sprintf("/pages/%s", pageId),
+		Idempotent: true,
+	})
 	if err != nil {
-		return pageResponse{}, fmt.Errorf("Unable to parse URL: %w", err)
+		logger.Err(err).Msg("Unable to retrieve page")
+		return nil, err
 	}
+	logger.Trace().RawJSON("page_response_json", body).Msg("Receieved Notion API response")
 
-	client := &http.Client{}
-	postBody := pageRequest{
-		PageSize:    api.PageSize,
-		StartCursor: cursor,
+	var page Page
+	if err := decodeResponse(body, "page", &page); err != nil {
+		logger.Err(err).Str("page_id", pageId).Send()
+		return nil, err
+	}
+	return &page, nil
+}
+
+// PageUpdater sets property values of a page in Notion
+type PageUpdater interface {
+	UpdatePage(context.Context, string, map[string]PropertyValue) (*Page, error)
+}
+
+type pageUpdateRequest struct {
+	Properties map[string]PropertyValue `json:"properties"`
+}
+
+// Set the given property values of a page, leaving its other properties as they are,
+// and return the page as updated. Requires the integration to have the update content capability
+func (api *ApiConfig) UpdatePage(ctx context.Context, pageId string, properties map[string]PropertyValue) (*Page, error) {
+	defer logging.LogFunction(
+		"pages.UpdatePage", time.Now(), "Updating page",
+		map[string]interface{}{"page_id": pageId, "properties": len(properties)},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	body, err := api.do(ctx, apiRequest{
+		Method: "PATCH",
+		Path:   fmt.Sprintf("/pages/%s", pageId),
+		Body:   pageUpdateRequest{Properties: properties},
+		// Properties are set to absolute values, so sending them twice is harmless
+		Idempotent: true,
+	})
+	if err != nil {
+		logger.Err(err).Msg("Unable to update page")
+		return nil, err
 	}
-	if sinceTime != nil {
-		postBody.Filter = filterDef{
-			Property: "Created",
-			Date: filterDateClause{
-				After: sinceTime.Format(ISO_TIME),
-			},
+	logger.Trace().RawJSON("page_response_json", body).Msg("Receieved Notion API response")
+
+	var page Page
+	if err := decodeResponse(body, "page", &page); err != nil {
+		logger.Err(err).Str("page_id", pageId).Send()
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Most blocks Notion accepts as the initial content of a new page
+const MAX_NEW_PAGE_CHILDREN = 100
+
+// PageCreator adds pages to a database
+type PageCreator interface {
+	CreatePage(context.Context, NewPage) (*Page, error)
+}
+
+// A page to create in a database. The title property can be keyed as "title",
+// its ID in every database, rather than by name
+type NewPage struct {
+	DatabaseId string // api.DatabaseId if empty
+	Properties map[string]PropertyValue
+	Children   []Block // Initial content, at most MAX_NEW_PAGE_CHILDREN blocks
+}
+
+type PageParent struct {
+	Type         string `json:"type"` // "database_id" or "data_source_id"
+	DatabaseId   string `json:"database_id,omitempty"`
+	DataSourceId string `json:"data_source_id,omitempty"`
+}
+
+type pageCreateRequest struct {
+	Parent     PageParent               `json:"parent"`
+	Properties map[string]PropertyValue `json:"properties"`
+	Children   []Block                  `json:"children,omitempty"`
+}
+
+// Create a page in a database, returning it as created by Notion.
+// On versions with data sources, the page is added to the first data source queried.
+// Requires the integration to have the insert content capability
+func (api *ApiConfig) CreatePage(ctx context.Context, page NewPage) (*Page, error) {
+	databaseId := page.DatabaseId
+	if databaseId == "" {
+		databaseId = api.DatabaseId
+	}
+	defer logging.LogFunction(
+		"pages.CreatePage", time.Now(), "Creating page",
+		map[string]interface{}{"database_id": databaseId, "children": len(page.Children)},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	if databaseId == "" {
+		return nil, fmt.Errorf("Unable to create page: no database given")
+	}
+	if len(page.Children) > MAX_NEW_PAGE_CHILDREN {
+		return nil, fmt.Errorf("Unable to create page: %d blocks of content given, at most %d allowed",
+			len(page.Children), MAX_NEW_PAGE_CHILDREN)
+	}
+
+	request := pageCreateRequest{
+		Parent:     PageParent{Type: "database_id", DatabaseId: databaseId},
+		Properties: page.Properties,
+		Children:   page.Children,
+	}
+	database := api.WithDatabase(databaseId)
+	if database.usesDataSources() {
+		ids, err := database.dataSourceIds(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create page: %w", err)
 		}
+		request.Parent = PageParent{Type: "data_source_id", DataSourceId: ids[0]}
 	}
-	jsonValue, _ := json.Marshal(postBody)
-	logger.Trace().
-		Str("request_verb", "POST").
-		Str("request_url", url.String()).
-		RawJSON("request_json", jsonValue).
-		Msg("Prepared Notion API request")
-	req, err := http.NewRequest("POST", url.String(), bytes.NewBuffer(jsonValue))
+
+	// Not retried, since a retry after a lost response would create the page twice
+	body, err := api.do(ctx, apiRequest{
+		Method: "POST",
+		Path:   "/pages",
+		Body:   request,
+	})
 	if err != nil {
-		return pageResponse{}, fmt.Errorf("Unable to create request: %w", err)
+		logger.Err(err).Msg("Unable to create page")
+		return nil, err
 	}
-	req.Header.Set("Notion-Version", "2021-08-16")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", api.SecretToken))
-	req.Header.Set("Content-Type", "application/json")
+	logger.Trace().RawJSON("page_response_json", body).Msg("Receieved Notion API response")
 
-	res, err := client.Do(req)
-	if err != nil {
-		return pageResponse{}, fmt.Errorf("Unable to retrieve response: %w", err)
+	var created Page
+	if err := decodeResponse(body, "page", &created); err != nil {
+		logger.Err(err).Send()
+		return nil, err
+	}
+	created.SourceDatabaseId = databaseId
+	return &created, nil
+}
+
+// EditedPageGetter retrieves pages edited since a time, to keep cached pages up to date
+type EditedPageGetter interface {
+	GetPagesEditedSinceTimeWithContext(context.Context, time.Time) ([]Page, error)
+}
+
+// Return pages from the Notion API edited since sinceTime, oldest edit first.
+// The window opens api.SyncOverlap early, since Notion rounds last_edited_time
+// down to the minute and its clock may not agree with ours
+func (api *ApiConfig) GetPagesEditedSinceTimeWithContext(ctx context.Context, sinceTime time.Time) ([]Page, error) {
+	query := Query{Sorts: []Sort{SortByTimestamp(PROPERTY_LAST_EDITED_TIME, ASCENDING)}}
+	if !sinceTime.IsZero() {
+		filter := LastEditedTime(DateCondition{OnOrAfter: sinceTime.Add(-api.syncOverlap()).Format(ISO_TIME)})
+		query.Filter = &filter
+	}
+	return api.QueryDatabase(ctx, query)
+}
+
+func (api *ApiConfig) syncOverlap() time.Duration {
+	if api.SyncOverlap == 0 {
+		return DEFAULT_SYNC_OVERLAP
 	}
+	return api.SyncOverlap
+}
 
-	if res.StatusCode != http.StatusOK {
-		return pageResponse{}, fmt.Errorf("Received invalid status: %s", res.Status)
+// Return the pages from the Notion API matching query, stopping early if ctx is done.
+// The query's filter is combined with api.Filter, and api.Sorts apply if it has none
+func (api *ApiConfig) QueryDatabase(ctx context.Context, query Query) ([]Page, error) {
+	query.Filter = allOf(api.Filter, query.Filter)
+	if len(query.Sorts) == 0 {
+		query.Sorts = api.Sorts
 	}
+	return api.getPages(ctx, query, "")
+}
 
-	body, err := io.ReadAll(res.Body)
-	defer res.Body.Close()
+func (api *ApiConfig) GetDatabaseId() string {
+	return api.DatabaseId
+}
+
+func (api *ApiConfig) queryPages(ctx context.Context, path string, query Query, cursor string) (pageResponse, error) {
+	postBody := pageRequest{
+		Filter:      query.Filter,
+		Sorts:       query.Sorts,
+		PageSize:    api.PageSize,
+		StartCursor: cursor,
+	}
+
+	// Querying doesn't modify anything, so it's safe to retry from the same cursor
+	body, err := api.do(ctx, apiRequest{
+		Method:     "POST",
+		Path:       path,
+		Body:       postBody,
+		Idempotent: true,
+	})
 	if err != nil {
-		return pageResponse{}, fmt.Errorf("Enable to read response body: %w", err)
+		return pageResponse{}, err
 	}
-	logger.Trace().RawJSON("page_response_json", body).Msg("Receieved Notion API response")
+	logging.GetLoggerWithContext(ctx).Trace().RawJSON("page_response_json", body).Msg("Receieved Notion API response")
 
 	var pageResponse pageResponse
-	json.Unmarshal(body, &pageResponse)
+	if err := decodeResponse(body, "list", &pageResponse); err != nil {
+		return pageResponse, err
+	}
 	return pageResponse, nil
 }
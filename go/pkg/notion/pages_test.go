@@ -1,11 +1,16 @@
 package notion
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRetrievePagesWithEmptyTime(t *testing.T) {
@@ -57,16 +62,20 @@ func TestRetrievePagesWithEmptyTime(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
-			CreatedTime:    "2021-11-05T12:54:00.000Z",
-			LastEditedTime: "2021-11-05T12:55:00.000Z",
-			Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Id:               "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Properties:       mockCreatedProperties("2021-11-05T12:54:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "5331da24-6597-4f2d-a684-fd94a0f3278a",
-			CreatedTime:    "2021-11-01T01:01:00.000Z",
-			LastEditedTime: "2021-11-01T13:24:00.000Z",
-			Url:            "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Id:               "5331da24-6597-4f2d-a684-fd94a0f3278a",
+			CreatedTime:      "2021-11-01T01:01:00.000Z",
+			LastEditedTime:   "2021-11-01T13:24:00.000Z",
+			Url:              "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Properties:       mockCreatedProperties("2021-11-01T01:01:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
@@ -129,16 +138,20 @@ func TestRetrieveAllPages(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
-			CreatedTime:    "2021-11-05T12:54:00.000Z",
-			LastEditedTime: "2021-11-05T12:55:00.000Z",
-			Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Id:               "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Properties:       mockCreatedProperties("2021-11-05T12:54:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "5331da24-6597-4f2d-a684-fd94a0f3278a",
-			CreatedTime:    "2021-11-01T01:01:00.000Z",
-			LastEditedTime: "2021-11-01T13:24:00.000Z",
-			Url:            "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Id:               "5331da24-6597-4f2d-a684-fd94a0f3278a",
+			CreatedTime:      "2021-11-01T01:01:00.000Z",
+			LastEditedTime:   "2021-11-01T13:24:00.000Z",
+			Url:              "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Properties:       mockCreatedProperties("2021-11-01T01:01:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
@@ -281,22 +294,28 @@ func TestRetrievePageCollection(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
-			CreatedTime:    "2021-11-05T12:54:00.000Z",
-			LastEditedTime: "2021-11-05T12:55:00.000Z",
-			Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Id:               "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Properties:       mockCreatedProperties("2021-11-05T12:54:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "5331da24-6597-4f2d-a684-fd94a0f3278a",
-			CreatedTime:    "2021-11-01T01:01:00.000Z",
-			LastEditedTime: "2021-11-01T13:24:00.000Z",
-			Url:            "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Id:               "5331da24-6597-4f2d-a684-fd94a0f3278a",
+			CreatedTime:      "2021-11-01T01:01:00.000Z",
+			LastEditedTime:   "2021-11-01T13:24:00.000Z",
+			Url:              "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Properties:       mockCreatedProperties("2021-11-01T01:01:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "240c0dcf-8334-43e5-9a01-a914c21de7e4",
-			CreatedTime:    "2021-12-12T23:51:00.000Z",
-			LastEditedTime: "2021-12-25T13:47:00.000Z",
-			Url:            "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Id:               "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+			CreatedTime:      "2021-12-12T23:51:00.000Z",
+			LastEditedTime:   "2021-12-25T13:47:00.000Z",
+			Url:              "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Properties:       mockCreatedProperties("2021-12-12T23:51:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
@@ -386,16 +405,18 @@ func TestRetrievePagesStartingWithCursor(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "240c0dcf-8334-43e5-9a01-a914c21de7e4",
-			CreatedTime:    "2021-12-12T23:51:00.000Z",
-			LastEditedTime: "2021-12-25T13:47:00.000Z",
-			Url:            "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Id:               "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+			CreatedTime:      "2021-12-12T23:51:00.000Z",
+			LastEditedTime:   "2021-12-25T13:47:00.000Z",
+			Url:              "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Properties:       mockCreatedProperties("2021-12-12T23:51:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
 	ts, api := mockNotionServerWithPaging([]string{mockData1, mockData2}, http.StatusOK)
 	defer ts.Close()
-	pages, err := api.getPages(nil, mockCursor)
+	pages, err := api.getPages(context.Background(), Query{}, mockCursor)
 
 	if assert.NoError(t, err) {
 		assert.NotNil(t, pages)
@@ -471,10 +492,12 @@ func TestRetrievePagesStartingWithTime(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "240c0dcf-8334-43e5-9a01-a914c21de7e4",
-			CreatedTime:    "2021-12-12T23:51:00.000Z",
-			LastEditedTime: "2021-12-25T13:47:00.000Z",
-			Url:            "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Id:               "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+			CreatedTime:      "2021-12-12T23:51:00.000Z",
+			LastEditedTime:   "2021-12-25T13:47:00.000Z",
+			Url:              "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Properties:       mockCreatedProperties("2021-12-12T23:51:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
@@ -558,22 +581,28 @@ func TestRetrieveAllPagesWhenNoStartTime(t *testing.T) {
 
 	expected := []Page{
 		{
-			Id:             "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
-			CreatedTime:    "2021-11-05T12:54:00.000Z",
-			LastEditedTime: "2021-11-05T12:55:00.000Z",
-			Url:            "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Id:               "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			Properties:       mockCreatedProperties("2021-11-05T12:54:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "5331da24-6597-4f2d-a684-fd94a0f3278a",
-			CreatedTime:    "2021-11-01T01:01:00.000Z",
-			LastEditedTime: "2021-11-01T13:24:00.000Z",
-			Url:            "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Id:               "5331da24-6597-4f2d-a684-fd94a0f3278a",
+			CreatedTime:      "2021-11-01T01:01:00.000Z",
+			LastEditedTime:   "2021-11-01T13:24:00.000Z",
+			Url:              "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a",
+			Properties:       mockCreatedProperties("2021-11-01T01:01:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 		{
-			Id:             "240c0dcf-8334-43e5-9a01-a914c21de7e4",
-			CreatedTime:    "2021-12-12T23:51:00.000Z",
-			LastEditedTime: "2021-12-25T13:47:00.000Z",
-			Url:            "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Id:               "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+			CreatedTime:      "2021-12-12T23:51:00.000Z",
+			LastEditedTime:   "2021-12-25T13:47:00.000Z",
+			Url:              "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4",
+			Properties:       mockCreatedProperties("2021-12-12T23:51:00.000Z"),
+			SourceDatabaseId: mockDatabaseId,
 		},
 	}
 
@@ -586,3 +615,318 @@ func TestRetrieveAllPagesWhenNoStartTime(t *testing.T) {
 		assert.EqualValues(t, expected, pages)
 	}
 }
+
+func TestRetrievePagesReturnsPartialResultsWhenCancelled(t *testing.T) {
+	mockData := []string{
+		`{
+			"object": "list",
+			"results": [
+				{
+					"object": "page",
+					"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+					"created_time": "2021-11-05T12:54:00.000Z",
+					"last_edited_time": "2021-11-05T12:55:00.000Z",
+					"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3"
+				}
+			],
+			"next_cursor": "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+			"has_more": true
+		}`,
+		`{
+			"object": "list",
+			"results": [],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+	}
+
+	expected := []Page{
+		{
+			Id:               "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			CreatedTime:      "2021-11-05T12:54:00.000Z",
+			LastEditedTime:   "2021-11-05T12:55:00.000Z",
+			Url:              "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+			SourceDatabaseId: mockDatabaseId,
+		},
+	}
+
+	// Cancel the context while the second page of results is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests := 0
+	ts, api := mockNotionServerWithPaging(mockData, http.StatusOK)
+	defer ts.Close()
+	handler := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			io.ReadAll(r.Body) // Drain the body so the server notices the client disconnecting
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	pages, err := api.GetPagesWithContext(ctx)
+
+	var partial *PartialResultError
+	if assert.ErrorAs(t, err, &partial) {
+		assert.True(t, IsPartial(err))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, mockCursor, partial.Cursor)
+		assert.Equal(t, 1, partial.Pages)
+	}
+	assert.EqualValues(t, expected, pages)
+}
+
+func TestRetrievePagesStopsBeforeDeadline(t *testing.T) {
+	requests := 0
+	ts, api := mockNotionServer(`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`, http.StatusOK)
+	defer ts.Close()
+	handler := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler.ServeHTTP(w, r)
+	})
+	api.DeadlineBuffer = time.Minute
+
+	// The deadline is already inside the buffer, so no request should be made
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pages, err := api.GetPagesSinceTimeWithContext(ctx, time.Now())
+
+	assert.True(t, IsPartial(err))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, pages)
+	assert.Equal(t, 0, requests)
+}
+
+func TestRetrievePagesEditedSinceTime(t *testing.T) {
+	since := time.Date(2021, 11, 5, 12, 54, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		since    time.Time
+		overlap  time.Duration
+		expected *Filter
+	}{
+		{"default overlap", since, 0, &Filter{Timestamp: PROPERTY_LAST_EDITED_TIME, LastEditedTime: &DateCondition{OnOrAfter: "2021-11-05T12:52:00+0000"}}},
+		{"configured overlap", since, 10 * time.Second, &Filter{Timestamp: PROPERTY_LAST_EDITED_TIME, LastEditedTime: &DateCondition{OnOrAfter: "2021-11-05T12:53:50+0000"}}},
+		{"never synced", time.Time{}, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var request pageRequest
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &request)
+				w.Write([]byte(`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`))
+			}))
+			defer ts.Close()
+			api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, SyncOverlap: test.overlap}
+
+			// Act
+			_, err := api.GetPagesEditedSinceTimeWithContext(context.Background(), test.since)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, request.Filter)
+			assert.Equal(t, []Sort{{Timestamp: PROPERTY_LAST_EDITED_TIME, Direction: ASCENDING}}, request.Sorts)
+		})
+	}
+}
+
+func TestGetPageInTrash(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{
+		"object": "page",
+		"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+		"created_time": "2021-11-05T12:54:00.000Z",
+		"last_edited_time": "2021-12-10T08:00:00.000Z",
+		"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+		"archived": true,
+		"in_trash": true
+	}`, http.StatusOK)
+	defer ts.Close()
+
+	// Act
+	page, err := api.GetPage(context.Background(), "3350ba04-48b1-43e3-8726-1b1e9828b2b3")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, page.Archived)
+	assert.True(t, page.InTrash)
+	assert.True(t, page.Removed())
+}
+
+func TestGetPageDeleted(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find page with ID: 3350ba04-48b1-43e3-8726-1b1e9828b2b3."}`, http.StatusNotFound)
+	defer ts.Close()
+
+	// Act
+	page, err := api.GetPage(context.Background(), "3350ba04-48b1-43e3-8726-1b1e9828b2b3")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	assert.Nil(t, page)
+}
+
+func TestUpdatePage(t *testing.T) {
+	// Arrange
+	var method, path string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{
+			"object": "page",
+			"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+			"last_edited_time": "2021-12-10T08:00:00.000Z",
+			"properties": {"Times Surfaced": {"id": "a%3Db", "type": "number", "number": 3}}
+		}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL}
+	count := float64(3)
+
+	// Act
+	page, err := api.UpdatePage(context.Background(), "3350ba04-48b1-43e3-8726-1b1e9828b2b3", map[string]PropertyValue{
+		"Last Surfaced":  {Date: &DateValue{Start: "2021-12-10"}},
+		"Times Surfaced": {Number: &count},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, method)
+	assert.Equal(t, "/pages/3350ba04-48b1-43e3-8726-1b1e9828b2b3", path)
+	assert.JSONEq(t, `{"properties": {"Last Surfaced": {"date": {"start": "2021-12-10"}}, "Times Surfaced": {"number": 3}}}`, string(body))
+	assert.Equal(t, float64(3), *page.Properties["Times Surfaced"].Number)
+}
+
+func TestCreatePage(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		expected string
+	}{
+		{"database", VERSION_2022_06_28, `{"type": "database_id", "database_id": "` + mockDatabaseId + `"}`},
+		{"data source", VERSION_2025_09_03, `{"type": "data_source_id", "data_source_id": "c174b72c-d782-432f-8dc0-b647e1c96df6"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var request map[string]json.RawMessage
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					w.Write([]byte(`{"object": "database", "id": "` + mockDatabaseId + `", "data_sources": [{"id": "c174b72c-d782-432f-8dc0-b647e1c96df6", "name": "Reading"}]}`))
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &request)
+				w.Write([]byte(`{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3", "url": "https://www.notion.so/Captured-3350ba0448b143e387261b1e9828b2b3"}`))
+			}))
+			defer ts.Close()
+			api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Version: test.version}
+
+			// Act
+			page, err := api.CreatePage(context.Background(), NewPage{
+				Properties: map[string]PropertyValue{"title": {Title: []RichText{NewRichText("Captured")}}},
+				Children:   []Block{NewParagraph("Worth reading")},
+			})
+
+			// Assert
+			require.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(request["parent"]))
+			assert.JSONEq(t, `{"title": {"title": [{"type": "text", "text": {"content": "Captured"}}]}}`, string(request["properties"]))
+			assert.JSONEq(t, `[{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "Worth reading"}}]}}]`, string(request["children"]))
+			assert.Equal(t, "3350ba04-48b1-43e3-8726-1b1e9828b2b3", page.Id)
+			assert.Equal(t, mockDatabaseId, page.SourceDatabaseId)
+		})
+	}
+}
+
+func TestCreatePageWithTooMuchContent(t *testing.T) {
+	// Arrange
+	api := &ApiConfig{Url: "http://localhost:0", DatabaseId: mockDatabaseId}
+	children := make([]Block, MAX_NEW_PAGE_CHILDREN+1)
+
+	// Act
+	_, err := api.CreatePage(context.Background(), NewPage{Children: children})
+
+	// Assert
+	assert.EqualError(t, err, "Unable to create page: 101 blocks of content given, at most 100 allowed")
+}
+
+func TestRetrievePagesRejectsInvalidResponses(t *testing.T) {
+	tests := map[string]string{
+		"<html><body>502 Bad Gateway</body></html>":                                `expected a JSON list object: invalid character '<' looking for beginning of value, body starts "<html><body>502 Bad Gateway</body></html>"`,
+		`{"object": "list", "results": [{"object": "page", "id": "3350ba04`:        "expected a JSON list object: unexpected end of JSON input",
+		`{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3"}`:         `expected a list object, received "page"`,
+		`{"object": "list", "results": {}, "has_more": false}`:                     "unable to decode list object",
+		`{"object": "list", "results": [], "next_cursor": null, "has_more": true}`: "has_more is set without a next_cursor",
+	}
+
+	for body, message := range tests {
+		// Arrange
+		ts, api := mockNotionServer(body, http.StatusOK)
+
+		// Act
+		pages, err := api.GetPages()
+		ts.Close()
+
+		// Assert
+		assert.Nil(t, pages)
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+		assert.Contains(t, err.Error(), message)
+	}
+}
+
+func TestRetrievePagesRejectsRepeatedCursor(t *testing.T) {
+	// Arrange: the second response points back at the cursor it was requested with
+	response := `{
+		"object": "list",
+		"results": [{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3"}],
+		"next_cursor": "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+		"has_more": true
+	}`
+	ts, api := mockNotionServerWithPaging([]string{response, response}, http.StatusOK)
+	defer ts.Close()
+
+	// Act
+	_, err := api.GetPages()
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	assert.EqualError(t, err, "Invalid Notion API response: next_cursor 240c0dcf-8334-43e5-9a01-a914c21de7e4 was already returned")
+}
+
+func TestRetrievePagesStopsAtPageLimit(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServerWithPaging([]string{`{
+		"object": "list",
+		"results": [
+			{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3"},
+			{"object": "page", "id": "5331da24-6597-4f2d-a684-fd94a0f3278a"}
+		],
+		"next_cursor": "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+		"has_more": true
+	}`}, http.StatusOK)
+	defer ts.Close()
+	api.MaxPages = 2
+
+	// Act
+	pages, err := api.GetPages()
+
+	// Assert
+	assert.Len(t, pages, 2)
+	var partial *PartialResultError
+	if assert.ErrorAs(t, err, &partial) {
+		assert.ErrorIs(t, err, ErrPageLimit)
+		assert.Equal(t, mockCursor, partial.Cursor)
+		assert.Equal(t, 2, partial.Pages)
+	}
+}
@@ -0,0 +1,302 @@
+package notion
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Notion property types, per https://developers.notion.com/reference/page-property-values
+const (
+	PROPERTY_TITLE            = "title"
+	PROPERTY_RICH_TEXT        = "rich_text"
+	PROPERTY_NUMBER           = "number"
+	PROPERTY_SELECT           = "select"
+	PROPERTY_MULTI_SELECT     = "multi_select"
+	PROPERTY_STATUS           = "status"
+	PROPERTY_DATE             = "date"
+	PROPERTY_PEOPLE           = "people"
+	PROPERTY_FILES            = "files"
+	PROPERTY_CHECKBOX         = "checkbox"
+	PROPERTY_URL              = "url"
+	PROPERTY_EMAIL            = "email"
+	PROPERTY_PHONE_NUMBER     = "phone_number"
+	PROPERTY_FORMULA          = "formula"
+	PROPERTY_RELATION         = "relation"
+	PROPERTY_ROLLUP           = "rollup"
+	PROPERTY_CREATED_TIME     = "created_time"
+	PROPERTY_CREATED_BY       = "created_by"
+	PROPERTY_LAST_EDITED_TIME = "last_edited_time"
+	PROPERTY_LAST_EDITED_BY   = "last_edited_by"
+	PROPERTY_UNIQUE_ID        = "unique_id"
+)
+
+// ===============================================================
+// Notion page property values. Only the field matching Type is set
+// ---------------------------------------------------------------
+type PropertyValue struct {
+	Id             string         `json:"id,omitempty"`
+	Type           string         `json:"type,omitempty"`
+	Title          []RichText     `json:"title,omitempty"`
+	RichText       []RichText     `json:"rich_text,omitempty"`
+	Number         *float64       `json:"number,omitempty"`
+	Select         *SelectOption  `json:"select,omitempty"`
+	MultiSelect    []SelectOption `json:"multi_select,omitempty"`
+	Status         *SelectOption  `json:"status,omitempty"`
+	Date           *DateValue     `json:"date,omitempty"`
+	People         []User         `json:"people,omitempty"`
+	Files          []FileValue    `json:"files,omitempty"`
+	Checkbox       *bool          `json:"checkbox,omitempty"`
+	Url            *string        `json:"url,omitempty"`
+	Email          *string        `json:"email,omitempty"`
+	PhoneNumber    *string        `json:"phone_number,omitempty"`
+	Formula        *FormulaValue  `json:"formula,omitempty"`
+	Relation       []ObjectRef    `json:"relation,omitempty"`
+	HasMore        bool           `json:"has_more,omitempty"` // Set by Notion on relations it truncated
+	Rollup         *RollupValue   `json:"rollup,omitempty"`
+	CreatedTime    *string        `json:"created_time,omitempty"`
+	CreatedBy      *User          `json:"created_by,omitempty"`
+	LastEditedTime *string        `json:"last_edited_time,omitempty"`
+	LastEditedBy   *User          `json:"last_edited_by,omitempty"`
+	UniqueId       *UniqueIdValue `json:"unique_id,omitempty"`
+}
+
+type SelectOption struct {
+	Id    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+type DateValue struct {
+	Start    string `json:"start"`
+	End      string `json:"end,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+type FileValue struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"` // "file" or "external"
+	File     *HostedFile   `json:"file,omitempty"`
+	External *ExternalFile `json:"external,omitempty"`
+}
+
+type HostedFile struct {
+	Url        string `json:"url"`
+	ExpiryTime string `json:"expiry_time,omitempty"`
+}
+
+type ExternalFile struct {
+	Url string `json:"url"`
+}
+
+type FormulaValue struct {
+	Type    string     `json:"type"` // "string", "number", "boolean" or "date"
+	String  *string    `json:"string,omitempty"`
+	Number  *float64   `json:"number,omitempty"`
+	Boolean *bool      `json:"boolean,omitempty"`
+	Date    *DateValue `json:"date,omitempty"`
+}
+
+type RollupValue struct {
+	Type     string          `json:"type"` // "number", "date" or "array"
+	Function string          `json:"function,omitempty"`
+	Number   *float64        `json:"number,omitempty"`
+	Date     *DateValue      `json:"date,omitempty"`
+	Array    []PropertyValue `json:"array,omitempty"`
+}
+
+type UniqueIdValue struct {
+	Prefix string `json:"prefix,omitempty"`
+	Number int    `json:"number"`
+}
+
+// ===============================================================
+
+// Return the property's value as a plain Go value:
+// string, float64, bool, []string, *DateValue, *User, []User, []FileValue,
+// []ObjectRef or []interface{} (for rollup arrays), or nil if the property is empty
+func (p PropertyValue) Value() interface{} {
+	switch p.Type {
+	case PROPERTY_TITLE:
+		return PlainText(p.Title)
+	case PROPERTY_RICH_TEXT:
+		return PlainText(p.RichText)
+	case PROPERTY_NUMBER:
+		return derefFloat(p.Number)
+	case PROPERTY_SELECT:
+		return optionName(p.Select)
+	case PROPERTY_STATUS:
+		return optionName(p.Status)
+	case PROPERTY_MULTI_SELECT:
+		names := make([]string, len(p.MultiSelect))
+		for i, option := range p.MultiSelect {
+			names[i] = option.Name
+		}
+		return names
+	case PROPERTY_DATE:
+		return derefDate(p.Date)
+	case PROPERTY_PEOPLE:
+		return p.People
+	case PROPERTY_FILES:
+		return p.Files
+	case PROPERTY_CHECKBOX:
+		return p.Checkbox != nil && *p.Checkbox
+	case PROPERTY_URL:
+		return derefString(p.Url)
+	case PROPERTY_EMAIL:
+		return derefString(p.Email)
+	case PROPERTY_PHONE_NUMBER:
+		return derefString(p.PhoneNumber)
+	case PROPERTY_FORMULA:
+		return p.Formula.value()
+	case PROPERTY_RELATION:
+		return p.Relation
+	case PROPERTY_ROLLUP:
+		return p.Rollup.value()
+	case PROPERTY_CREATED_TIME:
+		return derefString(p.CreatedTime)
+	case PROPERTY_LAST_EDITED_TIME:
+		return derefString(p.LastEditedTime)
+	case PROPERTY_CREATED_BY:
+		return derefUser(p.CreatedBy)
+	case PROPERTY_LAST_EDITED_BY:
+		return derefUser(p.LastEditedBy)
+	case PROPERTY_UNIQUE_ID:
+		if p.UniqueId == nil {
+			return nil
+		}
+		return p.UniqueId.String()
+	}
+	return nil
+}
+
+// Return the property's value formatted as plain text, e.g. for display
+func (p PropertyValue) PlainText() string {
+	return formatValue(p.Value())
+}
+
+func (f *FormulaValue) value() interface{} {
+	if f == nil {
+		return nil
+	}
+	switch f.Type {
+	case "string":
+		return derefString(f.String)
+	case "number":
+		return derefFloat(f.Number)
+	case "boolean":
+		return f.Boolean != nil && *f.Boolean
+	case "date":
+		return derefDate(f.Date)
+	}
+	return nil
+}
+
+func (r *RollupValue) value() interface{} {
+	if r == nil {
+		return nil
+	}
+	switch r.Type {
+	case "number":
+		return derefFloat(r.Number)
+	case "date":
+		return derefDate(r.Date)
+	case "array":
+		values := make([]interface{}, len(r.Array))
+		for i, item := range r.Array {
+			values[i] = item.Value()
+		}
+		return values
+	}
+	return nil
+}
+
+func (u UniqueIdValue) String() string {
+	if u.Prefix == "" {
+		return strconv.Itoa(u.Number)
+	}
+	return u.Prefix + "-" + strconv.Itoa(u.Number)
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, ", ")
+	case *DateValue:
+		if v.End != "" {
+			return v.Start + " → " + v.End
+		}
+		return v.Start
+	case *User:
+		return v.Name
+	case []User:
+		names := make([]string, len(v))
+		for i, user := range v {
+			names[i] = user.Name
+		}
+		return strings.Join(names, ", ")
+	case []FileValue:
+		names := make([]string, len(v))
+		for i, file := range v {
+			names[i] = file.Name
+		}
+		return strings.Join(names, ", ")
+	case []ObjectRef:
+		ids := make([]string, len(v))
+		for i, ref := range v {
+			ids[i] = ref.Id
+		}
+		return strings.Join(ids, ", ")
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := formatValue(item); s != "" {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+	return ""
+}
+
+func optionName(option *SelectOption) interface{} {
+	if option == nil {
+		return nil
+	}
+	return option.Name
+}
+
+func derefString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func derefFloat(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+func derefDate(d *DateValue) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d
+}
+
+func derefUser(u *User) interface{} {
+	if u == nil {
+		return nil
+	}
+	return u
+}
@@ -0,0 +1,119 @@
+package notion
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockPageWithProperties = `{
+	"object": "page",
+	"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+	"created_time": "2021-11-05T12:54:00.000Z",
+	"last_edited_time": "2021-11-05T12:55:00.000Z",
+	"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+	"properties": {
+		"Name": {"id": "title", "type": "title", "title": [
+			{"type": "text", "text": {"content": "Initial ", "link": null}, "plain_text": "Initial ", "href": null},
+			{"type": "text", "text": {"content": "goals", "link": null}, "annotations": {"bold": true, "italic": false, "strikethrough": false, "underline": false, "code": false, "color": "default"}, "plain_text": "goals", "href": null}
+		]},
+		"Summary": {"id": "a%3Ab", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "Where it all began"}, "plain_text": "Where it all began"}]},
+		"Rating": {"id": "c%3Ad", "type": "number", "number": 4.5},
+		"Empty Rating": {"id": "c%3Ae", "type": "number", "number": null},
+		"Type": {"id": "e%3Af", "type": "select", "select": {"id": "1", "name": "Article", "color": "blue"}},
+		"Tags": {"id": "g%3Ah", "type": "multi_select", "multi_select": [{"id": "2", "name": "go", "color": "red"}, {"id": "3", "name": "notion", "color": "gray"}]},
+		"Status": {"id": "i%3Aj", "type": "status", "status": {"id": "4", "name": "Done", "color": "green"}},
+		"Read On": {"id": "k%3Al", "type": "date", "date": {"start": "2021-11-06", "end": "2021-11-07", "time_zone": null}},
+		"Reviewers": {"id": "m%3An", "type": "people", "people": [{"object": "user", "id": "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", "type": "person", "name": "Ada Lovelace", "person": {"email": "ada@example.com"}}]},
+		"Attachments": {"id": "o%3Ap", "type": "files", "files": [{"name": "notes.pdf", "type": "external", "external": {"url": "https://example.com/notes.pdf"}}]},
+		"Archived": {"id": "q%3Ar", "type": "checkbox", "checkbox": false},
+		"URL": {"id": "s%3At", "type": "url", "url": "https://example.com"},
+		"Email": {"id": "u%3Av", "type": "email", "email": "ada@example.com"},
+		"Phone": {"id": "w%3Ax", "type": "phone_number", "phone_number": null},
+		"Sort Order": {"id": "y%3Az", "type": "formula", "formula": {"type": "number", "number": 100}},
+		"Related": {"id": "A%3AB", "type": "relation", "relation": [{"id": "5331da24-6597-4f2d-a684-fd94a0f3278a"}], "has_more": false},
+		"Related Tags": {"id": "C%3AD", "type": "rollup", "rollup": {"type": "array", "function": "show_original", "array": [{"type": "select", "select": {"name": "cooking"}}, {"type": "number", "number": 2}]}},
+		"Created": {"id": "MEdb", "type": "created_time", "created_time": "2021-11-05T12:54:00.000Z"},
+		"Created By": {"id": "E%3AF", "type": "created_by", "created_by": {"object": "user", "id": "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed"}},
+		"Edited": {"id": "G%3AH", "type": "last_edited_time", "last_edited_time": "2021-11-05T12:55:00.000Z"},
+		"Edited By": {"id": "I%3AJ", "type": "last_edited_by", "last_edited_by": {"object": "user", "id": "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", "name": "Ada Lovelace"}},
+		"ID": {"id": "K%3AL", "type": "unique_id", "unique_id": {"prefix": "NOTE", "number": 42}}
+	}
+}`
+
+func TestDecodePageProperties(t *testing.T) {
+	var page Page
+	require.NoError(t, json.Unmarshal([]byte(mockPageWithProperties), &page))
+
+	expected := map[string]interface{}{
+		"Name":         "Initial goals",
+		"Summary":      "Where it all began",
+		"Rating":       4.5,
+		"Empty Rating": nil,
+		"Type":         "Article",
+		"Tags":         []string{"go", "notion"},
+		"Status":       "Done",
+		"Read On":      &DateValue{Start: "2021-11-06", End: "2021-11-07"},
+		"Reviewers": []User{
+			{
+				Object: "user",
+				Id:     "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed",
+				Type:   "person",
+				Name:   "Ada Lovelace",
+				Person: &Person{Email: "ada@example.com"},
+			},
+		},
+		"Attachments": []FileValue{
+			{Name: "notes.pdf", Type: "external", External: &ExternalFile{Url: "https://example.com/notes.pdf"}},
+		},
+		"Archived":     false,
+		"URL":          "https://example.com",
+		"Email":        "ada@example.com",
+		"Phone":        nil,
+		"Sort Order":   float64(100),
+		"Related":      []ObjectRef{{Id: "5331da24-6597-4f2d-a684-fd94a0f3278a"}},
+		"Related Tags": []interface{}{"cooking", float64(2)},
+		"Created":      "2021-11-05T12:54:00.000Z",
+		"Created By":   &User{Object: "user", Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed"},
+		"Edited":       "2021-11-05T12:55:00.000Z",
+		"Edited By":    &User{Object: "user", Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada Lovelace"},
+		"ID":           "NOTE-42",
+	}
+
+	assert.Len(t, page.Properties, len(expected))
+	for name, value := range expected {
+		property, ok := page.Property(name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, value, property.Value(), name)
+		}
+	}
+	assert.Equal(t, "Initial goals", page.Title())
+	assert.True(t, page.Properties["Name"].Title[1].Annotations.Bold)
+}
+
+func TestPropertyPlainText(t *testing.T) {
+	var page Page
+	require.NoError(t, json.Unmarshal([]byte(mockPageWithProperties), &page))
+
+	assert.Equal(t, "4.5", page.Properties["Rating"].PlainText())
+	assert.Equal(t, "", page.Properties["Empty Rating"].PlainText())
+	assert.Equal(t, "go, notion", page.Properties["Tags"].PlainText())
+	assert.Equal(t, "2021-11-06 → 2021-11-07", page.Properties["Read On"].PlainText())
+	assert.Equal(t, "Ada Lovelace", page.Properties["Reviewers"].PlainText())
+	assert.Equal(t, "false", page.Properties["Archived"].PlainText())
+	assert.Equal(t, "cooking, 2", page.Properties["Related Tags"].PlainText())
+}
+
+func TestPagePropertiesRoundTrip(t *testing.T) {
+	var page Page
+	require.NoError(t, json.Unmarshal([]byte(mockPageWithProperties), &page))
+
+	encoded, err := json.Marshal(page)
+	require.NoError(t, err)
+	var decoded Page
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	assert.Equal(t, page, decoded)
+}
@@ -0,0 +1,241 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Most items of a relation, people, rich text, title or rollup array property included in page objects.
+// Notion truncates longer values, which are only complete when retrieved with GetPageProperty, per
+// https://developers.notion.com/reference/retrieve-a-page-property
+const MAX_PROPERTY_ITEMS = 25
+
+// PropertyCompleter retrieves the complete values of page properties that Notion truncates
+type PropertyCompleter interface {
+	GetPageProperty(context.Context, string, string) (*PropertyValue, error)
+	CompleteProperties(context.Context, *Page) error
+}
+
+// A page of the items of a paginated property
+type propertyItemResponse struct {
+	Object       string            `json:"object"`
+	Results      []json.RawMessage `json:"results"`
+	Next         string            `json:"next_cursor"`
+	HasMore      bool              `json:"has_more"`
+	PropertyItem struct {
+		Id     string       `json:"id"`
+		Type   string       `json:"type"`
+		Rollup *RollupValue `json:"rollup,omitempty"`
+	} `json:"property_item"`
+}
+
+// A single item of a paginated property, which holds one segment, user or reference rather than a list
+type propertyItem struct {
+	Id       string     `json:"id"`
+	Type     string     `json:"type"`
+	Title    *RichText  `json:"title,omitempty"`
+	RichText *RichText  `json:"rich_text,omitempty"`
+	People   *User      `json:"people,omitempty"`
+	Relation *ObjectRef `json:"relation,omitempty"`
+}
+
+// Return whether Notion may have left items out of the value, as it does in page objects
+// for values of more than MAX_PROPERTY_ITEMS items. Values of exactly MAX_PROPERTY_ITEMS items
+// can't be told from truncated ones, except for relations, which Notion marks with has_more
+func (p PropertyValue) Truncated() bool {
+	switch p.Type {
+	case PROPERTY_TITLE:
+		return len(p.Title) == MAX_PROPERTY_ITEMS
+	case PROPERTY_RICH_TEXT:
+		return len(p.RichText) == MAX_PROPERTY_ITEMS
+	case PROPERTY_PEOPLE:
+		return len(p.People) == MAX_PROPERTY_ITEMS
+	case PROPERTY_RELATION:
+		return p.HasMore || len(p.Relation) == MAX_PROPERTY_ITEMS
+	case PROPERTY_ROLLUP:
+		return p.Rollup != nil && len(p.Rollup.Array) == MAX_PROPERTY_ITEMS
+	}
+	return false
+}
+
+// Return the names of the page's properties that may be truncated
+func (p Page) TruncatedProperties() []string {
+	names := []string{}
+	for name, property := range p.Properties {
+		if property.Truncated() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Return the complete value of a page property, paging through its items if Notion paginates it.
+// propertyId is the property's ID as Notion returns it, which is already escaped for use in a URL
+func (api *ApiConfig) GetPageProperty(ctx context.Context, pageId string, propertyId string) (*PropertyValue, error) {
+	defer logging.LogFunction(
+		"property_items.GetPageProperty", time.Now(), "Getting page property",
+		map[string]interface{}{"page_id": pageId, "property_id": propertyId},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+
+	value := PropertyValue{Id: propertyId}
+	cursor := ""
+	seen := cursors{}
+	for hasMore := true; hasMore; {
+		params := url.Values{}
+		if api.PageSize > 0 {
+			params.Set("page_size", strconv.Itoa(int(api.PageSize)))
+		}
+		if cursor != "" {
+			params.Set("start_cursor", cursor)
+		}
+		body, err := api.do(ctx, apiRequest{
+			Method:     "GET",
+			Path:       fmt.Sprintf("/pages/%s/properties/%s?%s", pageId, propertyId, params.Encode()),
+			Idempotent: true,
+		})
+		if err != nil {
+			logger.Err(err).Msg("Unable to retrieve page property")
+			return nil, err
+		}
+		logger.Trace().RawJSON("property_response_json", body).Msg("Receieved Notion API response")
+
+		// Properties that aren't paginated are returned as a single property item
+		var header struct {
+			Object string `json:"object"`
+		}
+		if json.Unmarshal(body, &header) == nil && header.Object == "property_item" && cursor == "" {
+			var item PropertyValue
+			if err := decodeResponse(body, "property_item", &item); err != nil {
+				logger.Err(err).Str("page_id", pageId).Send()
+				return nil, err
+			}
+			return &item, nil
+		}
+
+		var response propertyItemResponse
+		if err = decodeResponse(body, "list", &response); err == nil {
+			err = seen.check(response.HasMore, response.Next)
+		}
+		if err == nil {
+			err = value.addItems(response)
+		}
+		if err != nil {
+			logger.Err(err).Str("page_id", pageId).Send()
+			return nil, err
+		}
+		hasMore = response.HasMore
+		cursor = response.Next
+	}
+	return &value, nil
+}
+
+// Append a page of property items to the value
+func (p *PropertyValue) addItems(response propertyItemResponse) error {
+	if response.PropertyItem.Id != "" {
+		p.Id = response.PropertyItem.Id
+	}
+	p.Type = response.PropertyItem.Type
+	if p.Type == PROPERTY_ROLLUP {
+		// Number and date rollups are computed over the items retrieved so far, so the last response has the result
+		rollup := response.PropertyItem.Rollup
+		if rollup == nil {
+			return fmt.Errorf("%w: rollup property item has no rollup", ErrInvalidResponse)
+		}
+		if p.Rollup == nil {
+			p.Rollup = &RollupValue{}
+		}
+		p.Rollup.Type = rollup.Type
+		p.Rollup.Function = rollup.Function
+		p.Rollup.Number = rollup.Number
+		p.Rollup.Date = rollup.Date
+	}
+
+	for _, result := range response.Results {
+		item, err := decodePropertyItem(result)
+		if err != nil {
+			return err
+		}
+		switch p.Type {
+		case PROPERTY_TITLE:
+			p.Title = append(p.Title, item.Title...)
+		case PROPERTY_RICH_TEXT:
+			p.RichText = append(p.RichText, item.RichText...)
+		case PROPERTY_PEOPLE:
+			p.People = append(p.People, item.People...)
+		case PROPERTY_RELATION:
+			p.Relation = append(p.Relation, item.Relation...)
+		case PROPERTY_ROLLUP:
+			if p.Rollup.Type == "array" {
+				p.Rollup.Array = append(p.Rollup.Array, item)
+			}
+		default:
+			return fmt.Errorf("%w: %s properties aren't paginated", ErrInvalidResponse, p.Type)
+		}
+	}
+	return nil
+}
+
+// Return a property item as a value of its type, with a single element for list types
+func decodePropertyItem(result json.RawMessage) (PropertyValue, error) {
+	var item propertyItem
+	if err := json.Unmarshal(result, &item); err != nil {
+		return PropertyValue{}, fmt.Errorf("%w: unable to decode property item: %v", ErrInvalidResponse, err)
+	}
+
+	value := PropertyValue{Id: item.Id, Type: item.Type}
+	switch {
+	case item.Type == PROPERTY_TITLE && item.Title != nil:
+		value.Title = []RichText{*item.Title}
+	case item.Type == PROPERTY_RICH_TEXT && item.RichText != nil:
+		value.RichText = []RichText{*item.RichText}
+	case item.Type == PROPERTY_PEOPLE && item.People != nil:
+		value.People = []User{*item.People}
+	case item.Type == PROPERTY_RELATION && item.Relation != nil:
+		value.Relation = []ObjectRef{*item.Relation}
+	default:
+		// Items of rollup arrays may be of any type, which have the same form as in page objects
+		value = PropertyValue{}
+		if err := json.Unmarshal(result, &value); err != nil {
+			return PropertyValue{}, fmt.Errorf("%w: unable to decode %s property item: %v", ErrInvalidResponse, item.Type, err)
+		}
+	}
+	return value, nil
+}
+
+// Replace the properties of page that Notion may have truncated with their complete values.
+// Properties that can't be retrieved are left as they are and the first error is returned.
+// Completed properties replace those of page rather than modifying them, as pages may share them
+func (api *ApiConfig) CompleteProperties(ctx context.Context, page *Page) error {
+	var firstErr error
+	var properties map[string]PropertyValue
+	for name, property := range page.Properties {
+		if !property.Truncated() || property.Id == "" {
+			continue
+		}
+		value, err := api.GetPageProperty(ctx, page.Id, property.Id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("Unable to retrieve property %s: %w", name, err)
+			}
+			continue
+		}
+		if properties == nil {
+			properties = make(map[string]PropertyValue, len(page.Properties))
+			for name, property := range page.Properties {
+				properties[name] = property
+			}
+		}
+		properties[name] = *value
+	}
+	if properties != nil {
+		page.Properties = properties
+	}
+	return firstErr
+}
@@ -0,0 +1,236 @@
+package notion_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/jeffrosenberg/random-notion/pkg/notiontest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relations(n int) []notion.ObjectRef {
+	refs := make([]notion.ObjectRef, n)
+	for i := range refs {
+		refs[i] = notion.ObjectRef{Id: fmt.Sprintf("page-%d", i+1)}
+	}
+	return refs
+}
+
+// Start a server with a page of three links, a number rollup computed over them,
+// an array rollup of their tags and a number property, which isn't paginated
+func linkedPage(t *testing.T) (*notiontest.Server, notion.Page) {
+	s := notiontest.NewServer(notiontest.Options{})
+	t.Cleanup(s.Close)
+	db := s.AddDatabase(notion.Database{
+		Title: []notion.RichText{notion.NewRichText("Zettelkasten")},
+		Properties: map[string]notion.PropertySchema{
+			"Name":   {Type: notion.PROPERTY_TITLE},
+			"Links":  {Type: notion.PROPERTY_RELATION},
+			"Score":  {Type: notion.PROPERTY_ROLLUP},
+			"Tags":   {Type: notion.PROPERTY_ROLLUP},
+			"Rating": {Type: notion.PROPERTY_NUMBER},
+		},
+	})
+	score, rating := float64(5), float64(4)
+	page := s.AddPage(db.Id, notion.Page{Properties: map[string]notion.PropertyValue{
+		"Name":  {Title: []notion.RichText{notion.NewRichText("Index")}},
+		"Links": {Relation: relations(3)},
+		"Score": {Rollup: &notion.RollupValue{Type: "number", Function: "sum", Number: &score}},
+		"Tags": {Rollup: &notion.RollupValue{Type: "array", Function: "show_original", Array: []notion.PropertyValue{
+			{Type: notion.PROPERTY_SELECT, Select: &notion.SelectOption{Name: "cooking"}},
+			{Type: notion.PROPERTY_RELATION, Relation: relations(1)},
+		}}},
+		"Rating": {Number: &rating},
+	}})
+	return s, page
+}
+
+func TestTruncated(t *testing.T) {
+	// Arrange
+	segments := make([]notion.RichText, notion.MAX_PROPERTY_ITEMS)
+	tests := map[string]struct {
+		value    notion.PropertyValue
+		expected bool
+	}{
+		"empty relation":            {notion.PropertyValue{Type: notion.PROPERTY_RELATION}, false},
+		"short relation":            {notion.PropertyValue{Type: notion.PROPERTY_RELATION, Relation: relations(3)}, false},
+		"relation with has_more":    {notion.PropertyValue{Type: notion.PROPERTY_RELATION, Relation: relations(notion.MAX_PROPERTY_ITEMS), HasMore: true}, true},
+		"relation at the limit":     {notion.PropertyValue{Type: notion.PROPERTY_RELATION, Relation: relations(notion.MAX_PROPERTY_ITEMS)}, true},
+		"completed relation":        {notion.PropertyValue{Type: notion.PROPERTY_RELATION, Relation: relations(30)}, false},
+		"rich text at the limit":    {notion.PropertyValue{Type: notion.PROPERTY_RICH_TEXT, RichText: segments}, true},
+		"title at the limit":        {notion.PropertyValue{Type: notion.PROPERTY_TITLE, Title: segments}, true},
+		"rollup array at the limit": {notion.PropertyValue{Type: notion.PROPERTY_ROLLUP, Rollup: &notion.RollupValue{Type: "array", Array: make([]notion.PropertyValue, notion.MAX_PROPERTY_ITEMS)}}, true},
+		"number rollup":             {notion.PropertyValue{Type: notion.PROPERTY_ROLLUP, Rollup: &notion.RollupValue{Type: "number"}}, false},
+		"multi-select":              {notion.PropertyValue{Type: notion.PROPERTY_MULTI_SELECT, MultiSelect: make([]notion.SelectOption, notion.MAX_PROPERTY_ITEMS)}, false},
+	}
+
+	for name, test := range tests {
+		// Act
+		truncated := test.value.Truncated()
+
+		// Assert
+		assert.Equal(t, test.expected, truncated, name)
+	}
+}
+
+func TestGetPagePropertyPagesThroughItems(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+	api.PageSize = 2
+
+	// Act
+	value, err := api.GetPageProperty(context.Background(), page.Id, page.Properties["Links"].Id)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, notion.PROPERTY_RELATION, value.Type)
+	assert.Equal(t, page.Properties["Links"].Id, value.Id)
+	assert.Equal(t, relations(3), value.Relation)
+	assert.False(t, value.HasMore)
+	assert.Equal(t, 2, s.Requests(), "Three items take two requests of two")
+}
+
+func TestGetPagePropertyCollectsRollups(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+	api.PageSize = 1
+
+	// Act
+	score, scoreErr := api.GetPageProperty(context.Background(), page.Id, page.Properties["Score"].Id)
+	tags, tagsErr := api.GetPageProperty(context.Background(), page.Id, page.Properties["Tags"].Id)
+
+	// Assert
+	require.NoError(t, scoreErr)
+	assert.Equal(t, float64(5), score.Value())
+	assert.Empty(t, score.Rollup.Array)
+	require.NoError(t, tagsErr)
+	require.Len(t, tags.Rollup.Array, 2)
+	assert.Equal(t, "cooking", tags.Rollup.Array[0].Value())
+	assert.Equal(t, relations(1), tags.Rollup.Array[1].Relation)
+}
+
+func TestGetPagePropertyReturnsUnpaginatedValue(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+
+	// Act
+	value, err := api.GetPageProperty(context.Background(), page.Id, page.Properties["Rating"].Id)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), value.Value())
+	assert.Equal(t, 1, s.Requests())
+}
+
+func TestGetPagePropertyReturnsNotFound(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+
+	// Act
+	_, err := api.GetPageProperty(context.Background(), page.Id, "gone")
+
+	// Assert
+	assert.True(t, errors.Is(err, notion.ErrObjectNotFound))
+}
+
+func TestCompletePropertiesReplacesTruncatedValues(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+	properties := map[string]notion.PropertyValue{}
+	for name, property := range page.Properties {
+		properties[name] = property
+	}
+	links := properties["Links"]
+	links.Relation = links.Relation[:2]
+	links.HasMore = true
+	properties["Links"] = links
+	page.Properties = properties
+
+	// Act
+	err := api.CompleteProperties(context.Background(), &page)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, relations(3), page.Properties["Links"].Relation)
+	assert.Equal(t, float64(4), page.Properties["Rating"].Value())
+	assert.Empty(t, page.TruncatedProperties())
+	assert.Equal(t, 1, s.Requests(), "Only the truncated property is retrieved")
+	assert.Len(t, properties["Links"].Relation, 2, "The original properties are left as they were")
+}
+
+func TestCompletePropertiesReturnsFirstError(t *testing.T) {
+	// Arrange
+	s, page := linkedPage(t)
+	api := s.Api()
+	page.Properties = map[string]notion.PropertyValue{
+		"Missing": {Id: "gone", Type: notion.PROPERTY_RELATION, HasMore: true},
+	}
+
+	// Act
+	err := api.CompleteProperties(context.Background(), &page)
+
+	// Assert
+	assert.True(t, errors.Is(err, notion.ErrObjectNotFound))
+	assert.True(t, page.Properties["Missing"].HasMore, "Properties that can't be retrieved are left as they are")
+}
+
+func TestCompletePropertiesKeepsValuesOnInvalidResponse(t *testing.T) {
+	// Arrange
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}))
+	defer ts.Close()
+	api := &notion.ApiConfig{Url: ts.URL}
+	page := notion.Page{Id: "3350ba04-48b1-43e3-8726-1b1e9828b2b3", Properties: map[string]notion.PropertyValue{
+		"Links": {Id: "rel", Type: notion.PROPERTY_RELATION, Relation: relations(notion.MAX_PROPERTY_ITEMS), HasMore: true},
+	}}
+
+	// Act
+	err := api.CompleteProperties(context.Background(), &page)
+
+	// Assert
+	assert.True(t, errors.Is(err, notion.ErrInvalidResponse))
+	assert.Equal(t, relations(notion.MAX_PROPERTY_ITEMS), page.Properties["Links"].Relation)
+	assert.True(t, page.Properties["Links"].HasMore)
+}
+
+func TestGetPagesKeepsPropertiesThatCantBeCompleted(t *testing.T) {
+	// Arrange
+	s, _ := linkedPage(t)
+	api := s.Api()
+	links := make([]notion.ObjectRef, 30)
+	for i := range links {
+		links[i] = notion.ObjectRef{Id: fmt.Sprintf("link-%d", i)}
+	}
+	s.AddPage(api.DatabaseId, notion.Page{Properties: map[string]notion.PropertyValue{
+		"Name":  {Title: []notion.RichText{notion.NewRichText("Archive")}},
+		"Links": {Relation: links},
+	}})
+	s.Inject(notiontest.Fault{
+		Method: http.MethodGet, Path: "/pages/", Status: http.StatusNotFound, Code: notion.CODE_OBJECT_NOT_FOUND,
+	})
+	api.CompleteTruncated = true
+
+	// Act
+	pages, err := api.GetPages()
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	truncated := 0
+	for _, page := range pages {
+		truncated += len(page.TruncatedProperties())
+	}
+	assert.Equal(t, 1, truncated, "The page is returned with the items it was retrieved with")
+}
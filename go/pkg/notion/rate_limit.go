@@ -0,0 +1,105 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Notion allows an average of three requests per second per integration,
+// per https://developers.notion.com/reference/request-limits
+const DEFAULT_RATE_LIMIT = 3.0
+const DEFAULT_RATE_BURST = 3
+
+// RateLimiter is a token bucket shared by every request made through it,
+// including requests made concurrently from several goroutines
+type RateLimiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Most tokens the bucket holds
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Create a limiter allowing rate requests per second on average
+// and up to burst requests at once
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Block until a request may be made, returning how long it waited.
+// Returns early with an error if ctx is done or its deadline would pass first
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil || l.rate <= 0 {
+		return 0, nil
+	}
+	wait := l.reserve(time.Now())
+	if wait <= 0 {
+		return 0, nil
+	}
+	if err := sleep(ctx, wait); err != nil {
+		l.cancel()
+		return 0, err
+	}
+	return wait, nil
+}
+
+// Take a token, returning how long to wait before it's available.
+// The bucket goes into debt so that concurrent callers queue up in order
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Return a reserved token that wasn't used
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
+
+// Wait for the limiter before sending each request
+func RateLimitMiddleware(limiter *RateLimiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			waited, err := limiter.Wait(req.Context())
+			if err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, err
+			}
+			if waited > 0 {
+				logging.GetLoggerWithContext(req.Context()).Debug().
+					Str("request_url", req.URL.String()).
+					Dur("rate_limit_wait", waited).
+					Msg("Waited for Notion API rate limit")
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterAllowsBurst(t *testing.T) {
+	// Arrange
+	limiter := NewRateLimiter(1, 3)
+	now := time.Now()
+
+	// Act & Assert
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Second, limiter.reserve(now))
+	assert.Equal(t, 2*time.Second, limiter.reserve(now)) // Queued behind the previous caller
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	// Arrange
+	limiter := NewRateLimiter(2, 1)
+	now := time.Now()
+	limiter.reserve(now)
+
+	// Act
+	early := limiter.reserve(now.Add(250 * time.Millisecond))
+	limiter.cancel()
+	later := limiter.reserve(now.Add(time.Hour)) // Refill is capped at the burst size
+	next := limiter.reserve(now.Add(time.Hour))
+
+	// Assert
+	assert.Equal(t, 250*time.Millisecond, early)
+	assert.Equal(t, time.Duration(0), later)
+	assert.Equal(t, 500*time.Millisecond, next)
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	// Arrange
+	limiter := NewRateLimiter(0.1, 1)
+	limiter.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	_, err := limiter.Wait(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.InDelta(t, 10*time.Second, limiter.reserve(limiter.last), float64(10*time.Millisecond)) // The abandoned token was returned
+}
+
+func TestNilRateLimiterDoesNotWait(t *testing.T) {
+	var limiter *RateLimiter
+
+	waited, err := limiter.Wait(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), waited)
+}
+
+func TestRateLimiterSharedAcrossGoroutines(t *testing.T) {
+	// Arrange
+	var mu sync.Mutex
+	requests := []time.Time{}
+	ts := mockDatabaseServer(func(r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, time.Now())
+	})
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, RateLimiter: NewRateLimiter(20, 1)}
+
+	// Act
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := api.GetDatabase()
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Len(t, requests, 5)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// A single call to the Notion API
+type apiRequest struct {
+	Method     string
+	Path       string      // Path relative to ApiConfig.Url, e.g. "/databases/{id}"
+	Body       interface{} // Marshalled to JSON when not nil
+	Idempotent bool        // Whether the request is safe to retry despite its method
+	// Headers to send in place of the defaults, e.g. another Authorization
+	Headers map[string]string
+}
+
+// Send a request to the Notion API through api.client(),
+// and return the body of a successful response
+func (api *ApiConfig) do(ctx context.Context, r apiRequest) ([]byte, error) {
+	logger := logging.GetLoggerWithContext(ctx)
+	url, err := url.Parse(fmt.Sprintf("%s%s", api.Url, r.Path))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse URL: %w", err)
+	}
+
+	var jsonValue []byte
+	if r.Body != nil {
+		jsonValue, err = json.Marshal(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create request body: %w", err)
+		}
+	}
+	logger.Trace().
+		Str("request_verb", r.Method).
+		Str("request_url", url.String()).
+		RawJSON("request_json", jsonOrNull(jsonValue)).
+		Msg("Prepared Notion API request")
+
+	var body io.Reader
+	if jsonValue != nil {
+		body = bytes.NewReader(jsonValue)
+	}
+	if r.Idempotent {
+		ctx = withIdempotent(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, url.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create request: %w", err)
+	}
+	if jsonValue != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := api.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve response: %w", err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res, resBody)
+	}
+	return resBody, nil
+}
+
+func jsonOrNull(value []byte) []byte {
+	if value == nil {
+		return []byte("null")
+	}
+	return value
+}
+
+// Decode a successful response body into v, checking that it's a Notion object of the given type,
+// e.g. "page" or "list". The body isn't required to have an object type if object is empty
+func decodeResponse(body []byte, object string, v interface{}) error {
+	var header struct {
+		Object string `json:"object"`
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		return fmt.Errorf("%w: expected a JSON %s object: %v, body starts %q", ErrInvalidResponse, object, err, bodyStart(body))
+	}
+	if header.Object != object {
+		return fmt.Errorf("%w: expected a %s object, received %q", ErrInvalidResponse, object, header.Object)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: unable to decode %s object: %v", ErrInvalidResponse, object, err)
+	}
+	return nil
+}
+
+// Return the start of a body for error messages, e.g. to tell an HTML error page
+func bodyStart(body []byte) string {
+	const length = 64
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) > length {
+		return s[:length] + "..."
+	}
+	return s
+}
+
+// Cursors already returned while paging through a list, to check each response's cursor.
+// A response with has_more needs a next_cursor, and one that was returned before would page forever
+type cursors map[string]bool
+
+func (seen cursors) check(hasMore bool, next string) error {
+	if !hasMore {
+		return nil
+	}
+	if next == "" {
+		return fmt.Errorf("%w: has_more is set without a next_cursor", ErrInvalidResponse)
+	}
+	if seen[next] {
+		return fmt.Errorf("%w: next_cursor %s was already returned", ErrInvalidResponse, next)
+	}
+	seen[next] = true
+	return nil
+}
@@ -0,0 +1,116 @@
+package notion
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DEFAULT_MAX_ATTEMPTS = 4
+const DEFAULT_BASE_DELAY = 500 * time.Millisecond
+const DEFAULT_MAX_DELAY = 10 * time.Second
+const DEFAULT_JITTER = 0.5
+
+// RetryPolicy controls how failed requests to the Notion API are retried.
+// The zero value disables retries
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; values below 2 disable retries
+	BaseDelay   time.Duration // Delay before the first retry, doubled on each later attempt
+	// Upper bound on a single delay. Requests Notion asks to wait longer for with Retry-After
+	// aren't retried, as retrying any sooner would be rate limited again
+	MaxDelay time.Duration
+	Jitter   float64 // Fraction of each computed delay to randomize, between 0 and 1
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DEFAULT_MAX_ATTEMPTS,
+		BaseDelay:   DEFAULT_BASE_DELAY,
+		MaxDelay:    DEFAULT_MAX_DELAY,
+		Jitter:      DEFAULT_JITTER,
+	}
+}
+
+// Return whether a request that failed on the given attempt (starting at 1)
+// should be tried again
+func (p RetryPolicy) shouldRetry(attempt int, res *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	if err != nil {
+		return !isContextError(err) // Network errors are worth another try, cancellation isn't
+	}
+	if d, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok && p.MaxDelay > 0 && d > p.MaxDelay {
+		return false
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Return how long to wait before the next attempt,
+// preferring the server's Retry-After header when one is present
+func (p RetryPolicy) delay(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if d, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			return p.capDelay(d)
+		}
+	}
+
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		d = d*(1-jitter) + d*jitter*rand.Float64()
+	}
+	return p.capDelay(time.Duration(d))
+}
+
+func (p RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// Parse a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// Wait for d, returning early with an error if ctx is done
+// or its deadline would pass before the wait is over
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
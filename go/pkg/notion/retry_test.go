@@ -0,0 +1,260 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	}
+}
+
+func TestRetryResumesFromCurrentCursor(t *testing.T) {
+	mockData1 := `{
+		"object": "list",
+		"results": [
+			{
+				"object": "page",
+				"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+				"created_time": "2021-11-05T12:54:00.000Z",
+				"last_edited_time": "2021-11-05T12:55:00.000Z",
+				"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3"
+			}
+		],
+		"next_cursor": "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+		"has_more": true
+	}`
+	mockData2 := `{
+		"object": "list",
+		"results": [
+			{
+				"object": "page",
+				"id": "240c0dcf-8334-43e5-9a01-a914c21de7e4",
+				"created_time": "2021-12-12T23:51:00.000Z",
+				"last_edited_time": "2021-12-25T13:47:00.000Z",
+				"url": "https://www.notion.so/Tampa-s-Best-Shuttle-Taxi-Service-Express-Transportation-240c0dcf833443e59a01a914c21de7e4"
+			}
+		],
+		"next_cursor": null,
+		"has_more": false
+	}`
+
+	// Fail the first request for the second page of results
+	cursors := []string{}
+	failed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var pageRequest pageRequest
+		json.Unmarshal(body, &pageRequest)
+		cursors = append(cursors, pageRequest.StartCursor)
+
+		if pageRequest.StartCursor == "" {
+			w.Write([]byte(mockData1))
+		} else if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"object": "error", "status": 503, "code": "service_unavailable", "message": "Unavailable"}`))
+		} else {
+			w.Write([]byte(mockData2))
+		}
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: testRetryPolicy()}
+
+	pages, err := api.GetPages()
+
+	require.NoError(t, err)
+	assert.Len(t, pages, 2)
+	assert.Equal(t, []string{"", mockCursor, mockCursor}, cursors)
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	requests := []time.Time{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"object": "error", "status": 429, "code": "rate_limited", "message": "Slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"object": "database", "id": "99999999-abcd-efgh-1234-000000000000"}`))
+	}))
+	defer ts.Close()
+	policy := testRetryPolicy()
+	policy.MaxDelay = 5 * time.Second
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: policy}
+
+	db, err := api.GetDatabase()
+
+	require.NoError(t, err)
+	assert.Equal(t, "99999999-abcd-efgh-1234-000000000000", db.Id)
+	if assert.Len(t, requests, 2) {
+		assert.GreaterOrEqual(t, requests[1].Sub(requests[0]), time.Second)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: testRetryPolicy()}
+
+	pages, err := api.GetPages()
+
+	assert.Error(t, err)
+	assert.Nil(t, pages)
+	assert.Equal(t, 3, requests)
+}
+
+func TestNoRetryWhenRetryAfterExceedsMaxDelay(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"object": "error", "status": 429, "code": "rate_limited", "message": "Slow down"}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: testRetryPolicy()}
+
+	_, err := api.GetDatabase()
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, CODE_RATE_LIMITED, apiErr.Code)
+	assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryAbandonedBeforeDeadlineKeepsAPIError(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"object": "error", "status": 429, "code": "rate_limited", "message": "Slow down"}`))
+	}))
+	defer ts.Close()
+	policy := testRetryPolicy()
+	policy.MaxDelay = 10 * time.Second
+	api := &ApiConfig{Url: ts.URL, Retry: policy}
+
+	// The deadline passes before Notion would accept another attempt
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := api.GetPageProperty(ctx, mockPageId, "title")
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, CODE_RATE_LIMITED, apiErr.Code)
+	assert.Equal(t, 5*time.Second, apiErr.RetryAfter)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryAttemptsThatTimeOut(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"object": "database", "id": "99999999-abcd-efgh-1234-000000000000"}`))
+	}))
+	defer ts.Close()
+	// The timeout only has to allow for the attempt that succeeds, not the one before it
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: testRetryPolicy(), HttpClient: client}
+
+	db, err := api.GetDatabase()
+
+	require.NoError(t, err)
+	assert.Equal(t, "99999999-abcd-efgh-1234-000000000000", db.Id)
+	assert.Equal(t, 2, requests)
+}
+
+func TestAttemptTimeoutWithoutRetries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, HttpClient: client}
+
+	_, err := api.GetDatabase()
+
+	assert.ErrorIs(t, err, ErrAttemptTimeout)
+	assert.False(t, isContextError(err), "The caller's context hasn't ended")
+}
+
+func TestNoRetryForClientErrors(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"object": "error", "status": 400, "code": "validation_error", "message": "Invalid filter"}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, Retry: testRetryPolicy()}
+
+	_, err := api.GetPages()
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    300 * time.Millisecond,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, policy.delay(1, nil))
+	assert.Equal(t, 200*time.Millisecond, policy.delay(2, nil))
+	assert.Equal(t, 300*time.Millisecond, policy.delay(3, nil)) // Capped by MaxDelay
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		d := policy.delay(2, nil)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 200*time.Millisecond)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
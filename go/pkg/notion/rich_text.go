@@ -0,0 +1,80 @@
+package notion
+
+import "strings"
+
+// Most characters Notion accepts in the content of a single rich text object
+const MAX_TEXT_LENGTH = 2000
+
+// ===============================================================
+// Notion rich text objects,
+// per https://developers.notion.com/reference/rich-text
+// ---------------------------------------------------------------
+type RichText struct {
+	Type        string       `json:"type"` // "text", "mention" or "equation"
+	PlainText   string       `json:"plain_text,omitempty"`
+	Href        string       `json:"href,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Text        *Text        `json:"text,omitempty"`
+	Mention     *Mention     `json:"mention,omitempty"`
+	Equation    *Equation    `json:"equation,omitempty"`
+}
+
+type Text struct {
+	Content string `json:"content"`
+	Link    *Link  `json:"link,omitempty"`
+}
+
+type Link struct {
+	Url string `json:"url"`
+}
+
+type Annotations struct {
+	Bold          bool   `json:"bold"`
+	Italic        bool   `json:"italic"`
+	Strikethrough bool   `json:"strikethrough"`
+	Underline     bool   `json:"underline"`
+	Code          bool   `json:"code"`
+	Color         string `json:"color,omitempty"`
+}
+
+type Mention struct {
+	Type        string     `json:"type"` // "user", "page", "database", "date", "link_preview" or "template_mention"
+	User        *User      `json:"user,omitempty"`
+	Page        *ObjectRef `json:"page,omitempty"`
+	Database    *ObjectRef `json:"database,omitempty"`
+	Date        *DateValue `json:"date,omitempty"`
+	LinkPreview *Link      `json:"link_preview,omitempty"`
+}
+
+type Equation struct {
+	Expression string `json:"expression"`
+}
+
+type ObjectRef struct {
+	Id string `json:"id"`
+}
+
+// ===============================================================
+
+// Build a plain, unannotated rich text segment, e.g. for writing a title
+func NewRichText(content string) RichText {
+	return RichText{
+		Type: "text",
+		Text: &Text{Content: content},
+	}
+}
+
+// Concatenate the plain text of rich text segments
+func PlainText(segments []RichText) string {
+	var sb strings.Builder
+	for _, rt := range segments {
+		if rt.PlainText != "" {
+			sb.WriteString(rt.PlainText)
+		} else if rt.Text != nil {
+			sb.WriteString(rt.Text.Content)
+		} else if rt.Equation != nil {
+			sb.WriteString(rt.Equation.Expression)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,239 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Date property GetPagesSinceTime filters on unless ApiConfig.CreatedProperty is set
+const DEFAULT_CREATED_PROPERTY = "Created"
+
+// ===============================================================
+// Database property schema,
+// per https://developers.notion.com/reference/property-object
+// ---------------------------------------------------------------
+type PropertySchema struct {
+	Id          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"` // One of the PROPERTY_* constants
+	Number      *NumberSchema   `json:"number,omitempty"`
+	Select      *SelectSchema   `json:"select,omitempty"`
+	MultiSelect *SelectSchema   `json:"multi_select,omitempty"`
+	Status      *StatusSchema   `json:"status,omitempty"`
+	Relation    *RelationSchema `json:"relation,omitempty"`
+	Formula     *FormulaSchema  `json:"formula,omitempty"`
+	Rollup      *RollupSchema   `json:"rollup,omitempty"`
+	UniqueId    *UniqueIdSchema `json:"unique_id,omitempty"`
+}
+
+type NumberSchema struct {
+	Format string `json:"format"`
+}
+
+type SelectSchema struct {
+	Options []SelectOption `json:"options"`
+}
+
+type StatusSchema struct {
+	Options []SelectOption `json:"options"`
+	Groups  []StatusGroup  `json:"groups"`
+}
+
+type StatusGroup struct {
+	Id        string   `json:"id"`
+	Name      string   `json:"name"`
+	Color     string   `json:"color,omitempty"`
+	OptionIds []string `json:"option_ids"`
+}
+
+type RelationSchema struct {
+	Type         string `json:"type,omitempty"` // "single_property" or "dual_property"
+	DatabaseId   string `json:"database_id"`
+	DataSourceId string `json:"data_source_id,omitempty"` // VERSION_2025_09_03 and later
+}
+
+type FormulaSchema struct {
+	Expression string `json:"expression"`
+}
+
+type RollupSchema struct {
+	RelationPropertyName string `json:"relation_property_name"`
+	RelationPropertyId   string `json:"relation_property_id"`
+	RollupPropertyName   string `json:"rollup_property_name"`
+	RollupPropertyId     string `json:"rollup_property_id"`
+	Function             string `json:"function"`
+}
+
+type UniqueIdSchema struct {
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// Return the names of the options of a select, multi-select or status property
+func (p PropertySchema) OptionNames() []string {
+	var options []SelectOption
+	switch {
+	case p.Select != nil:
+		options = p.Select.Options
+	case p.MultiSelect != nil:
+		options = p.MultiSelect.Options
+	case p.Status != nil:
+		options = p.Status.Options
+	}
+	names := make([]string, len(options))
+	for i, option := range options {
+		names[i] = option.Name
+	}
+	return names
+}
+
+// ===============================================================
+
+// A data source holds the property schema of a database
+// on VERSION_2025_09_03 and later
+type DataSource struct {
+	Id         string                    `json:"id"`
+	Title      []RichText                `json:"title,omitempty"`
+	Properties map[string]PropertySchema `json:"properties,omitempty"`
+}
+
+func (api *ApiConfig) GetDataSource(ctx context.Context, dataSourceId string) (*DataSource, error) {
+	defer logging.LogFunction(
+		"pages.GetDataSource", time.Now(), "Getting data source",
+		map[string]interface{}{"data_source_id": dataSourceId},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	body, err := api.do(ctx, apiRequest{
+		Method:     "GET",
+		Path:       fmt.Sprintf("/data_sources/%s", dataSourceId),
+		Idempotent: true,
+	})
+	if err != nil {
+		logger.Err(err).Msg("Unable to retrieve data source")
+		return nil, err
+	}
+	logger.Trace().RawJSON("data_source_response_json", body).Msg("Receieved Notion API response")
+
+	var ds DataSource
+	if err := decodeResponse(body, "data_source", &ds); err != nil {
+		logger.Err(err).Str("data_source_id", dataSourceId).Send()
+		return nil, err
+	}
+	return &ds, nil
+}
+
+// Return the property schemas of the database: one for the database itself,
+// or one per queried data source on versions that have them
+func (api *ApiConfig) schemas(ctx context.Context) ([]map[string]PropertySchema, error) {
+	if !api.usesDataSources() {
+		db, err := api.GetDatabaseWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]PropertySchema{db.Properties}, nil
+	}
+
+	ids, err := api.dataSourceIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schemas := []map[string]PropertySchema{}
+	for _, id := range ids {
+		ds, err := api.GetDataSource(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, ds.Properties)
+	}
+	return schemas, nil
+}
+
+// Check that every database has the properties this package relies on,
+// falling back to built-in timestamps where a property is missing or has the wrong type.
+// Errors are only returned if a schema can't be retrieved
+func (api *ApiConfig) ValidateSchema(ctx context.Context) error {
+	defer logging.LogFunction(
+		"pages.ValidateSchema", time.Now(), "Validating database schema",
+		map[string]interface{}{"created_property": api.createdProperty()},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	if api.createdTypes == nil {
+		api.createdTypes = map[string]string{}
+	}
+
+	var firstErr error
+	for _, databaseId := range api.GetDatabaseIds() {
+		schemas, err := api.WithDatabase(databaseId).schemas(ctx)
+		if err != nil {
+			logger.Err(err).Str("database_id", databaseId).Msg("Unable to validate database schema")
+			if firstErr == nil {
+				firstErr = fmt.Errorf("Unable to retrieve schema of database %s: %w", databaseId, err)
+			}
+			continue
+		}
+
+		createdType := createdPropertyType(schemas, api.createdProperty())
+		api.createdTypes[NormalizeId(databaseId)] = createdType
+		if createdType == "" {
+			logger.Warn().
+				Str("database_id", databaseId).
+				Str("property", api.createdProperty()).
+				Msg("Database has no usable created property, filtering on created_time instead")
+		}
+	}
+
+	return firstErr
+}
+
+// Return the type of the named property if it can filter by creation date in every schema,
+// or an empty string if it can't
+func createdPropertyType(schemas []map[string]PropertySchema, name string) string {
+	propertyType := ""
+	for i, schema := range schemas {
+		property, ok := schema[name]
+		if !ok || (property.Type != PROPERTY_DATE && property.Type != PROPERTY_CREATED_TIME) {
+			return ""
+		}
+		if i > 0 && property.Type != propertyType {
+			return ""
+		}
+		propertyType = property.Type
+	}
+	return propertyType
+}
+
+// Return the type of the created property of the current database, if ValidateSchema found it
+func (api *ApiConfig) createdType() (string, bool) {
+	propertyType, validated := api.createdTypes[NormalizeId(api.DatabaseId)]
+	return propertyType, validated
+}
+
+func (api *ApiConfig) createdProperty() string {
+	if api.CreatedProperty == "" {
+		return DEFAULT_CREATED_PROPERTY
+	}
+	return api.CreatedProperty
+}
+
+// Return a filter for pages created after sinceTime, using the condition
+// that suits the created property as found by ValidateSchema
+func (api *ApiConfig) createdSinceFilter(sinceTime time.Time) *Filter {
+	condition := DateCondition{After: sinceTime.Format(ISO_TIME)}
+	propertyType, validated := api.createdType()
+	if !validated {
+		propertyType = PROPERTY_DATE
+	}
+
+	var filter Filter
+	switch propertyType {
+	case PROPERTY_DATE:
+		filter = Property(api.createdProperty()).Date(condition)
+	case PROPERTY_CREATED_TIME:
+		filter = Property(api.createdProperty()).CreatedTime(condition)
+	default:
+		filter = CreatedTime(condition)
+	}
+	return &filter
+}
@@ -0,0 +1,236 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Return a database schema with a "Created" property of the given type, or none if it's "missing"
+func mockSchema(createdType string) string {
+	created := ""
+	if createdType != "missing" {
+		created = fmt.Sprintf(`"Created": {"id": "MEdb", "name": "Created", "type": "%s", "%s": {}},`, createdType, createdType)
+	}
+	return fmt.Sprintf(mockSchemaTemplate, created)
+}
+
+const mockSchemaTemplate = `{
+	"object": "database",
+	"id": "99999999-abcd-efgh-1234-000000000000",
+	"title": [{"type": "text", "text": {"content": "Reading List"}, "plain_text": "Reading List"}],
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		%s
+		"Tags": {
+			"id": "flsb",
+			"name": "Tags",
+			"type": "multi_select",
+			"multi_select": {
+				"options": [
+					{"id": "5de29601-9c24-4b04-8629-0bca891c5120", "name": "Fiction", "color": "blue"},
+					{"id": "385890b8-fe15-421b-b214-b02959b0f8d9", "name": "History", "color": "gray"}
+				]
+			}
+		},
+		"Status": {
+			"id": "biOx",
+			"name": "Status",
+			"type": "status",
+			"status": {
+				"options": [{"id": "034ece9a-384d-4d1f-97f7-7f685b29ae9b", "name": "Not started", "color": "default"}],
+				"groups": [{"id": "b9d42483-e576-4858-a26f-ed940a5f678f", "name": "To-do", "color": "gray", "option_ids": ["034ece9a-384d-4d1f-97f7-7f685b29ae9b"]}]
+			}
+		},
+		"Author": {
+			"id": "%%3Dz7p",
+			"name": "Author",
+			"type": "relation",
+			"relation": {"database_id": "0c1f7cb2-8090-4f18-924e-d92965055e32", "type": "single_property", "single_property": {}}
+		},
+		"Pages": {
+			"id": "xX%%7DW",
+			"name": "Pages",
+			"type": "rollup",
+			"rollup": {
+				"rollup_property_name": "Page count",
+				"relation_property_name": "Author",
+				"rollup_property_id": "m%%3EMZ",
+				"relation_property_id": "%%3Dz7p",
+				"function": "sum"
+			}
+		}
+	}
+}`
+
+// Serve a database schema, recording the filter of each query
+func mockNotionSchemaServer(createdType string, queryStatus int) (*httptest.Server, *ApiConfig, *[]*Filter) {
+	filters := []*Filter{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/databases/" + mockDatabaseId:
+			w.Write([]byte(mockSchema(createdType)))
+		case "/databases/" + mockDatabaseId + "/query":
+			body, _ := io.ReadAll(r.Body)
+			var request pageRequest
+			json.Unmarshal(body, &request)
+			filters = append(filters, request.Filter)
+			if len(filters) == 1 && queryStatus != http.StatusOK {
+				w.WriteHeader(queryStatus)
+				w.Write([]byte(`{"object": "error", "status": 400, "code": "validation_error", "message": "Could not find property with name or id: Created"}`))
+				return
+			}
+			w.Write([]byte(`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		DatabaseId:  mockDatabaseId,
+		SecretToken: mockApiToken,
+	}
+	return server, api, &filters
+}
+
+func TestDecodeDatabaseSchema(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionSchemaServer("created_time", http.StatusOK)
+	defer ts.Close()
+
+	// Act
+	db, err := api.GetDatabase()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "Reading List", db.Name())
+	assert.Equal(t, PROPERTY_CREATED_TIME, db.Properties["Created"].Type)
+	assert.Equal(t, []string{"Fiction", "History"}, db.Properties["Tags"].OptionNames())
+	assert.Equal(t, []string{"Not started"}, db.Properties["Status"].OptionNames())
+	assert.Equal(t, []string{"034ece9a-384d-4d1f-97f7-7f685b29ae9b"}, db.Properties["Status"].Status.Groups[0].OptionIds)
+	assert.Equal(t, &RelationSchema{Type: "single_property", DatabaseId: "0c1f7cb2-8090-4f18-924e-d92965055e32"}, db.Properties["Author"].Relation)
+	assert.Equal(t, "sum", db.Properties["Pages"].Rollup.Function)
+	assert.Equal(t, "Author", db.Properties["Pages"].Rollup.RelationPropertyName)
+	assert.Equal(t, "%3Dz7p", db.Properties["Author"].Id) // Property IDs are URL-encoded by Notion
+}
+
+func TestValidateSchemaChoosesCreatedFilter(t *testing.T) {
+	since := time.Date(2021, 11, 5, 12, 54, 0, 0, time.UTC)
+	condition := DateCondition{After: since.Format(ISO_TIME)}
+	tests := []struct {
+		createdType string
+		expected    Filter
+	}{
+		{PROPERTY_DATE, Filter{Property: "Created", Date: &condition}},
+		{PROPERTY_CREATED_TIME, Filter{Property: "Created", CreatedTime: &condition}},
+		{PROPERTY_RICH_TEXT, Filter{Timestamp: PROPERTY_CREATED_TIME, CreatedTime: &condition}},
+		{"missing", Filter{Timestamp: PROPERTY_CREATED_TIME, CreatedTime: &condition}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.createdType, func(t *testing.T) {
+			// Arrange
+			ts, api, filters := mockNotionSchemaServer(test.createdType, http.StatusOK)
+			defer ts.Close()
+
+			// Act
+			err := api.ValidateSchema(context.Background())
+			require.NoError(t, err)
+			_, err = api.GetPagesSinceTime(since)
+
+			// Assert
+			require.NoError(t, err)
+			require.Len(t, *filters, 1)
+			assert.Equal(t, test.expected, *(*filters)[0])
+		})
+	}
+}
+
+func TestValidatedSchemaIsSharedByCopies(t *testing.T) {
+	// Arrange
+	ts, mock, _ := mockNotionSchemaServer(PROPERTY_CREATED_TIME, http.StatusOK)
+	defer ts.Close()
+	api := NewApiConfig()
+	api.Url = mock.Url
+	api.DatabaseId = mockDatabaseId
+	api.SecretToken = mockApiToken
+	dashed := api.WithDatabase("99999999-ABCD-efgh-1234-000000000000")
+	since := time.Date(2021, 11, 5, 12, 54, 0, 0, time.UTC)
+
+	// Act
+	err := api.ValidateSchema(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	condition := DateCondition{After: since.Format(ISO_TIME)}
+	assert.Equal(t, Filter{Property: "Created", CreatedTime: &condition}, *dashed.createdSinceFilter(since))
+}
+
+func TestValidateSchemaError(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find database"}`, http.StatusNotFound)
+	defer ts.Close()
+
+	// Act
+	err := api.ValidateSchema(context.Background())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestCreatedFilterFallsBackWithoutValidation(t *testing.T) {
+	// Arrange
+	ts, api, filters := mockNotionSchemaServer("missing", http.StatusBadRequest)
+	defer ts.Close()
+	since := time.Date(2021, 11, 5, 12, 54, 0, 0, time.UTC)
+
+	// Act
+	pages, err := api.GetPagesSinceTime(since)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, pages)
+	require.Len(t, *filters, 2)
+	assert.Equal(t, "Created", (*filters)[0].Property)
+	assert.Equal(t, PROPERTY_CREATED_TIME, (*filters)[1].Timestamp)
+}
+
+func TestValidateSchemaOfDataSources(t *testing.T) {
+	// Arrange
+	requested := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/data_sources/ds-articles":
+			w.Write([]byte(`{"object": "data_source", "id": "ds-articles", "properties": {"Created": {"id": "a", "name": "Created", "type": "date", "date": {}}}}`))
+		case "/data_sources/ds-notes":
+			w.Write([]byte(`{"object": "data_source", "id": "ds-notes", "properties": {"Name": {"id": "title", "name": "Name", "type": "title", "title": {}}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	api := &ApiConfig{
+		Url:           ts.URL,
+		DatabaseId:    mockDatabaseId,
+		Version:       VERSION_2025_09_03,
+		DataSourceIds: []string{"ds-articles", "ds-notes"},
+	}
+
+	// Act
+	err := api.ValidateSchema(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data_sources/ds-articles", "/data_sources/ds-notes"}, requested)
+	assert.Equal(t, PROPERTY_CREATED_TIME, api.createdSinceFilter(time.Now()).Timestamp) // Not every data source has the property
+}
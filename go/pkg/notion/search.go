@@ -0,0 +1,199 @@
+package notion
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Kinds of object a search can be limited to
+const (
+	SEARCH_OBJECT_PAGE     = "page"
+	SEARCH_OBJECT_DATABASE = "database" // Sent as "data_source" on versions that have them
+)
+
+// Searcher finds the pages and databases shared with the integration
+type Searcher interface {
+	Search(context.Context, SearchQuery) ([]SearchResult, error)
+}
+
+type SearchQuery struct {
+	Query  string      // Text to match against titles; everything if empty
+	Object string      // SEARCH_OBJECT_PAGE or SEARCH_OBJECT_DATABASE; both if empty
+	Sort   *SearchSort // Notion's own relevance order if nil
+}
+
+// Search results can only be sorted by when they were last edited
+type SearchSort struct {
+	Direction string `json:"direction"`
+	Timestamp string `json:"timestamp"`
+}
+
+func SortByLastEdited(direction string) *SearchSort {
+	return &SearchSort{Direction: direction, Timestamp: PROPERTY_LAST_EDITED_TIME}
+}
+
+// A page or database found by a search
+type SearchResult struct {
+	Object         string                   `json:"object"` // "page", "database" or "data_source"
+	Id             string                   `json:"id"`
+	CreatedTime    string                   `json:"created_time"`
+	LastEditedTime string                   `json:"last_edited_time"`
+	Url            string                   `json:"url"`
+	Title          []RichText               `json:"title,omitempty"`      // Databases only
+	Properties     map[string]PropertyValue `json:"properties,omitempty"` // Pages only
+	Parent         *PageParent              `json:"parent,omitempty"`
+	Archived       bool                     `json:"archived,omitempty"`
+	InTrash        bool                     `json:"in_trash,omitempty"`
+}
+
+func (r SearchResult) IsPage() bool {
+	return r.Object == SEARCH_OBJECT_PAGE
+}
+
+// Return the plain text title of the page or database
+func (r SearchResult) Name() string {
+	if r.IsPage() {
+		return r.Page().Title()
+	}
+	return PlainText(r.Title)
+}
+
+// Return the ID of the database found, which on versions with data sources
+// is the parent of the data source returned; only meaningful if not IsPage
+func (r SearchResult) DatabaseId() string {
+	if r.Object == "data_source" && r.Parent != nil && r.Parent.DatabaseId != "" {
+		return r.Parent.DatabaseId
+	}
+	return r.Id
+}
+
+// Return the result as a Page; only meaningful if IsPage
+func (r SearchResult) Page() Page {
+	return Page{
+		Id:             r.Id,
+		CreatedTime:    r.CreatedTime,
+		LastEditedTime: r.LastEditedTime,
+		Url:            r.Url,
+		Properties:     r.Properties,
+		Archived:       r.Archived,
+		InTrash:        r.InTrash,
+	}
+}
+
+// ===============================================================
+// Notion search request body,
+// per https://developers.notion.com/reference/post-search
+// ---------------------------------------------------------------
+type searchRequest struct {
+	Query       string        `json:"query,omitempty"`
+	Filter      *searchFilter `json:"filter,omitempty"`
+	Sort        *SearchSort   `json:"sort,omitempty"`
+	PageSize    uint8         `json:"page_size"`
+	StartCursor string        `json:"start_cursor,omitempty"`
+}
+
+type searchFilter struct {
+	Property string `json:"property"`
+	Value    string `json:"value"`
+}
+
+// ===============================================================
+
+type searchResponse struct {
+	Object  string         `json:"object"`
+	Results []SearchResult `json:"results"`
+	Next    string         `json:"next_cursor"`
+	HasMore bool           `json:"has_more"`
+}
+
+// Return every page and database shared with the integration that matches query.
+// If ctx is cancelled or its deadline is near, the results retrieved so far
+// are returned along with a *PartialResultError
+func (api *ApiConfig) Search(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	defer logging.LogFunction(
+		"search.Search", time.Now(), "Searching workspace",
+		map[string]interface{}{
+			"query":  query.Query,
+			"object": query.Object,
+		},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	ctx, cancel := api.pagingContext(ctx)
+	defer cancel()
+
+	request := searchRequest{
+		Query:    query.Query,
+		Sort:     query.Sort,
+		PageSize: api.PageSize,
+	}
+	if query.Object != "" {
+		request.Filter = &searchFilter{Property: "object", Value: api.searchObject(query.Object)}
+	}
+
+	results := []SearchResult{}
+	seen := cursors{}
+	for hasMore := true; hasMore; {
+		if len(results) >= api.maxPages() {
+			logger.Warn().Int("max_pages", api.maxPages()).Msg("Stopped searching at the page limit")
+			return results, &PartialResultError{Cursor: request.StartCursor, Pages: len(results), Err: ErrPageLimit}
+		}
+		response, err := api.search(ctx, request)
+		if err == nil {
+			err = seen.check(response.HasMore, response.Next)
+		}
+		if err != nil {
+			if isContextError(err) {
+				return results, &PartialResultError{Cursor: request.StartCursor, Pages: len(results), Err: err}
+			}
+			logger.Err(err).Msg("Unable to search workspace")
+			return nil, err
+		}
+		results = append(results, response.Results...)
+		hasMore = response.HasMore
+		request.StartCursor = response.Next
+	}
+
+	return results, nil
+}
+
+// Return every page shared with the integration
+func (api *ApiConfig) SearchPages(ctx context.Context) ([]Page, error) {
+	results, err := api.Search(ctx, SearchQuery{Object: SEARCH_OBJECT_PAGE})
+	if results == nil {
+		return nil, err
+	}
+	pages := make([]Page, 0, len(results))
+	for _, result := range results {
+		pages = append(pages, result.Page())
+	}
+	return pages, err
+}
+
+func (api *ApiConfig) searchObject(object string) string {
+	if object == SEARCH_OBJECT_DATABASE && api.usesDataSources() {
+		return "data_source"
+	}
+	return object
+}
+
+func (api *ApiConfig) search(ctx context.Context, request searchRequest) (searchResponse, error) {
+	// Searching doesn't modify anything, so it's safe to retry from the same cursor
+	body, err := api.do(ctx, apiRequest{
+		Method:     "POST",
+		Path:       "/search",
+		Body:       request,
+		Idempotent: true,
+	})
+	if err != nil {
+		return searchResponse{}, err
+	}
+	logging.GetLoggerWithContext(ctx).Trace().RawJSON("search_response_json", body).Msg("Receieved Notion API response")
+
+	var response searchResponse
+	if err := decodeResponse(body, "list", &response); err != nil {
+		return searchResponse{}, err
+	}
+	return response, nil
+}
@@ -0,0 +1,191 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockNotionSearchServer(responses []string) (*httptest.Server, *ApiConfig, *[]searchRequest) {
+	requests := []searchRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var request searchRequest
+		json.Unmarshal(body, &request)
+		requests = append(requests, request)
+		w.Write([]byte(responses[len(requests)-1]))
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		SecretToken: mockApiToken,
+		PageSize:    2,
+	}
+	return server, api, &requests
+}
+
+func TestSearchDatabases(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionSearchServer([]string{
+		`{
+			"object": "list",
+			"results": [
+				{
+					"object": "database",
+					"id": "668d797c-76fa-4934-9b05-ad288df2d136",
+					"title": [{"type": "text", "text": {"content": "Reading List"}, "plain_text": "Reading List"}],
+					"url": "https://www.notion.so/668d797c76fa49349b05ad288df2d136"
+				}
+			],
+			"next_cursor": "cursor-2",
+			"has_more": true
+		}`,
+		`{
+			"object": "list",
+			"results": [
+				{
+					"object": "database",
+					"id": "a3f1c9e0-9b2d-4c1e-8f5a-1d2e3f4a5b6c",
+					"title": [{"type": "text", "text": {"content": "Zettelkasten"}, "plain_text": "Zettelkasten"}]
+				}
+			],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+	})
+	defer ts.Close()
+
+	// Act
+	results, err := api.Search(context.Background(), SearchQuery{
+		Object: SEARCH_OBJECT_DATABASE,
+		Sort:   SortByLastEdited(DESCENDING),
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Reading List", results[0].Name())
+	assert.Equal(t, "Zettelkasten", results[1].Name())
+	assert.False(t, results[0].IsPage())
+
+	require.Len(t, *requests, 2)
+	first := (*requests)[0]
+	assert.Equal(t, &searchFilter{Property: "object", Value: "database"}, first.Filter)
+	assert.Equal(t, &SearchSort{Direction: DESCENDING, Timestamp: "last_edited_time"}, first.Sort)
+	assert.Equal(t, uint8(2), first.PageSize)
+	assert.Equal(t, "", first.StartCursor)
+	assert.Equal(t, "cursor-2", (*requests)[1].StartCursor)
+}
+
+func TestSearchDataSourcesOnNewerVersions(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionSearchServer([]string{`{
+		"object": "list",
+		"results": [{
+			"object": "data_source",
+			"id": "c174b72c-d782-432f-8dc0-b647e1c96df6",
+			"parent": {"type": "database_id", "database_id": "` + mockDatabaseId + `"}
+		}],
+		"next_cursor": null,
+		"has_more": false
+	}`})
+	defer ts.Close()
+	api.Version = VERSION_2025_09_03
+
+	// Act
+	results, err := api.Search(context.Background(), SearchQuery{Object: SEARCH_OBJECT_DATABASE})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "data_source", (*requests)[0].Filter.Value)
+	require.Len(t, results, 1)
+	assert.Equal(t, mockDatabaseId, results[0].DatabaseId())
+}
+
+func TestSearchPages(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionSearchServer([]string{
+		`{
+			"object": "list",
+			"results": [
+				{
+					"object": "page",
+					"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+					"created_time": "2021-11-05T12:54:00.000Z",
+					"last_edited_time": "2021-11-05T12:55:00.000Z",
+					"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+					"properties": {
+						"title": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Initial goals"}, "plain_text": "Initial goals"}]}
+					}
+				}
+			],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+	})
+	defer ts.Close()
+
+	// Act
+	pages, err := api.SearchPages(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, "3350ba04-48b1-43e3-8726-1b1e9828b2b3", pages[0].Id)
+	assert.Equal(t, "Initial goals", pages[0].Title())
+	assert.Equal(t, "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3", pages[0].Url)
+	assert.Equal(t, &searchFilter{Property: "object", Value: "page"}, (*requests)[0].Filter)
+	assert.Nil(t, (*requests)[0].Sort)
+}
+
+func TestSearchPagesKeepsRemovedPages(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionSearchServer([]string{
+		`{
+			"object": "list",
+			"results": [
+				{"object": "page", "id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3", "archived": true},
+				{"object": "page", "id": "5331da24-6597-4f2d-a684-fd94a0f3278a", "in_trash": true},
+				{"object": "page", "id": "4c5a7e2b-0d3f-4b8e-9a61-2f7d8c9e0a1b"}
+			],
+			"next_cursor": null,
+			"has_more": false
+		}`,
+	})
+	defer ts.Close()
+
+	// Act
+	pages, err := api.SearchPages(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, pages, 3)
+	assert.True(t, pages[0].Archived)
+	assert.True(t, pages[1].InTrash)
+	assert.True(t, pages[0].Removed())
+	assert.True(t, pages[1].Removed())
+	assert.False(t, pages[2].Removed())
+}
+
+func TestSearchError(t *testing.T) {
+	// Arrange
+	ts, api := mockNotionServer(`{"object": "error", "status": 401, "code": "unauthorized", "message": "API token is invalid."}`, http.StatusUnauthorized)
+	defer ts.Close()
+
+	// Act
+	results, err := api.Search(context.Background(), SearchQuery{})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Nil(t, results)
+}
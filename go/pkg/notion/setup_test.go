@@ -76,14 +76,25 @@ func mockNotionServerWithPaging(mockData []string, status int) (*httptest.Server
 	return server, api
 }
 
+// Properties of a page in the mock database, which has only a "Created" property
+func mockCreatedProperties(createdTime string) map[string]PropertyValue {
+	return map[string]PropertyValue{
+		"Created": {
+			Id:          "MEdb",
+			Type:        PROPERTY_CREATED_TIME,
+			CreatedTime: &createdTime,
+		},
+	}
+}
+
 func notionHeaderIsValid(w http.ResponseWriter, r *http.Request) bool {
 	if contains(r.Header.Values("Authorization"), fmt.Sprintf("Bearer %s", mockApiToken)) == false {
 		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`{"object": "error","status": 400,"code": "unauthorized","message": "API token is invalid."}`))
+		w.Write([]byte(`{"object": "error","status": 401,"code": "unauthorized","message": "API token is invalid."}`))
 		return false
 	} else if contains(r.Header.Values("Notion-Version"), mockApiVersion) == false {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte(`{"object": "error","status": 401,"code": "missing_version","message": "Notion-Version header should be defined..."}`))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"object": "error","status": 400,"code": "missing_version","message": "Notion-Version header should be defined..."}`))
 		return false
 	} else {
 		return true
@@ -105,7 +116,7 @@ func filterValuePassed(r *http.Request) bool {
 
 	var pageRequest pageRequest
 	json.Unmarshal(body, &pageRequest)
-	return pageRequest.Filter.Date.After != ""
+	return pageRequest.Filter != nil && pageRequest.Filter.Date != nil && pageRequest.Filter.Date.After != ""
 }
 
 func contains(input []string, expected string) bool {
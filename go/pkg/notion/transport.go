@@ -0,0 +1,296 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+const DEFAULT_USER_AGENT = "random-notion"
+const DEFAULT_CLIENT_TIMEOUT = 30 * time.Second
+
+// Shared by every ApiConfig without its own HttpClient, so that idle
+// connections to Notion are kept alive and reused across warm Lambda invocations.
+// Its Timeout, as that of any HttpClient, limits each attempt at a request rather than every retry of it
+var DefaultHttpClient = &http.Client{
+	Timeout: DEFAULT_CLIENT_TIMEOUT,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	},
+}
+
+// Middleware wraps the transport used for every request to the Notion API
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Return the client used to send requests, with the middleware chain applied to its transport.
+// Requests pass through the Notion headers, then retries, then the rate limiter, then the client's
+// timeout, then api.Middlewares in order, so that custom middleware sees every individual attempt
+func (api *ApiConfig) client() *http.Client {
+	base := api.HttpClient
+	if base == nil {
+		base = DefaultHttpClient
+	}
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	chain := []Middleware{
+		HeaderMiddleware("Authorization", fmt.Sprintf("Bearer %s", api.SecretToken)),
+		HeaderMiddleware("Notion-Version", api.version()),
+		HeaderMiddleware("User-Agent", api.userAgent()),
+		RetryMiddleware(api.Retry),
+		RateLimitMiddleware(api.RateLimiter),
+		TimeoutMiddleware(base.Timeout),
+	}
+	chain = append(chain, api.Middlewares...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		transport = chain[i](transport)
+	}
+
+	client := *base
+	client.Transport = transport
+	client.Timeout = 0 // Applied to each attempt by TimeoutMiddleware
+	return &client
+}
+
+func (api *ApiConfig) userAgent() string {
+	if api.UserAgent == "" {
+		return DEFAULT_USER_AGENT
+	}
+	return api.UserAgent
+}
+
+// Set a header on every request that doesn't already have one
+func HeaderMiddleware(key string, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(key) != "" {
+				return next.RoundTrip(req)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(key, value)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type idempotentKey struct{}
+
+// Mark requests made with ctx as safe to retry even though their method isn't
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// Retry failed idempotent requests according to policy.
+// Request bodies are replayed with req.GetBody, as set by http.NewRequest
+func RetryMiddleware(policy RetryPolicy) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			logger := logging.GetLoggerWithContext(ctx)
+			retryable := isIdempotent(req) && (req.Body == nil || req.GetBody != nil)
+
+			for attempt := 1; ; attempt++ {
+				attemptReq := req
+				if attempt > 1 && req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, fmt.Errorf("Unable to replay request body: %w", err)
+					}
+					attemptReq = req.Clone(ctx)
+					attemptReq.Body = body
+				}
+
+				res, err := next.RoundTrip(attemptReq)
+				if !retryable || !policy.shouldRetry(attempt, res, err) {
+					return res, err
+				}
+
+				delay := policy.delay(attempt, res)
+				if res != nil {
+					errBody, _ := io.ReadAll(res.Body)
+					res.Body.Close()
+					err = newAPIError(res, errBody)
+				}
+				logger.Warn().
+					Err(err).
+					Int("attempt", attempt).
+					Dur("retry_delay", delay).
+					Msg("Retrying Notion API request")
+				if sleepErr := sleep(ctx, delay); sleepErr != nil {
+					return nil, &retryAbandonedError{Err: err, Cause: sleepErr}
+				}
+			}
+		})
+	}
+}
+
+// Fail each attempt at a request that takes longer than timeout, including reading its body,
+// so that a slow attempt can be retried. Attempts aren't limited if timeout is zero
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if timeout <= 0 {
+			return next
+		}
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			res, err := next.RoundTrip(req.WithContext(ctx))
+			if err != nil {
+				// Unlike the caller's deadline passing, an attempt timing out is worth retrying
+				if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+					err = fmt.Errorf("%w after %s", ErrAttemptTimeout, timeout)
+				}
+				cancel()
+				return res, err
+			}
+			res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+			return res, nil
+		})
+	}
+}
+
+// Ends the context of an attempt once its response has been read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Log each request and its outcome at debug level
+func LoggingMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			logger := logging.GetLoggerWithContext(req.Context())
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+
+			event := logger.Debug().
+				Str("request_verb", req.Method).
+				Str("request_url", req.URL.String()).
+				Dur("duration", time.Since(start))
+			if err != nil {
+				event.Err(err).Msg("Notion API request failed")
+			} else {
+				event.Int("status", res.StatusCode).Msg("Notion API request completed")
+			}
+			return res, err
+		})
+	}
+}
+
+// RequestMetrics describes a single attempt at a request to the Notion API
+type RequestMetrics struct {
+	Method   string
+	Path     string
+	Status   int // Zero if no response was received
+	Duration time.Duration
+	Err      error
+}
+
+// Report metrics for each request to record, e.g. to publish them to CloudWatch
+func MetricsMiddleware(record func(RequestMetrics)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+
+			metrics := RequestMetrics{
+				Method:   req.Method,
+				Path:     req.URL.Path,
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if res != nil {
+				metrics.Status = res.StatusCode
+			}
+			record(metrics)
+			return res, err
+		})
+	}
+}
+
+// FaultInjection describes failures to simulate when testing how callers
+// cope with an unreliable Notion API
+type FaultInjection struct {
+	Rate    float64       // Fraction of requests to fail, between 0 and 1
+	Status  int           // Status of the failed responses; http.StatusServiceUnavailable if zero
+	Err     error         // If set, fail with this transport error instead of a response
+	Latency time.Duration // Delay added to every request
+}
+
+// Fail a random fraction of requests without sending them to Notion
+func FaultInjectionMiddleware(faults FaultInjection) Middleware {
+	status := faults.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if faults.Latency > 0 {
+				if err := sleep(req.Context(), faults.Latency); err != nil {
+					return nil, err
+				}
+			}
+			if faults.Rate <= 0 || rand.Float64() >= faults.Rate {
+				return next.RoundTrip(req)
+			}
+
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			if faults.Err != nil {
+				return nil, faults.Err
+			}
+			body := fmt.Sprintf(`{"object": "error", "status": %d, "code": "injected_fault", "message": "Injected fault"}`, status)
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+				StatusCode:    status,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": []string{"application/json"}},
+				Body:          io.NopCloser(strings.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		})
+	}
+}
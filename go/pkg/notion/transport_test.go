@@ -0,0 +1,177 @@
+package notion
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockDatabaseServer(handler func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(r)
+		w.Write([]byte(`{"object": "database", "id": "99999999-abcd-efgh-1234-000000000000"}`))
+	}))
+}
+
+func TestRequestHeadersSetByMiddleware(t *testing.T) {
+	// Arrange
+	var headers http.Header
+	ts := mockDatabaseServer(func(r *http.Request) { headers = r.Header })
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, SecretToken: mockApiToken, Version: mockApiVersion}
+
+	// Act
+	_, err := api.GetDatabase()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+mockApiToken, headers.Get("Authorization"))
+	assert.Equal(t, mockApiVersion, headers.Get("Notion-Version"))
+	assert.Equal(t, DEFAULT_USER_AGENT, headers.Get("User-Agent"))
+}
+
+func TestCustomUserAgent(t *testing.T) {
+	// Arrange
+	var userAgent string
+	ts := mockDatabaseServer(func(r *http.Request) { userAgent = r.UserAgent() })
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, UserAgent: "my-integration/1.0"}
+
+	// Act
+	_, err := api.GetDatabase()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "my-integration/1.0", userAgent)
+}
+
+func TestInjectedHttpClient(t *testing.T) {
+	// Arrange
+	var requested string
+	client := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"object": "database", "id": "from-custom-transport"}`)),
+			Request:    req,
+		}, nil
+	})}
+	api := &ApiConfig{Url: "https://notion.test/v1", DatabaseId: mockDatabaseId, HttpClient: client}
+
+	// Act
+	db, err := api.GetDatabase()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "from-custom-transport", db.Id)
+	assert.Equal(t, "https://notion.test/v1/databases/"+mockDatabaseId, requested)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	// Arrange
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	ts := mockDatabaseServer(func(r *http.Request) { calls = append(calls, "server") })
+	defer ts.Close()
+	api := &ApiConfig{
+		Url:         ts.URL,
+		DatabaseId:  mockDatabaseId,
+		Middlewares: []Middleware{record("first"), record("second")},
+	}
+
+	// Act
+	_, err := api.GetDatabase()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "server"}, calls)
+}
+
+func TestMiddlewareSeesEachRetry(t *testing.T) {
+	// Arrange
+	requests := 0
+	ts := mockDatabaseServer(func(r *http.Request) { requests++ })
+	defer ts.Close()
+	metrics := []RequestMetrics{}
+	api := &ApiConfig{
+		Url:        ts.URL,
+		DatabaseId: mockDatabaseId,
+		Retry:      testRetryPolicy(),
+		Middlewares: []Middleware{
+			MetricsMiddleware(func(m RequestMetrics) { metrics = append(metrics, m) }),
+			FaultInjectionMiddleware(FaultInjection{Rate: 1, Status: http.StatusBadGateway}),
+		},
+	}
+
+	// Act
+	_, err := api.GetDatabase()
+
+	// Assert
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, &APIError{Code: "injected_fault"}))
+	assert.Equal(t, 0, requests)
+	require.Len(t, metrics, 3)
+	for _, m := range metrics {
+		assert.Equal(t, http.MethodGet, m.Method)
+		assert.Equal(t, "/databases/"+mockDatabaseId, m.Path)
+		assert.Equal(t, http.StatusBadGateway, m.Status)
+	}
+}
+
+func TestRetryReplaysRequestBody(t *testing.T) {
+	// Arrange
+	bodies := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"object": "list", "results": [], "next_cursor": null, "has_more": false}`))
+	}))
+	defer ts.Close()
+	api := &ApiConfig{Url: ts.URL, DatabaseId: mockDatabaseId, PageSize: 10, Retry: testRetryPolicy()}
+
+	// Act
+	_, err := api.GetPages()
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, bodies, 2)
+	assert.NotEmpty(t, bodies[0])
+	assert.Equal(t, bodies[0], bodies[1])
+}
+
+func TestFaultInjectionTransportError(t *testing.T) {
+	// Arrange
+	faultErr := errors.New("connection reset")
+	api := &ApiConfig{
+		Url:         "https://notion.test/v1",
+		DatabaseId:  mockDatabaseId,
+		Middlewares: []Middleware{FaultInjectionMiddleware(FaultInjection{Rate: 1, Err: faultErr})},
+	}
+
+	// Act
+	start := time.Now()
+	_, err := api.GetDatabase()
+
+	// Assert
+	assert.True(t, errors.Is(err, faultErr))
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,273 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/logging"
+)
+
+// Notion user object, per https://developers.notion.com/reference/user
+type User struct {
+	Object    string  `json:"object,omitempty"`
+	Id        string  `json:"id"`
+	Type      string  `json:"type,omitempty"` // "person" or "bot"
+	Name      string  `json:"name,omitempty"`
+	AvatarUrl string  `json:"avatar_url,omitempty"`
+	Person    *Person `json:"person,omitempty"`
+}
+
+type Person struct {
+	Email string `json:"email,omitempty"`
+}
+
+// UserResolver looks up the users referenced by pages, which Notion only identifies by ID
+type UserResolver interface {
+	ResolveUsers(context.Context, *Page) error
+	ResolveCommentUsers(context.Context, []Comment) error
+	FindUsers(context.Context, string) ([]User, error)
+}
+
+type userResponse struct {
+	Object  string `json:"object"`
+	Results []User `json:"results"`
+	Next    string `json:"next_cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// UserCache holds users already retrieved from Notion, so that each is only requested once.
+// It is safe for concurrent use and shared by copies of an ApiConfig
+type UserCache struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewUserCache(users ...User) *UserCache {
+	cache := &UserCache{users: map[string]User{}}
+	cache.Add(users...)
+	return cache
+}
+
+// Cache users, skipping those without a name, which Notion returns for partial user objects
+func (c *UserCache) Add(users ...User) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, user := range users {
+		if user.Name != "" {
+			c.users[user.Id] = user
+		}
+	}
+}
+
+func (c *UserCache) Get(userId string) (User, bool) {
+	if c == nil {
+		return User{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	user, ok := c.users[userId]
+	return user, ok
+}
+
+// Return every cached user, e.g. to persist them between runs
+func (c *UserCache) Users() []User {
+	if c == nil {
+		return []User{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make([]User, 0, len(c.users))
+	for _, user := range c.users {
+		users = append(users, user)
+	}
+	return users
+}
+
+func (c *UserCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.users)
+}
+
+// Return every user in the workspace, excluding guests
+func (api *ApiConfig) ListUsers(ctx context.Context) ([]User, error) {
+	defer logging.LogFunction(
+		"users.ListUsers", time.Now(), "Listing users", map[string]interface{}{},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+
+	users := []User{}
+	cursor := ""
+	seen := cursors{}
+	for hasMore := true; hasMore; {
+		params := url.Values{}
+		if api.PageSize > 0 {
+			params.Set("page_size", strconv.Itoa(int(api.PageSize)))
+		}
+		if cursor != "" {
+			params.Set("start_cursor", cursor)
+		}
+		body, err := api.do(ctx, apiRequest{
+			Method:     "GET",
+			Path:       "/users?" + params.Encode(),
+			Idempotent: true,
+		})
+		if err != nil {
+			logger.Err(err).Msg("Unable to list users")
+			return nil, err
+		}
+		logger.Trace().RawJSON("users_response_json", body).Msg("Receieved Notion API response")
+
+		var response userResponse
+		if err = decodeResponse(body, "list", &response); err == nil {
+			err = seen.check(response.HasMore, response.Next)
+		}
+		if err != nil {
+			logger.Err(err).Msg("Unable to list users")
+			return nil, err
+		}
+		users = append(users, response.Results...)
+		hasMore = response.HasMore
+		cursor = response.Next
+	}
+
+	api.Users.Add(users...)
+	return users, nil
+}
+
+// Return the user with the given ID, from api.Users if it's been retrieved before
+func (api *ApiConfig) GetUser(ctx context.Context, userId string) (*User, error) {
+	if user, ok := api.Users.Get(userId); ok {
+		return &user, nil
+	}
+	user, err := api.getUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	api.Users.Add(*user)
+	return user, nil
+}
+
+// Return the bot user of the integration making requests
+func (api *ApiConfig) GetMe(ctx context.Context) (*User, error) {
+	return api.getUser(ctx, "me")
+}
+
+func (api *ApiConfig) getUser(ctx context.Context, userId string) (*User, error) {
+	defer logging.LogFunction(
+		"users.getUser", time.Now(), "Getting user",
+		map[string]interface{}{"user_id": userId},
+	)
+	logger := logging.GetLoggerWithContext(ctx)
+	body, err := api.do(ctx, apiRequest{
+		Method:     "GET",
+		Path:       fmt.Sprintf("/users/%s", userId),
+		Idempotent: true,
+	})
+	if err != nil {
+		logger.Err(err).Msg("Unable to retrieve user")
+		return nil, err
+	}
+	logger.Trace().RawJSON("user_response_json", body).Msg("Receieved Notion API response")
+
+	var user User
+	if err := decodeResponse(body, "user", &user); err != nil {
+		logger.Err(err).Str("user_id", userId).Send()
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Fill in the names of the users who wrote each comment, returning the first error
+func (api *ApiConfig) ResolveCommentUsers(ctx context.Context, comments []Comment) error {
+	var firstErr error
+	for i, comment := range comments {
+		if comment.CreatedBy == nil || comment.CreatedBy.Name != "" {
+			continue
+		}
+		user, err := api.GetUser(ctx, comment.CreatedBy.Id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("Unable to resolve user %s: %w", comment.CreatedBy.Id, err)
+			}
+			continue
+		}
+		comments[i].CreatedBy = user
+	}
+	return firstErr
+}
+
+// Fill in the names of the users who created and last edited the page,
+// and of those in its people properties. Users that can't be retrieved,
+// such as guests, are left as they are and the first error is returned.
+// Resolved users replace those of page rather than modifying them, as pages may share them
+func (api *ApiConfig) ResolveUsers(ctx context.Context, page *Page) error {
+	var firstErr error
+	resolve := func(user *User) *User {
+		if user == nil || user.Name != "" || user.Id == "" {
+			return user
+		}
+		resolved, err := api.GetUser(ctx, user.Id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("Unable to resolve user %s: %w", user.Id, err)
+			}
+			return user
+		}
+		return resolved
+	}
+
+	page.CreatedBy = resolve(page.CreatedBy)
+	page.LastEditedBy = resolve(page.LastEditedBy)
+	properties := make(map[string]PropertyValue, len(page.Properties))
+	for name, property := range page.Properties {
+		property.CreatedBy = resolve(property.CreatedBy)
+		property.LastEditedBy = resolve(property.LastEditedBy)
+		if property.People != nil {
+			people := make([]User, len(property.People))
+			for i := range property.People {
+				people[i] = *resolve(&property.People[i])
+			}
+			property.People = people
+		}
+		properties[name] = property
+	}
+	if page.Properties != nil {
+		page.Properties = properties
+	}
+	return firstErr
+}
+
+// Return the users whose ID is nameOrId or whose name matches it, ignoring case.
+// Every user is listed the first time a name isn't found in api.Users
+func (api *ApiConfig) FindUsers(ctx context.Context, nameOrId string) ([]User, error) {
+	if users := findUsers(api.Users.Users(), nameOrId); len(users) > 0 {
+		return users, nil
+	}
+	all, err := api.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findUsers(all, nameOrId), nil
+}
+
+func findUsers(users []User, nameOrId string) []User {
+	found := []User{}
+	for _, user := range users {
+		if user.Id == nameOrId || strings.EqualFold(user.Name, nameOrId) {
+			found = append(found, user)
+		}
+	}
+	return found
+}
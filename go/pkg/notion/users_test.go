@@ -0,0 +1,164 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockUserId = "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed"
+const mockUserId2 = "e79a0b74-3aba-4149-9f74-0bb5791a6ee6"
+
+// Serve two pages of users, each user individually, and the integration's bot user
+func mockNotionUsersServer() (*httptest.Server, *ApiConfig, *[]string) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/users":
+			if r.URL.Query().Get("start_cursor") == "" {
+				w.Write([]byte(`{"object": "list", "results": [{"object": "user", "id": "` + mockUserId + `", "type": "person", "name": "Ada Lovelace", "person": {"email": "ada@example.com"}}], "next_cursor": "cursor-2", "has_more": true}`))
+				return
+			}
+			w.Write([]byte(`{"object": "list", "results": [{"object": "user", "id": "` + mockUserId2 + `", "type": "person", "name": "Grace Hopper"}], "next_cursor": null, "has_more": false}`))
+		case "/users/" + mockUserId:
+			w.Write([]byte(`{"object": "user", "id": "` + mockUserId + `", "type": "person", "name": "Ada Lovelace"}`))
+		case "/users/me":
+			w.Write([]byte(`{"object": "user", "id": "b6fa0b8c-4c3d-4b5e-9b9b-0b4c1f8f9d2a", "type": "bot", "name": "Random Notion"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find user"}`))
+		}
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		SecretToken: mockApiToken,
+		PageSize:    1,
+		Users:       NewUserCache(),
+	}
+	return server, api, &requests
+}
+
+func TestListUsers(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionUsersServer()
+	defer ts.Close()
+
+	// Act
+	users, err := api.ListUsers(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "Ada Lovelace", users[0].Name)
+	assert.Equal(t, "ada@example.com", users[0].Person.Email)
+	assert.Equal(t, "Grace Hopper", users[1].Name)
+	assert.Equal(t, []string{"/users?page_size=1", "/users?page_size=1&start_cursor=cursor-2"}, *requests)
+	assert.Equal(t, 2, api.Users.Len())
+}
+
+func TestListUsersRejectsInvalidResponses(t *testing.T) {
+	tests := map[string]string{
+		"non-JSON body": "<html><body>502 Bad Gateway</body></html>",
+		"repeated cursor": `{"object": "list", "results": [{"object": "user", "id": "` + mockUserId + `", "name": "Ada Lovelace"}],
+			"next_cursor": "cursor-2", "has_more": true}`,
+	}
+
+	for name, body := range tests {
+		// Arrange
+		ts, api := mockNotionServer(body, http.StatusOK)
+
+		// Act
+		users, err := api.ListUsers(context.Background())
+		ts.Close()
+
+		// Assert
+		assert.Nil(t, users, name)
+		assert.ErrorIs(t, err, ErrInvalidResponse, name)
+	}
+}
+
+func TestGetUserIsCached(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionUsersServer()
+	defer ts.Close()
+
+	// Act
+	first, err := api.GetUser(context.Background(), mockUserId)
+	require.NoError(t, err)
+	second, err := api.GetUser(context.Background(), mockUserId)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, "Ada Lovelace", first.Name)
+	assert.Equal(t, first, second)
+	assert.Len(t, *requests, 1)
+}
+
+func TestGetMe(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionUsersServer()
+	defer ts.Close()
+
+	// Act
+	me, err := api.GetMe(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "bot", me.Type)
+	assert.Equal(t, "Random Notion", me.Name)
+}
+
+func TestResolveUsers(t *testing.T) {
+	// Arrange
+	ts, api, _ := mockNotionUsersServer()
+	defer ts.Close()
+	author := &User{Object: "user", Id: mockUserId}
+	guest := User{Object: "user", Id: "1d4e9b2c-7f3a-4e8b-a5c6-2b9d0e1f3a4c"}
+	people := []User{{Object: "user", Id: mockUserId}, guest}
+	page := Page{
+		Id:           mockPageId,
+		CreatedBy:    author,
+		LastEditedBy: author,
+		Properties: map[string]PropertyValue{
+			"Reviewers": {Type: PROPERTY_PEOPLE, People: people},
+		},
+	}
+
+	// Act
+	err := api.ResolveUsers(context.Background(), &page)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrObjectNotFound) // The guest can't be retrieved
+	assert.Equal(t, "Ada Lovelace", page.CreatedBy.Name)
+	assert.Equal(t, "Ada Lovelace", page.LastEditedBy.Name)
+	assert.Equal(t, "Ada Lovelace", page.Properties["Reviewers"].People[0].Name)
+	assert.Equal(t, "", author.Name) // Shared users aren't modified
+	assert.Equal(t, "", people[0].Name)
+}
+
+func TestFindUsersByName(t *testing.T) {
+	// Arrange
+	ts, api, requests := mockNotionUsersServer()
+	defer ts.Close()
+
+	// Act
+	found, err := api.FindUsers(context.Background(), "grace hopper")
+	require.NoError(t, err)
+	again, err := api.FindUsers(context.Background(), "Grace Hopper")
+	require.NoError(t, err)
+	missing, err := api.FindUsers(context.Background(), "Alan Turing")
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, found, 1)
+	assert.Equal(t, mockUserId2, found[0].Id)
+	assert.Equal(t, found, again)
+	assert.Empty(t, missing)
+	assert.Len(t, *requests, 4) // Listed once for the first name and again for the missing one
+}
@@ -0,0 +1,131 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockNotionServerWithDataSources(t *testing.T) (*httptest.Server, *ApiConfig, *[]string) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, VERSION_2025_09_03, r.Header.Get("Notion-Version"))
+		paths = append(paths, r.Method+" "+r.URL.Path)
+
+		switch r.URL.Path {
+		case "/databases/" + mockDatabaseId:
+			w.Write([]byte(`{
+				"object": "database",
+				"id": "99999999-abcd-efgh-1234-000000000000",
+				"data_sources": [
+					{"id": "ds-articles", "name": "Articles"},
+					{"id": "ds-notes", "name": "Notes"}
+				]
+			}`))
+		case "/data_sources/ds-articles/query":
+			w.Write([]byte(`{
+				"object": "list",
+				"results": [{
+					"object": "page",
+					"id": "3350ba04-48b1-43e3-8726-1b1e9828b2b3",
+					"parent": {"type": "data_source_id", "data_source_id": "ds-articles", "database_id": "99999999-abcd-efgh-1234-000000000000"},
+					"url": "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3"
+				}],
+				"next_cursor": null,
+				"has_more": false
+			}`))
+		case "/data_sources/ds-notes/query":
+			w.Write([]byte(`{
+				"object": "list",
+				"results": [{
+					"object": "page",
+					"id": "5331da24-6597-4f2d-a684-fd94a0f3278a",
+					"parent": {"type": "data_source_id", "data_source_id": "ds-notes", "database_id": "99999999-abcd-efgh-1234-000000000000"},
+					"url": "https://www.notion.so/Chicken-korma-recipe-How-to-make-chicken-korma-Swasthi-s-Recipes-5331da2465974f2da684fd94a0f3278a"
+				}],
+				"next_cursor": null,
+				"has_more": false
+			}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"object": "error", "status": 400, "code": "invalid_request_url", "message": "Invalid request URL."}`))
+		}
+	}))
+
+	api := &ApiConfig{
+		Url:         server.URL,
+		DatabaseId:  mockDatabaseId,
+		SecretToken: mockApiToken,
+		Version:     VERSION_2025_09_03,
+	}
+
+	return server, api, &paths
+}
+
+func TestQueryEachDataSource(t *testing.T) {
+	ts, api, paths := mockNotionServerWithDataSources(t)
+	defer ts.Close()
+
+	pages, err := api.GetPagesWithContext(context.Background())
+
+	require.NoError(t, err)
+	if assert.Len(t, pages, 2) {
+		assert.Equal(t, "3350ba04-48b1-43e3-8726-1b1e9828b2b3", pages[0].Id)
+		assert.Equal(t, "5331da24-6597-4f2d-a684-fd94a0f3278a", pages[1].Id)
+	}
+	assert.Equal(t, []string{
+		"GET /databases/" + mockDatabaseId,
+		"POST /data_sources/ds-articles/query",
+		"POST /data_sources/ds-notes/query",
+	}, *paths)
+}
+
+func TestQueryConfiguredDataSources(t *testing.T) {
+	ts, api, paths := mockNotionServerWithDataSources(t)
+	defer ts.Close()
+	api.DataSourceIds = []string{"ds-notes"}
+
+	pages, err := api.GetPagesWithContext(context.Background())
+
+	require.NoError(t, err)
+	if assert.Len(t, pages, 1) {
+		assert.Equal(t, "5331da24-6597-4f2d-a684-fd94a0f3278a", pages[0].Id)
+	}
+	assert.Equal(t, []string{"POST /data_sources/ds-notes/query"}, *paths)
+}
+
+func TestDataSourcesRetrievedOncePerDatabase(t *testing.T) {
+	ts, api, paths := mockNotionServerWithDataSources(t)
+	defer ts.Close()
+	api.DataSources = NewDataSourceCache()
+
+	_, firstErr := api.GetPagesWithContext(context.Background())
+	_, secondErr := api.WithDatabase(mockDatabaseId).GetPagesWithContext(context.Background())
+
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	assert.Equal(t, []string{
+		"GET /databases/" + mockDatabaseId,
+		"POST /data_sources/ds-articles/query",
+		"POST /data_sources/ds-notes/query",
+		"POST /data_sources/ds-articles/query",
+		"POST /data_sources/ds-notes/query",
+	}, *paths)
+	ids, ok := api.DataSources.Get(mockDatabaseId)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"ds-articles", "ds-notes"}, ids)
+}
+
+func TestDefaultVersionQueriesDatabase(t *testing.T) {
+	api := &ApiConfig{DatabaseId: mockDatabaseId}
+
+	paths, err := api.queryPaths(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/databases/" + mockDatabaseId + "/query"}, paths)
+	assert.Equal(t, DEFAULT_NOTION_VERSION, api.version())
+}
@@ -0,0 +1,587 @@
+package notiontest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Property types Notion computes, which can be seeded with AddPage but not set through the API
+var computedTypes = []string{
+	notion.PROPERTY_FORMULA,
+	notion.PROPERTY_ROLLUP,
+	notion.PROPERTY_CREATED_TIME,
+	notion.PROPERTY_CREATED_BY,
+	notion.PROPERTY_LAST_EDITED_TIME,
+	notion.PROPERTY_LAST_EDITED_BY,
+	notion.PROPERTY_UNIQUE_ID,
+}
+
+type pageObject struct {
+	Object string `json:"object"`
+	notion.Page
+	Parent notion.PageParent `json:"parent"`
+}
+
+type databaseObject struct {
+	Object string `json:"object"`
+	notion.Database
+}
+
+type dataSourceObject struct {
+	Object string `json:"object"`
+	notion.DataSource
+}
+
+type listObject struct {
+	Object     string      `json:"object"`
+	Results    interface{} `json:"results"`
+	NextCursor *string     `json:"next_cursor"`
+	HasMore    bool        `json:"has_more"`
+}
+
+type pageCreateRequest struct {
+	Parent     notion.PageParent               `json:"parent"`
+	Properties map[string]notion.PropertyValue `json:"properties"`
+	Children   []notion.Block                  `json:"children"`
+}
+
+type pageUpdateRequest struct {
+	Properties map[string]notion.PropertyValue `json:"properties"`
+	Archived   *bool                           `json:"archived"`
+	InTrash    *bool                           `json:"in_trash"`
+}
+
+func (s *Server) getDatabase(version string, id string) (interface{}, *notion.APIError) {
+	db, ok := s.databases[normalizeId(id)]
+	if !ok {
+		return nil, notFound("database", id)
+	}
+	response := databaseObject{Object: "database", Database: db.Database}
+	if usesDataSources(version) {
+		response.Properties = nil
+	} else {
+		response.DataSources = nil
+	}
+	return response, nil
+}
+
+func (s *Server) getDataSource(id string) (interface{}, *notion.APIError) {
+	db, ok := s.dataSources[normalizeId(id)]
+	if !ok {
+		return nil, notFound("data_source", id)
+	}
+	return dataSourceObject{Object: "data_source", DataSource: notion.DataSource{
+		Id:         db.dataSourceId,
+		Title:      db.Title,
+		Properties: db.Properties,
+	}}, nil
+}
+
+func (s *Server) getPage(version string, id string) (interface{}, *notion.APIError) {
+	p, ok := s.pages[normalizeId(id)]
+	if !ok {
+		return nil, notFound("page", id)
+	}
+	return s.pageObject(p, version), nil
+}
+
+func (s *Server) createPage(r *http.Request, version string) (interface{}, *notion.APIError) {
+	var request pageCreateRequest
+	if apiErr := decode(r, &request); apiErr != nil {
+		return nil, apiErr
+	}
+
+	var db *database
+	switch request.Parent.Type {
+	case "database_id":
+		db = s.databases[normalizeId(request.Parent.DatabaseId)]
+		if db == nil {
+			return nil, notFound("database", request.Parent.DatabaseId)
+		}
+	case "data_source_id":
+		db = s.dataSources[normalizeId(request.Parent.DataSourceId)]
+		if db == nil {
+			return nil, notFound("data_source", request.Parent.DataSourceId)
+		}
+	default:
+		return nil, validationError("body.parent should be a database or data source, instead was %q.", request.Parent.Type)
+	}
+	if len(request.Children) > notion.MAX_NEW_PAGE_CHILDREN {
+		return nil, validationError("body.children.length should be ≤ `%d`, instead was `%d`.",
+			notion.MAX_NEW_PAGE_CHILDREN, len(request.Children))
+	}
+	for name, value := range request.Properties {
+		if apiErr := checkWritable(db, name, value); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	p, apiErr := s.addPage(db, notion.Page{Properties: request.Properties})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	s.addBlocks(p.Id, request.Children)
+	return s.pageObject(p, version), nil
+}
+
+func (s *Server) updatePage(r *http.Request, version string, id string) (interface{}, *notion.APIError) {
+	p, ok := s.pages[normalizeId(id)]
+	if !ok {
+		return nil, notFound("page", id)
+	}
+	var request pageUpdateRequest
+	if apiErr := decode(r, &request); apiErr != nil {
+		return nil, apiErr
+	}
+
+	db := s.databases[normalizeId(p.databaseId)]
+	updated := map[string]notion.PropertyValue{}
+	for key, value := range request.Properties {
+		if apiErr := checkWritable(db, key, value); apiErr != nil {
+			return nil, apiErr
+		}
+		schema, _ := db.property(key)
+		updated[schema.Name] = propertyValue(schema, value)
+	}
+	for name, value := range updated {
+		p.Properties[name] = value
+	}
+	if request.Archived != nil {
+		p.Archived = *request.Archived
+	}
+	if request.InTrash != nil {
+		p.InTrash = *request.InTrash
+	}
+	p.LastEditedTime = s.now()
+	setTimestamps(db, &p.Page)
+	return s.pageObject(p, version), nil
+}
+
+// Store a new page in db. Properties missing from p are added empty, as Notion returns every property
+func (s *Server) addPage(db *database, p notion.Page) (*page, *notion.APIError) {
+	properties := map[string]notion.PropertyValue{}
+	for key, value := range p.Properties {
+		schema, ok := db.property(key)
+		if !ok {
+			return nil, validationError("%s is not a property that exists.", key)
+		}
+		if t := valueType(value); t != "" && t != schema.Type {
+			return nil, validationError("%s is expected to be %s.", schema.Name, schema.Type)
+		}
+		properties[schema.Name] = propertyValue(schema, value)
+	}
+	for name, schema := range db.Properties {
+		if _, ok := properties[name]; !ok {
+			properties[name] = notion.PropertyValue{Id: schema.Id, Type: schema.Type}
+		}
+	}
+
+	if p.Id == "" {
+		p.Id = s.newId()
+	}
+	if p.CreatedTime == "" {
+		p.CreatedTime = s.now()
+	}
+	if p.LastEditedTime == "" {
+		p.LastEditedTime = p.CreatedTime
+	}
+	if p.Url == "" {
+		p.Url = "https://www.notion.so/" + normalizeId(p.Id)
+	}
+	p.Properties = properties
+	p.SourceDatabaseId = ""
+	setTimestamps(db, &p)
+
+	stored := &page{Page: p, databaseId: db.Id}
+	s.pages[normalizeId(p.Id)] = stored
+	s.pageOrder = append(s.pageOrder, normalizeId(p.Id))
+	return stored, nil
+}
+
+func (s *Server) pageObject(p *page, version string) pageObject {
+	parent := notion.PageParent{Type: "database_id", DatabaseId: p.databaseId}
+	if usesDataSources(version) {
+		parent.Type = "data_source_id"
+		parent.DataSourceId = s.databases[normalizeId(p.databaseId)].dataSourceId
+	}
+	page := p.Page
+	page.Properties = make(map[string]notion.PropertyValue, len(p.Properties))
+	for name, value := range p.Properties {
+		page.Properties[name] = truncated(value)
+	}
+	return pageObject{Object: "page", Page: page, Parent: parent}
+}
+
+// Return value with lists of more than notion.MAX_PROPERTY_ITEMS items cut short, as in Notion's page objects
+func truncated(value notion.PropertyValue) notion.PropertyValue {
+	const max = notion.MAX_PROPERTY_ITEMS
+	if len(value.Title) > max {
+		value.Title = value.Title[:max]
+	}
+	if len(value.RichText) > max {
+		value.RichText = value.RichText[:max]
+	}
+	if len(value.People) > max {
+		value.People = value.People[:max]
+	}
+	if len(value.Relation) > max {
+		value.Relation = value.Relation[:max]
+		value.HasMore = true
+	}
+	if value.Rollup != nil && len(value.Rollup.Array) > max {
+		rollup := *value.Rollup
+		rollup.Array = rollup.Array[:max]
+		value.Rollup = &rollup
+	}
+	return value
+}
+
+// Return the schema of a property of the database by name or ID. "title" always names the title property
+func (db *database) property(key string) (notion.PropertySchema, bool) {
+	if schema, ok := db.Properties[key]; ok {
+		return schema, true
+	}
+	for _, schema := range db.Properties {
+		if schema.Id == key {
+			return schema, true
+		}
+	}
+	return notion.PropertySchema{}, false
+}
+
+// Check that a property value sent to the API can be written to db
+func checkWritable(db *database, key string, value notion.PropertyValue) *notion.APIError {
+	schema, ok := db.property(key)
+	if !ok {
+		return validationError("%s is not a property that exists.", key)
+	}
+	if containsString(computedTypes, schema.Type) {
+		return validationError("%s is a %s property, which can't be set.", schema.Name, schema.Type)
+	}
+	if t := valueType(value); t != "" && t != schema.Type {
+		return validationError("%s is expected to be %s.", schema.Name, schema.Type)
+	}
+	return nil
+}
+
+// Return value as stored for the property, with its ID, type and plain text filled in
+func propertyValue(schema notion.PropertySchema, value notion.PropertyValue) notion.PropertyValue {
+	value.Id = schema.Id
+	value.Type = schema.Type
+	value.Title = withPlainText(value.Title)
+	value.RichText = withPlainText(value.RichText)
+	return value
+}
+
+func withPlainText(segments []notion.RichText) []notion.RichText {
+	if segments == nil {
+		return nil
+	}
+	filled := make([]notion.RichText, len(segments))
+	for i, segment := range segments {
+		if segment.PlainText == "" {
+			segment.PlainText = notion.PlainText([]notion.RichText{segment})
+		}
+		filled[i] = segment
+	}
+	return filled
+}
+
+// Set the created and last edited time properties of p from its timestamps
+func setTimestamps(db *database, p *notion.Page) {
+	for name, schema := range db.Properties {
+		switch schema.Type {
+		case notion.PROPERTY_CREATED_TIME:
+			created := p.CreatedTime
+			p.Properties[name] = notion.PropertyValue{Id: schema.Id, Type: schema.Type, CreatedTime: &created}
+		case notion.PROPERTY_LAST_EDITED_TIME:
+			edited := p.LastEditedTime
+			p.Properties[name] = notion.PropertyValue{Id: schema.Id, Type: schema.Type, LastEditedTime: &edited}
+		}
+	}
+}
+
+// Return the type of the field set in value, or an empty string if none is
+func valueType(value notion.PropertyValue) string {
+	switch {
+	case value.Title != nil:
+		return notion.PROPERTY_TITLE
+	case value.RichText != nil:
+		return notion.PROPERTY_RICH_TEXT
+	case value.Number != nil:
+		return notion.PROPERTY_NUMBER
+	case value.Select != nil:
+		return notion.PROPERTY_SELECT
+	case value.MultiSelect != nil:
+		return notion.PROPERTY_MULTI_SELECT
+	case value.Status != nil:
+		return notion.PROPERTY_STATUS
+	case value.Date != nil:
+		return notion.PROPERTY_DATE
+	case value.People != nil:
+		return notion.PROPERTY_PEOPLE
+	case value.Files != nil:
+		return notion.PROPERTY_FILES
+	case value.Checkbox != nil:
+		return notion.PROPERTY_CHECKBOX
+	case value.Url != nil:
+		return notion.PROPERTY_URL
+	case value.Email != nil:
+		return notion.PROPERTY_EMAIL
+	case value.PhoneNumber != nil:
+		return notion.PROPERTY_PHONE_NUMBER
+	case value.Formula != nil:
+		return notion.PROPERTY_FORMULA
+	case value.Relation != nil:
+		return notion.PROPERTY_RELATION
+	case value.Rollup != nil:
+		return notion.PROPERTY_ROLLUP
+	case value.CreatedTime != nil:
+		return notion.PROPERTY_CREATED_TIME
+	case value.CreatedBy != nil:
+		return notion.PROPERTY_CREATED_BY
+	case value.LastEditedTime != nil:
+		return notion.PROPERTY_LAST_EDITED_TIME
+	case value.LastEditedBy != nil:
+		return notion.PROPERTY_LAST_EDITED_BY
+	case value.UniqueId != nil:
+		return notion.PROPERTY_UNIQUE_ID
+	}
+	return ""
+}
+
+// ===============================================================
+// Blocks
+// ---------------------------------------------------------------
+
+func (s *Server) addBlocks(parentId string, blocks []notion.Block) []notion.Block {
+	added := make([]notion.Block, len(blocks))
+	for i, block := range blocks {
+		if block.Id == "" {
+			block.Id = s.newId()
+		}
+		block.Object = "block"
+		if block.CreatedTime == "" {
+			block.CreatedTime = s.now()
+		}
+		if block.LastEditedTime == "" {
+			block.LastEditedTime = block.CreatedTime
+		}
+		children := block.Children
+		block.Children = nil
+		s.blocks[normalizeId(block.Id)] = block
+		s.children[normalizeId(parentId)] = append(s.children[normalizeId(parentId)], normalizeId(block.Id))
+
+		block.Children = s.addBlocks(block.Id, children)
+		block.HasChildren = len(children) > 0
+		added[i] = block
+	}
+	return added
+}
+
+func (s *Server) getBlockChildren(r *http.Request, id string) (interface{}, *notion.APIError) {
+	_, isPage := s.pages[normalizeId(id)]
+	_, isBlock := s.blocks[normalizeId(id)]
+	if !isPage && !isBlock {
+		return nil, notFound("block", id)
+	}
+	size, apiErr := pageSize(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	ids, next, apiErr := paginate(s.children[normalizeId(id)], size, r.URL.Query().Get("start_cursor"))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	results := make([]notion.Block, len(ids))
+	for i, childId := range ids {
+		block := s.blocks[childId]
+		block.HasChildren = len(s.children[childId]) > 0
+		results[i] = block
+	}
+	return list(results, next), nil
+}
+
+// ===============================================================
+// Property items
+// ---------------------------------------------------------------
+
+type propertyItemObject struct {
+	Object   string            `json:"object"`
+	Id       string            `json:"id"`
+	Type     string            `json:"type"`
+	Title    *notion.RichText  `json:"title,omitempty"`
+	RichText *notion.RichText  `json:"rich_text,omitempty"`
+	People   *notion.User      `json:"people,omitempty"`
+	Relation *notion.ObjectRef `json:"relation,omitempty"`
+}
+
+type propertyValueObject struct {
+	Object string `json:"object"`
+	notion.PropertyValue
+}
+
+type propertyItemListObject struct {
+	listObject
+	Type         string           `json:"type"`
+	PropertyItem propertyListItem `json:"property_item"`
+}
+
+type propertyListItem struct {
+	Id      string              `json:"id"`
+	Type    string              `json:"type"`
+	NextUrl *string             `json:"next_url"`
+	Rollup  *notion.RollupValue `json:"rollup,omitempty"`
+}
+
+func (s *Server) getPageProperty(r *http.Request, pageId string, propertyId string) (interface{}, *notion.APIError) {
+	p, ok := s.pages[normalizeId(pageId)]
+	if !ok {
+		return nil, notFound("page", pageId)
+	}
+	var value *notion.PropertyValue
+	for _, property := range p.Properties {
+		if property.Id == propertyId {
+			property := property
+			value = &property
+		}
+	}
+	if value == nil {
+		return nil, notFound("property", propertyId)
+	}
+
+	items := propertyItems(*value)
+	if items == nil {
+		return propertyValueObject{Object: "property_item", PropertyValue: *value}, nil
+	}
+	size, apiErr := pageSize(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	ids := make([]string, len(items))
+	for i := range items {
+		ids[i] = strconv.Itoa(i)
+	}
+	ids, next, apiErr := paginate(ids, size, r.URL.Query().Get("start_cursor"))
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	results := make([]interface{}, len(ids))
+	for i, id := range ids {
+		index, _ := strconv.Atoi(id)
+		results[i] = items[index]
+	}
+
+	item := propertyListItem{Id: value.Id, Type: value.Type}
+	if value.Rollup != nil {
+		rollup := *value.Rollup
+		rollup.Array = nil
+		item.Rollup = &rollup
+	}
+	return propertyItemListObject{listObject: list(results, next), Type: "property_item", PropertyItem: item}, nil
+}
+
+// Return the items Notion lists a property value as, one per segment, user or reference,
+// or nil if the property isn't paginated
+func propertyItems(value notion.PropertyValue) []interface{} {
+	items := []interface{}{}
+	item := func() propertyItemObject {
+		return propertyItemObject{Object: "property_item", Id: value.Id, Type: value.Type}
+	}
+	switch value.Type {
+	case notion.PROPERTY_TITLE:
+		for i := range value.Title {
+			listed := item()
+			listed.Title = &value.Title[i]
+			items = append(items, listed)
+		}
+	case notion.PROPERTY_RICH_TEXT:
+		for i := range value.RichText {
+			listed := item()
+			listed.RichText = &value.RichText[i]
+			items = append(items, listed)
+		}
+	case notion.PROPERTY_PEOPLE:
+		for i := range value.People {
+			listed := item()
+			listed.People = &value.People[i]
+			items = append(items, listed)
+		}
+	case notion.PROPERTY_RELATION:
+		for i := range value.Relation {
+			listed := item()
+			listed.Relation = &value.Relation[i]
+			items = append(items, listed)
+		}
+	case notion.PROPERTY_ROLLUP:
+		if value.Rollup == nil {
+			break
+		}
+		for _, element := range value.Rollup.Array {
+			if listed := propertyItems(element); listed != nil && element.Type != notion.PROPERTY_ROLLUP {
+				items = append(items, listed...)
+			} else {
+				items = append(items, propertyValueObject{Object: "property_item", PropertyValue: element})
+			}
+		}
+	default:
+		return nil
+	}
+	return items
+}
+
+// ===============================================================
+
+func pageSize(r *http.Request) (int, *notion.APIError) {
+	value := r.URL.Query().Get("page_size")
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, validationError("page_size should be a number, instead was `%s`.", value)
+	}
+	return size, nil
+}
+
+// Return the IDs of one page of results starting at cursor, which is the ID of the first result,
+// and the cursor of the next page if there is one
+func paginate(ids []string, pageSize int, cursor string) ([]string, string, *notion.APIError) {
+	if pageSize == 0 {
+		pageSize = int(notion.DEFAULT_PAGE_SIZE)
+	}
+	if pageSize < 1 || pageSize > int(notion.DEFAULT_PAGE_SIZE) {
+		return nil, "", validationError("body.page_size should be ≤ `%d`, instead was `%d`.", notion.DEFAULT_PAGE_SIZE, pageSize)
+	}
+
+	start := 0
+	if cursor != "" {
+		start = -1
+		for i, id := range ids {
+			if id == normalizeId(cursor) {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", validationError("start_cursor provided is invalid: %s", cursor)
+		}
+	}
+	end := start + pageSize
+	if end >= len(ids) {
+		return ids[start:], "", nil
+	}
+	return ids[start:end], ids[end], nil
+}
+
+func list(results interface{}, next string) listObject {
+	response := listObject{Object: "list", Results: results}
+	if next != "" {
+		response.NextCursor = &next
+		response.HasMore = true
+	}
+	return response
+}
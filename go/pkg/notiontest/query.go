@@ -0,0 +1,589 @@
+package notiontest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+type queryRequest struct {
+	Filter      *notion.Filter `json:"filter"`
+	Sorts       []notion.Sort  `json:"sorts"`
+	PageSize    int            `json:"page_size"`
+	StartCursor string         `json:"start_cursor"`
+}
+
+// Return the pages of db matching the query, in the order they were added unless sorted.
+// Archived and trashed pages are left out, as Notion does
+func (s *Server) query(r *http.Request, version string, db *database, kind string, id string) (interface{}, *notion.APIError) {
+	if db == nil {
+		return nil, notFound(kind, id)
+	}
+	var request queryRequest
+	if apiErr := decode(r, &request); apiErr != nil {
+		return nil, apiErr
+	}
+
+	pages := []*page{}
+	for _, pageId := range s.pageOrder {
+		p := s.pages[pageId]
+		if p.databaseId != db.Id || p.Removed() {
+			continue
+		}
+		if request.Filter != nil {
+			matched, apiErr := s.matches(db, p, *request.Filter)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			if !matched {
+				continue
+			}
+		}
+		pages = append(pages, p)
+	}
+	if apiErr := sortPages(db, pages, request.Sorts); apiErr != nil {
+		return nil, apiErr
+	}
+
+	ids := make([]string, len(pages))
+	for i, p := range pages {
+		ids[i] = normalizeId(p.Id)
+	}
+	ids, next, apiErr := paginate(ids, request.PageSize, request.StartCursor)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	results := make([]pageObject, len(ids))
+	for i, pageId := range ids {
+		results[i] = s.pageObject(s.pages[pageId], version)
+	}
+	return list(results, next), nil
+}
+
+// ===============================================================
+// Filters
+// ---------------------------------------------------------------
+
+func (s *Server) matches(db *database, p *page, f notion.Filter) (bool, *notion.APIError) {
+	switch {
+	case f.And != nil:
+		for _, child := range f.And {
+			matched, apiErr := s.matches(db, p, child)
+			if apiErr != nil || !matched {
+				return false, apiErr
+			}
+		}
+		return true, nil
+	case f.Or != nil:
+		for _, child := range f.Or {
+			matched, apiErr := s.matches(db, p, child)
+			if apiErr != nil || matched {
+				return matched, apiErr
+			}
+		}
+		return false, nil
+	case f.Timestamp == notion.PROPERTY_CREATED_TIME:
+		return s.conditionMatches(notion.PropertyValue{Type: f.Timestamp, CreatedTime: &p.CreatedTime}, f)
+	case f.Timestamp == notion.PROPERTY_LAST_EDITED_TIME:
+		return s.conditionMatches(notion.PropertyValue{Type: f.Timestamp, LastEditedTime: &p.LastEditedTime}, f)
+	case f.Timestamp != "":
+		return false, validationError("body.filter.timestamp should be created_time or last_edited_time, instead was %q.", f.Timestamp)
+	}
+
+	schema, ok := db.property(f.Property)
+	if !ok {
+		return false, validationError("Could not find property with name or id: %s", f.Property)
+	}
+	return s.conditionMatches(p.Properties[schema.Name], f)
+}
+
+// Return whether value satisfies the condition of f. As in Notion,
+// the condition must be for the value's type
+func (s *Server) conditionMatches(value notion.PropertyValue, f notion.Filter) (bool, *notion.APIError) {
+	condition, types := filterCondition(f)
+	if condition == "" {
+		return false, validationError("body.filter should define a condition.")
+	}
+	if !containsString(types, value.Type) {
+		return false, validationError("database property %s does not match filter %s", value.Type, condition)
+	}
+
+	switch {
+	case f.Title != nil:
+		return textMatches(*f.Title, notion.PlainText(value.Title))
+	case f.RichText != nil:
+		return textMatches(*f.RichText, notion.PlainText(value.RichText))
+	case f.Url != nil:
+		return textMatches(*f.Url, value.PlainText())
+	case f.Email != nil:
+		return textMatches(*f.Email, value.PlainText())
+	case f.PhoneNumber != nil:
+		return textMatches(*f.PhoneNumber, value.PlainText())
+	case f.Number != nil:
+		return numberMatches(*f.Number, value.Number)
+	case f.UniqueId != nil:
+		var number *float64
+		if value.UniqueId != nil {
+			n := float64(value.UniqueId.Number)
+			number = &n
+		}
+		return numberMatches(*f.UniqueId, number)
+	case f.Checkbox != nil:
+		return checkboxMatches(*f.Checkbox, value.Checkbox != nil && *value.Checkbox)
+	case f.Select != nil:
+		return selectMatches(*f.Select, value.Select)
+	case f.Status != nil:
+		return selectMatches(*f.Status, value.Status)
+	case f.MultiSelect != nil:
+		names, _ := value.Value().([]string)
+		return listMatches(notion.ContainsCondition(*f.MultiSelect), names)
+	case f.Date != nil:
+		return s.dateMatches(*f.Date, value.Date)
+	case f.CreatedTime != nil:
+		return s.dateMatches(*f.CreatedTime, timestampDate(value.CreatedTime))
+	case f.LastEditedTime != nil:
+		return s.dateMatches(*f.LastEditedTime, timestampDate(value.LastEditedTime))
+	case f.People != nil:
+		return listMatches(*f.People, userIds(value))
+	case f.CreatedBy != nil:
+		return listMatches(*f.CreatedBy, userIds(value))
+	case f.LastEditedBy != nil:
+		return listMatches(*f.LastEditedBy, userIds(value))
+	case f.Relation != nil:
+		ids := []string{}
+		for _, ref := range value.Relation {
+			ids = append(ids, ref.Id)
+		}
+		return listMatches(*f.Relation, ids)
+	case f.Files != nil:
+		return emptyMatches(*f.Files, len(value.Files) == 0)
+	case f.Formula != nil:
+		return s.formulaMatches(*f.Formula, value.Formula)
+	case f.Rollup != nil:
+		return s.rollupMatches(*f.Rollup, value.Rollup)
+	}
+	return false, nil
+}
+
+// Return the name of the condition set in f and the property types it applies to
+func filterCondition(f notion.Filter) (string, []string) {
+	people := []string{notion.PROPERTY_PEOPLE, notion.PROPERTY_CREATED_BY, notion.PROPERTY_LAST_EDITED_BY}
+	switch {
+	case f.Title != nil:
+		return "title", []string{notion.PROPERTY_TITLE}
+	case f.RichText != nil:
+		return "rich_text", []string{notion.PROPERTY_RICH_TEXT}
+	case f.Url != nil:
+		return "url", []string{notion.PROPERTY_URL}
+	case f.Email != nil:
+		return "email", []string{notion.PROPERTY_EMAIL}
+	case f.PhoneNumber != nil:
+		return "phone_number", []string{notion.PROPERTY_PHONE_NUMBER}
+	case f.Number != nil:
+		return "number", []string{notion.PROPERTY_NUMBER}
+	case f.UniqueId != nil:
+		return "unique_id", []string{notion.PROPERTY_UNIQUE_ID}
+	case f.Checkbox != nil:
+		return "checkbox", []string{notion.PROPERTY_CHECKBOX}
+	case f.Select != nil:
+		return "select", []string{notion.PROPERTY_SELECT}
+	case f.Status != nil:
+		return "status", []string{notion.PROPERTY_STATUS}
+	case f.MultiSelect != nil:
+		return "multi_select", []string{notion.PROPERTY_MULTI_SELECT}
+	case f.Date != nil:
+		return "date", []string{notion.PROPERTY_DATE}
+	case f.CreatedTime != nil:
+		return "created_time", []string{notion.PROPERTY_CREATED_TIME}
+	case f.LastEditedTime != nil:
+		return "last_edited_time", []string{notion.PROPERTY_LAST_EDITED_TIME}
+	case f.People != nil:
+		return "people", people
+	case f.CreatedBy != nil:
+		return "created_by", people
+	case f.LastEditedBy != nil:
+		return "last_edited_by", people
+	case f.Relation != nil:
+		return "relation", []string{notion.PROPERTY_RELATION}
+	case f.Files != nil:
+		return "files", []string{notion.PROPERTY_FILES}
+	case f.Formula != nil:
+		return "formula", []string{notion.PROPERTY_FORMULA}
+	case f.Rollup != nil:
+		return "rollup", []string{notion.PROPERTY_ROLLUP}
+	}
+	return "", nil
+}
+
+func textMatches(c notion.TextCondition, text string) (bool, *notion.APIError) {
+	switch {
+	case c.Equals != "":
+		return text == c.Equals, nil
+	case c.DoesNotEqual != "":
+		return text != c.DoesNotEqual, nil
+	case c.Contains != "":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(c.Contains)), nil
+	case c.DoesNotContain != "":
+		return !strings.Contains(strings.ToLower(text), strings.ToLower(c.DoesNotContain)), nil
+	case c.StartsWith != "":
+		return strings.HasPrefix(text, c.StartsWith), nil
+	case c.EndsWith != "":
+		return strings.HasSuffix(text, c.EndsWith), nil
+	}
+	return emptyMatches(notion.EmptyCondition{IsEmpty: c.IsEmpty, IsNotEmpty: c.IsNotEmpty}, text == "")
+}
+
+func numberMatches(c notion.NumberCondition, number *float64) (bool, *notion.APIError) {
+	if c.IsEmpty || c.IsNotEmpty {
+		return emptyMatches(notion.EmptyCondition{IsEmpty: c.IsEmpty, IsNotEmpty: c.IsNotEmpty}, number == nil)
+	}
+	if number == nil {
+		return false, nil
+	}
+	n := *number
+	switch {
+	case c.Equals != nil:
+		return n == *c.Equals, nil
+	case c.DoesNotEqual != nil:
+		return n != *c.DoesNotEqual, nil
+	case c.GreaterThan != nil:
+		return n > *c.GreaterThan, nil
+	case c.LessThan != nil:
+		return n < *c.LessThan, nil
+	case c.GreaterThanOrEqualTo != nil:
+		return n >= *c.GreaterThanOrEqualTo, nil
+	case c.LessThanOrEqualTo != nil:
+		return n <= *c.LessThanOrEqualTo, nil
+	}
+	return false, validationError("body.filter.number should define a condition.")
+}
+
+func checkboxMatches(c notion.CheckboxCondition, checked bool) (bool, *notion.APIError) {
+	switch {
+	case c.Equals != nil:
+		return checked == *c.Equals, nil
+	case c.DoesNotEqual != nil:
+		return checked != *c.DoesNotEqual, nil
+	}
+	return false, validationError("body.filter.checkbox should define a condition.")
+}
+
+func selectMatches(c notion.SelectCondition, option *notion.SelectOption) (bool, *notion.APIError) {
+	name := ""
+	if option != nil {
+		name = option.Name
+	}
+	switch {
+	case c.Equals != "":
+		return name == c.Equals, nil
+	case c.DoesNotEqual != "":
+		return name != c.DoesNotEqual, nil
+	}
+	return emptyMatches(notion.EmptyCondition{IsEmpty: c.IsEmpty, IsNotEmpty: c.IsNotEmpty}, name == "")
+}
+
+// Match multi-select option names, or the IDs of people and related pages
+func listMatches(c notion.ContainsCondition, values []string) (bool, *notion.APIError) {
+	switch {
+	case c.Contains != "":
+		return containsValue(values, c.Contains), nil
+	case c.DoesNotContain != "":
+		return !containsValue(values, c.DoesNotContain), nil
+	}
+	return emptyMatches(notion.EmptyCondition{IsEmpty: c.IsEmpty, IsNotEmpty: c.IsNotEmpty}, len(values) == 0)
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || normalizeId(v) == normalizeId(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func emptyMatches(c notion.EmptyCondition, empty bool) (bool, *notion.APIError) {
+	switch {
+	case c.IsEmpty:
+		return empty, nil
+	case c.IsNotEmpty:
+		return !empty, nil
+	}
+	return false, validationError("body.filter should define a condition.")
+}
+
+func (s *Server) dateMatches(c notion.DateCondition, date *notion.DateValue) (bool, *notion.APIError) {
+	if c.IsEmpty || c.IsNotEmpty {
+		return emptyMatches(notion.EmptyCondition{IsEmpty: c.IsEmpty, IsNotEmpty: c.IsNotEmpty}, date == nil)
+	}
+	if date == nil {
+		return false, nil
+	}
+	value, ok := parseDate(date.Start)
+	if !ok {
+		return false, nil
+	}
+
+	compare := func(condition string) (int, *notion.APIError) {
+		t, ok := parseDate(condition)
+		if !ok {
+			return 0, validationError("body.filter.date should be a valid ISO 8601 date string, instead was %q.", condition)
+		}
+		// A date without a time matches the whole day
+		if len(condition) == len("2006-01-02") {
+			value = time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		switch {
+		case value.Before(t):
+			return -1, nil
+		case value.After(t):
+			return 1, nil
+		}
+		return 0, nil
+	}
+	now := s.opts.Now()
+	within := func(from time.Time, to time.Time) (bool, *notion.APIError) {
+		return !value.Before(from) && !value.After(to), nil
+	}
+
+	var order int
+	var apiErr *notion.APIError
+	switch {
+	case c.Equals != "":
+		order, apiErr = compare(c.Equals)
+		return order == 0, apiErr
+	case c.Before != "":
+		order, apiErr = compare(c.Before)
+		return order < 0, apiErr
+	case c.After != "":
+		order, apiErr = compare(c.After)
+		return order > 0, apiErr
+	case c.OnOrBefore != "":
+		order, apiErr = compare(c.OnOrBefore)
+		return order <= 0, apiErr
+	case c.OnOrAfter != "":
+		order, apiErr = compare(c.OnOrAfter)
+		return order >= 0, apiErr
+	case c.PastWeek != nil:
+		return within(now.AddDate(0, 0, -7), now)
+	case c.PastMonth != nil:
+		return within(now.AddDate(0, -1, 0), now)
+	case c.PastYear != nil:
+		return within(now.AddDate(-1, 0, 0), now)
+	case c.NextWeek != nil:
+		return within(now, now.AddDate(0, 0, 7))
+	case c.NextMonth != nil:
+		return within(now, now.AddDate(0, 1, 0))
+	case c.NextYear != nil:
+		return within(now, now.AddDate(1, 0, 0))
+	case c.ThisWeek != nil:
+		year, week := now.ISOWeek()
+		valueYear, valueWeek := value.ISOWeek()
+		return year == valueYear && week == valueWeek, nil
+	}
+	return false, validationError("body.filter.date should define a condition.")
+}
+
+func (s *Server) formulaMatches(c notion.FormulaCondition, formula *notion.FormulaValue) (bool, *notion.APIError) {
+	if formula == nil {
+		formula = &notion.FormulaValue{}
+	}
+	switch {
+	case c.String != nil:
+		text := ""
+		if formula.String != nil {
+			text = *formula.String
+		}
+		return textMatches(*c.String, text)
+	case c.Number != nil:
+		return numberMatches(*c.Number, formula.Number)
+	case c.Checkbox != nil:
+		return checkboxMatches(*c.Checkbox, formula.Boolean != nil && *formula.Boolean)
+	case c.Date != nil:
+		return s.dateMatches(*c.Date, formula.Date)
+	}
+	return false, validationError("body.filter.formula should define a condition.")
+}
+
+func (s *Server) rollupMatches(c notion.RollupCondition, rollup *notion.RollupValue) (bool, *notion.APIError) {
+	if rollup == nil {
+		rollup = &notion.RollupValue{}
+	}
+	switch {
+	case c.Number != nil:
+		return numberMatches(*c.Number, rollup.Number)
+	case c.Date != nil:
+		return s.dateMatches(*c.Date, rollup.Date)
+	case c.Any != nil:
+		for _, item := range rollup.Array {
+			matched, apiErr := s.conditionMatches(item, *c.Any)
+			if apiErr != nil || matched {
+				return matched, apiErr
+			}
+		}
+		return false, nil
+	case c.Every != nil, c.None != nil:
+		every := c.Every != nil
+		filter := c.Every
+		if !every {
+			filter = c.None
+		}
+		for _, item := range rollup.Array {
+			matched, apiErr := s.conditionMatches(item, *filter)
+			if apiErr != nil {
+				return false, apiErr
+			}
+			if matched != every {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, validationError("body.filter.rollup should define a condition.")
+}
+
+func timestampDate(timestamp *string) *notion.DateValue {
+	if timestamp == nil || *timestamp == "" {
+		return nil
+	}
+	return &notion.DateValue{Start: *timestamp}
+}
+
+func userIds(value notion.PropertyValue) []string {
+	ids := []string{}
+	for _, user := range value.People {
+		ids = append(ids, user.Id)
+	}
+	for _, user := range []*notion.User{value.CreatedBy, value.LastEditedBy} {
+		if user != nil {
+			ids = append(ids, user.Id)
+		}
+	}
+	return ids
+}
+
+// Parse the date formats Notion returns and accepts in filters
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, notion.ISO_TIME, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ===============================================================
+// Sorts
+// ---------------------------------------------------------------
+
+// Sort pages in place by each of sorts in turn. Empty values come last whichever the direction
+func sortPages(db *database, pages []*page, sorts []notion.Sort) *notion.APIError {
+	keys := make([]func(p *page) interface{}, len(sorts))
+	for i, by := range sorts {
+		switch {
+		case by.Timestamp == notion.PROPERTY_CREATED_TIME:
+			keys[i] = func(p *page) interface{} { return p.CreatedTime }
+		case by.Timestamp == notion.PROPERTY_LAST_EDITED_TIME:
+			keys[i] = func(p *page) interface{} { return p.LastEditedTime }
+		case by.Timestamp != "":
+			return validationError("body.sorts[%d].timestamp should be created_time or last_edited_time, instead was %q.", i, by.Timestamp)
+		default:
+			schema, ok := db.property(by.Property)
+			if !ok {
+				return validationError("Could not find sort property with name or id: %s", by.Property)
+			}
+			keys[i] = func(p *page) interface{} { return sortKey(p.Properties[schema.Name]) }
+		}
+		if by.Direction != notion.ASCENDING && by.Direction != notion.DESCENDING {
+			return validationError("body.sorts[%d].direction should be ascending or descending, instead was %q.", i, by.Direction)
+		}
+	}
+
+	sort.SliceStable(pages, func(a, b int) bool {
+		for i, by := range sorts {
+			order := compareValues(keys[i](pages[a]), keys[i](pages[b]))
+			if order == 0 {
+				continue
+			}
+			if isEmpty(keys[i](pages[a])) || isEmpty(keys[i](pages[b])) || by.Direction == notion.ASCENDING {
+				return order < 0
+			}
+			return order > 0
+		}
+		return false
+	})
+	return nil
+}
+
+// Compare two property values, putting empty values last
+func compareValues(a interface{}, b interface{}) int {
+	switch {
+	case isEmpty(a) && isEmpty(b):
+		return 0
+	case isEmpty(a):
+		return 1
+	case isEmpty(b):
+		return -1
+	}
+
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case bool:
+		if av == b.(bool) {
+			return 0
+		} else if !av {
+			return -1
+		}
+		return 1
+	case *notion.DateValue:
+		return compareValues(av.Start, b.(*notion.DateValue).Start)
+	case string:
+		if at, ok := parseDate(av); ok {
+			if bt, ok := parseDate(b.(string)); ok {
+				return compareTimes(at, bt)
+			}
+		}
+		return strings.Compare(strings.ToLower(av), strings.ToLower(b.(string)))
+	}
+	return 0
+}
+
+// Return the value of a property to sort by: a number, boolean, date or string
+func sortKey(value notion.PropertyValue) interface{} {
+	switch v := value.Value().(type) {
+	case nil, float64, bool, *notion.DateValue, string:
+		return v
+	}
+	return value.PlainText()
+}
+
+func compareTimes(a time.Time, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
@@ -0,0 +1,415 @@
+// Package notiontest provides a fake of the Notion API for tests and local demos.
+// It holds databases, pages and blocks in memory and serves the endpoints the notion package uses,
+// checking requests the way Notion does and failing them on demand
+package notiontest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Token the server accepts unless Options.Token is set
+const DEFAULT_TOKEN = "secret_notiontest"
+
+// Format of the timestamps Notion returns
+const TIME_FORMAT = "2006-01-02T15:04:05.000Z"
+
+type Options struct {
+	Token    string   // Bearer token requests must send; DEFAULT_TOKEN if empty
+	Versions []string // Notion-Version headers accepted; any if empty, though one must be sent
+	// Requests per second allowed on average before responding 429; unlimited if zero
+	RateLimit float64
+	RateBurst int           // Requests allowed at once under RateLimit; at least 1
+	Latency   time.Duration // Delay before each response
+	// Clock for the timestamps of pages and relative date filters; time.Now if nil
+	Now func() time.Time
+}
+
+// Fault makes matching requests fail with a Notion error instead of being served
+type Fault struct {
+	Method     string // Any method if empty
+	Path       string // Prefix of the path after /v1, e.g. "/pages"; any path if empty
+	Status     int
+	Code       string // One of the notion.CODE_* constants
+	Message    string
+	RetryAfter time.Duration // Sent as the Retry-After header if set
+	Times      int           // Matching requests to fail; every one if zero
+}
+
+// Server is a running fake of the Notion API, serving requests under URL + "/v1"
+type Server struct {
+	*httptest.Server
+	opts Options
+
+	mu          sync.Mutex
+	ids         int
+	requests    int
+	databases   map[string]*database // Keyed by normalized ID, as are the maps below
+	databaseIds []string             // In the order they were added
+	dataSources map[string]*database
+	pages       map[string]*page
+	pageOrder   []string
+	blocks      map[string]notion.Block
+	children    map[string][]string // IDs of the child blocks of each page or block, in order
+	faults      []*Fault
+	tokens      float64
+	lastRequest time.Time
+}
+
+type database struct {
+	notion.Database
+	dataSourceId string
+}
+
+type page struct {
+	notion.Page
+	databaseId string
+}
+
+// Start a server with no content, to be closed with Close
+func NewServer(opts Options) *Server {
+	if opts.Token == "" {
+		opts.Token = DEFAULT_TOKEN
+	}
+	if opts.RateBurst < 1 {
+		opts.RateBurst = 1
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	s := &Server{
+		opts:        opts,
+		databases:   map[string]*database{},
+		dataSources: map[string]*database{},
+		pages:       map[string]*page{},
+		blocks:      map[string]notion.Block{},
+		children:    map[string][]string{},
+		tokens:      float64(opts.RateBurst),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Return a config for the server's API, retrieving pages from the databases added so far.
+// Requests aren't rate limited by the client, so that the server's rate limit can be tested
+func (s *Server) Api() *notion.ApiConfig {
+	api := notion.NewApiConfig()
+	api.Url = s.URL + "/v1"
+	api.SecretToken = s.opts.Token
+	api.RateLimiter = nil
+	if len(s.opts.Versions) > 0 {
+		api.Version = s.opts.Versions[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.databaseIds) > 0 {
+		api.DatabaseId = s.databaseIds[0]
+		api.DatabaseIds = append([]string{}, s.databaseIds[1:]...)
+	}
+	return api
+}
+
+// Add a database, returning it with its ID, timestamps and data source filled in.
+// Properties are keyed by name; a "Name" title property is added if there's no title
+func (s *Server) AddDatabase(db notion.Database) notion.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db.Id == "" {
+		db.Id = s.newId()
+	}
+	now := s.now()
+	if db.CreatedTime == "" {
+		db.CreatedTime = now
+	}
+	if db.LastEditedTime == "" {
+		db.LastEditedTime = db.CreatedTime
+	}
+	if db.Url == "" {
+		db.Url = "https://www.notion.so/" + normalizeId(db.Id)
+	}
+
+	properties := map[string]notion.PropertySchema{}
+	hasTitle := false
+	for name, schema := range db.Properties {
+		schema.Name = name
+		if schema.Id == "" {
+			schema.Id = propertyId(name)
+		}
+		if schema.Type == notion.PROPERTY_TITLE {
+			schema.Id = "title"
+			hasTitle = true
+		}
+		properties[name] = schema
+	}
+	if !hasTitle {
+		properties["Name"] = notion.PropertySchema{Id: "title", Name: "Name", Type: notion.PROPERTY_TITLE}
+	}
+	db.Properties = properties
+
+	stored := &database{Database: db, dataSourceId: s.newId()}
+	if len(db.DataSources) > 0 {
+		stored.dataSourceId = db.DataSources[0].Id
+	}
+	stored.DataSources = []notion.DataSourceRef{{Id: stored.dataSourceId, Name: db.Name()}}
+	s.databases[normalizeId(db.Id)] = stored
+	s.databaseIds = append(s.databaseIds, db.Id)
+	s.dataSources[normalizeId(stored.dataSourceId)] = stored
+	return stored.Database
+}
+
+// Add a page to a database, returning it as the API would.
+// Its properties are matched to the database's by name, and "title" stands for the title property.
+// Panics if the database hasn't been added or a property doesn't fit its schema
+func (s *Server) AddPage(databaseId string, p notion.Page) notion.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, ok := s.databases[normalizeId(databaseId)]
+	if !ok {
+		panic(fmt.Sprintf("notiontest: no database %s", databaseId))
+	}
+	created, apiErr := s.addPage(db, p)
+	if apiErr != nil {
+		panic(fmt.Sprintf("notiontest: %s", apiErr.Message))
+	}
+	return created.Page
+}
+
+// Add blocks as the last children of a page or block, along with their own Children.
+// Returns the blocks with their IDs filled in
+func (s *Server) AddBlocks(parentId string, blocks ...notion.Block) []notion.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addBlocks(parentId, blocks)
+}
+
+// Return a page as currently stored, and whether it exists
+func (s *Server) Page(pageId string) (notion.Page, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pages[normalizeId(pageId)]
+	if !ok {
+		return notion.Page{}, false
+	}
+	return p.Page, true
+}
+
+// Fail requests matching fault until it has been applied fault.Times times.
+// Faults are checked in the order they were injected
+func (s *Server) Inject(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// Return the number of requests received, including those that failed
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// ===============================================================
+// Request handling
+// ---------------------------------------------------------------
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if wait := s.rateLimit(); wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		writeError(w, apiError(http.StatusTooManyRequests, notion.CODE_RATE_LIMITED, "You have been rate limited. Please try again later."))
+		return
+	}
+	if apiErr := s.checkHeaders(r); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	if fault := s.fault(r.Method, path); fault != nil {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(fault.RetryAfter.Seconds()))))
+		}
+		writeError(w, apiError(fault.Status, fault.Code, fault.Message))
+		return
+	}
+
+	result, apiErr := s.route(r, path)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) route(r *http.Request, path string) (interface{}, *notion.APIError) {
+	version := r.Header.Get("Notion-Version")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "databases":
+		return s.getDatabase(version, parts[1])
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "databases" && parts[2] == "query":
+		if usesDataSources(version) {
+			break
+		}
+		return s.query(r, version, s.databases[normalizeId(parts[1])], "database", parts[1])
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "data_sources":
+		return s.getDataSource(parts[1])
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "data_sources" && parts[2] == "query":
+		return s.query(r, version, s.dataSources[normalizeId(parts[1])], "data_source", parts[1])
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "pages":
+		return s.createPage(r, version)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "pages":
+		return s.getPage(version, parts[1])
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "pages" && parts[2] == "properties":
+		return s.getPageProperty(r, parts[1], parts[3])
+	case r.Method == http.MethodPatch && len(parts) == 2 && parts[0] == "pages":
+		return s.updatePage(r, version, parts[1])
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		return s.getBlockChildren(r, parts[1])
+	}
+	return nil, apiError(http.StatusBadRequest, notion.CODE_INVALID_REQUEST_URL, "Invalid request URL.")
+}
+
+func (s *Server) checkHeaders(r *http.Request) *notion.APIError {
+	if r.Header.Get("Authorization") != "Bearer "+s.opts.Token {
+		return apiError(http.StatusUnauthorized, notion.CODE_UNAUTHORIZED, "API token is invalid.")
+	}
+	version := r.Header.Get("Notion-Version")
+	if version == "" {
+		return apiError(http.StatusBadRequest, notion.CODE_MISSING_VERSION,
+			"Notion-Version header failed validation: Notion-Version header should be defined, instead was `undefined`.")
+	}
+	if len(s.opts.Versions) > 0 && !containsString(s.opts.Versions, version) {
+		return apiError(http.StatusBadRequest, notion.CODE_VALIDATION_ERROR,
+			fmt.Sprintf("Notion-Version header failed validation: %s is not a supported version.", version))
+	}
+	return nil
+}
+
+// Take a token from the rate limit bucket, returning how long to wait if there isn't one
+func (s *Server) rateLimit() time.Duration {
+	if s.opts.RateLimit <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if !s.lastRequest.IsZero() {
+		s.tokens = math.Min(float64(s.opts.RateBurst), s.tokens+now.Sub(s.lastRequest).Seconds()*s.opts.RateLimit)
+	}
+	s.lastRequest = now
+	if s.tokens < 1 {
+		return time.Duration((1 - s.tokens) / s.opts.RateLimit * float64(time.Second))
+	}
+	s.tokens--
+	return 0
+}
+
+// Return the first fault matching the request, using it up
+func (s *Server) fault(method string, path string) *Fault {
+	for i, fault := range s.faults {
+		if (fault.Method != "" && fault.Method != method) || !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+func apiError(status int, code string, message string) *notion.APIError {
+	return &notion.APIError{Status: status, Code: code, Message: message}
+}
+
+func writeError(w http.ResponseWriter, apiErr *notion.APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(struct {
+		Object string `json:"object"`
+		*notion.APIError
+	}{"error", apiErr})
+}
+
+func notFound(kind string, id string) *notion.APIError {
+	return apiError(http.StatusNotFound, notion.CODE_OBJECT_NOT_FOUND, fmt.Sprintf(
+		"Could not find %s with ID: %s. Make sure the relevant pages and databases are shared with your integration.", kind, id))
+}
+
+func validationError(format string, a ...interface{}) *notion.APIError {
+	return apiError(http.StatusBadRequest, notion.CODE_VALIDATION_ERROR, fmt.Sprintf(format, a...))
+}
+
+// Decode a JSON request body into v
+func decode(r *http.Request, v interface{}) *notion.APIError {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return apiError(http.StatusBadRequest, notion.CODE_INVALID_JSON, "Error parsing JSON body.")
+	}
+	return nil
+}
+
+// ===============================================================
+// Helpers
+// ---------------------------------------------------------------
+
+// Return a new ID in Notion's dashed UUID format. IDs are sequential, so runs are repeatable
+func (s *Server) newId() string {
+	s.ids++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.ids)
+}
+
+func (s *Server) now() string {
+	return s.opts.Now().UTC().Format(TIME_FORMAT)
+}
+
+// Return an ID without dashes, as Notion accepts IDs either way
+func normalizeId(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
+
+// Return a short property ID derived from its name
+func propertyId(name string) string {
+	id := strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return id
+}
+
+func usesDataSources(version string) bool {
+	return version >= notion.VERSION_2025_09_03
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
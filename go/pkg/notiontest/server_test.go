@@ -0,0 +1,304 @@
+package notiontest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mockNow = time.Date(2022, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// Start a server with a reading list of five pages, added a day apart
+func readingList(t *testing.T, opts Options) (*Server, notion.Database) {
+	opts.Now = func() time.Time { return mockNow }
+	s := NewServer(opts)
+	t.Cleanup(s.Close)
+
+	db := s.AddDatabase(notion.Database{
+		Title: []notion.RichText{notion.NewRichText("Reading list")},
+		Properties: map[string]notion.PropertySchema{
+			"Title":   {Type: notion.PROPERTY_TITLE},
+			"Created": {Type: notion.PROPERTY_CREATED_TIME},
+			"Rating":  {Type: notion.PROPERTY_NUMBER},
+			"Tags":    {Type: notion.PROPERTY_MULTI_SELECT},
+			"Read":    {Type: notion.PROPERTY_CHECKBOX},
+		},
+	})
+	for i, book := range []struct {
+		title  string
+		rating float64
+		tags   []string
+		read   bool
+	}{
+		{"Middlemarch", 5, []string{"Fiction"}, true},
+		{"Thinking in Systems", 4, []string{"Systems"}, false},
+		{"Piranesi", 4, []string{"Fiction", "Fantasy"}, false},
+		{"The Goal", 3, []string{"Systems"}, true},
+		{"Gödel, Escher, Bach", 5, []string{}, false},
+	} {
+		rating, read := book.rating, book.read
+		tags := []notion.SelectOption{}
+		for _, tag := range book.tags {
+			tags = append(tags, notion.SelectOption{Name: tag})
+		}
+		s.AddPage(db.Id, notion.Page{
+			CreatedTime: mockNow.AddDate(0, 0, i-5).Format(TIME_FORMAT),
+			Properties: map[string]notion.PropertyValue{
+				"title":  {Title: []notion.RichText{notion.NewRichText(book.title)}},
+				"Rating": {Number: &rating},
+				"Tags":   {MultiSelect: tags},
+				"Read":   {Checkbox: &read},
+			},
+		})
+	}
+	return s, db
+}
+
+func titles(pages []notion.Page) []string {
+	titles := []string{}
+	for _, page := range pages {
+		titles = append(titles, page.Title())
+	}
+	return titles
+}
+
+func TestQueryWithFilterSortsAndPaging(t *testing.T) {
+	// Arrange
+	s, db := readingList(t, Options{})
+	api := s.Api()
+	api.PageSize = 2
+	filter := notion.Or(
+		notion.Property("Tags").MultiSelect(notion.MultiSelectCondition{Contains: "Fiction"}),
+		notion.Property("Rating").Number(notion.NumberCondition{GreaterThanOrEqualTo: notion.Float(5)}),
+	)
+
+	// Act
+	pages, err := api.QueryDatabase(context.Background(), notion.Query{
+		Filter: &filter,
+		Sorts: []notion.Sort{
+			notion.SortByProperty("Rating", notion.DESCENDING),
+			notion.SortByTimestamp(notion.PROPERTY_CREATED_TIME, notion.ASCENDING),
+		},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Middlemarch", "Gödel, Escher, Bach", "Piranesi"}, titles(pages))
+	assert.Equal(t, 2, s.Requests(), "Three pages take two requests of two")
+	for _, page := range pages {
+		assert.Equal(t, db.Id, page.SourceDatabaseId)
+	}
+}
+
+func TestQueryOnDataSources(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{Versions: []string{notion.VERSION_2025_09_03}})
+	api := s.Api()
+	filter := notion.Property("Read").Checkbox(notion.CheckboxCondition{Equals: notion.Bool(true)})
+
+	// Act
+	pages, err := api.QueryDatabase(context.Background(), notion.Query{Filter: &filter})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Middlemarch", "The Goal"}, titles(pages))
+}
+
+func TestFilterOnCreatedTimeProperty(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{})
+	api := s.Api()
+
+	// Act: the client first filters "Created" as a date, which Notion rejects for a created_time property
+	pages, err := api.GetPagesSinceTime(mockNow.AddDate(0, 0, -3).Add(-time.Hour))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Piranesi", "The Goal", "Gödel, Escher, Bach"}, titles(pages))
+}
+
+func TestRejectUnknownFilterProperty(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{})
+	filter := notion.Property("Author").RichText(notion.TextCondition{Contains: "Eliot"})
+
+	// Act
+	_, err := s.Api().QueryDatabase(context.Background(), notion.Query{Filter: &filter})
+
+	// Assert
+	assert.True(t, errors.Is(err, notion.ErrValidation))
+	assert.Contains(t, err.Error(), "Could not find property with name or id: Author")
+}
+
+func TestCreateAndUpdatePage(t *testing.T) {
+	// Arrange
+	s, db := readingList(t, Options{})
+	api := s.Api()
+	ctx := context.Background()
+	rating := float64(4)
+
+	// Act
+	created, createErr := api.CreatePage(ctx, notion.NewPage{
+		Properties: map[string]notion.PropertyValue{
+			"title":  {Title: []notion.RichText{notion.NewRichText("Station Eleven")}},
+			"Rating": {Number: &rating},
+		},
+		Children: []notion.Block{notion.NewParagraph("Recommended by a friend")},
+	})
+	require.NoError(t, createErr)
+	rating = 5
+	_, updateErr := api.UpdatePage(ctx, created.Id, map[string]notion.PropertyValue{"Rating": {Number: &rating}})
+	page, getErr := api.GetPage(ctx, created.Id)
+	content, contentErr := api.GetPageContent(ctx, created.Id)
+
+	// Assert
+	require.NoError(t, updateErr)
+	require.NoError(t, getErr)
+	require.NoError(t, contentErr)
+	assert.Equal(t, "Station Eleven", page.Title())
+	assert.Equal(t, "5", page.Properties["Rating"].PlainText())
+	assert.Equal(t, mockNow.Format(TIME_FORMAT), page.Properties["Created"].PlainText())
+	assert.Equal(t, db.Id, created.SourceDatabaseId)
+	if assert.Len(t, content, 1) {
+		assert.Equal(t, "Recommended by a friend", notion.PlainText(content[0].RichText()))
+	}
+}
+
+func TestRejectInvalidPropertyValues(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{})
+	api := s.Api()
+	created := mockNow.Format(TIME_FORMAT)
+	text := "five"
+	tests := map[string]map[string]notion.PropertyValue{
+		"is not a property that exists":   {"Author": {RichText: []notion.RichText{notion.NewRichText("George Eliot")}}},
+		"Rating is expected to be number": {"Rating": {Url: &text}},
+		"can't be set":                    {"Created": {CreatedTime: &created}},
+	}
+
+	for message, properties := range tests {
+		// Act
+		_, err := api.CreatePage(context.Background(), notion.NewPage{Properties: properties})
+
+		// Assert
+		assert.True(t, errors.Is(err, notion.ErrValidation), message)
+		assert.Contains(t, err.Error(), message)
+	}
+}
+
+func TestCheckAuthAndVersionHeaders(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{})
+	api := s.Api()
+	api.SecretToken = "secret_wrong"
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/v1/pages/"+s.Api().DatabaseId, nil)
+	req.Header.Set("Authorization", "Bearer "+DEFAULT_TOKEN)
+
+	// Act
+	_, unauthorizedErr := api.GetPages()
+	res, err := http.DefaultClient.Do(req)
+
+	// Assert
+	assert.True(t, errors.Is(unauthorizedErr, notion.ErrUnauthorized))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "The version header is missing")
+}
+
+func TestInjectFaults(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{})
+	api := s.Api()
+	api.Retry.BaseDelay = time.Millisecond
+	s.Inject(Fault{Path: "/databases", Status: http.StatusServiceUnavailable, Code: notion.CODE_SERVICE_UNAVAILABLE, Times: 1})
+	s.Inject(Fault{Method: http.MethodGet, Path: "/pages", Status: http.StatusNotFound, Code: notion.CODE_OBJECT_NOT_FOUND})
+
+	// Act
+	pages, queryErr := api.GetPages()
+	_, getErr := api.GetPage(context.Background(), "00000000-0000-4000-8000-000000000003")
+
+	// Assert
+	require.NoError(t, queryErr, "The query is retried after the fault")
+	assert.Len(t, pages, 5)
+	assert.True(t, errors.Is(getErr, notion.ErrObjectNotFound))
+	assert.Equal(t, 3, s.Requests())
+}
+
+func TestRateLimit(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{RateLimit: 0.5, RateBurst: 1})
+	api := s.Api()
+	api.Retry.MaxAttempts = 1
+
+	// Act
+	_, firstErr := api.GetPages()
+	_, secondErr := api.GetPages()
+
+	// Assert
+	require.NoError(t, firstErr)
+	var apiErr *notion.APIError
+	if assert.True(t, errors.As(secondErr, &apiErr)) {
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.Status)
+		assert.Equal(t, 2*time.Second, apiErr.RetryAfter)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	// Arrange
+	s, _ := readingList(t, Options{Latency: 50 * time.Millisecond})
+	api := s.Api()
+	api.Retry.MaxAttempts = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := api.QueryDatabase(ctx, notion.Query{})
+
+	// Assert
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestTruncateAndCompleteLinkedPages(t *testing.T) {
+	// Arrange
+	s := NewServer(Options{})
+	t.Cleanup(s.Close)
+	db := s.AddDatabase(notion.Database{
+		Title: []notion.RichText{notion.NewRichText("Zettelkasten")},
+		Properties: map[string]notion.PropertySchema{
+			"Name":  {Type: notion.PROPERTY_TITLE},
+			"Links": {Type: notion.PROPERTY_RELATION},
+		},
+	})
+	links := []notion.ObjectRef{}
+	for i := 0; i < 30; i++ {
+		links = append(links, notion.ObjectRef{Id: s.newId()})
+	}
+	s.AddPage(db.Id, notion.Page{Properties: map[string]notion.PropertyValue{
+		"Name":  {Title: []notion.RichText{notion.NewRichText("Index")}},
+		"Links": {Relation: links},
+	}})
+	api := s.Api()
+	api.PageSize = 10
+
+	// Act
+	truncated, truncatedErr := api.GetPages()
+	api.CompleteTruncated = true
+	completed, completedErr := api.GetPages()
+
+	// Assert
+	require.NoError(t, truncatedErr)
+	require.NoError(t, completedErr)
+	require.Len(t, truncated, 1)
+	require.Len(t, completed, 1)
+	assert.Len(t, truncated[0].Properties["Links"].Relation, notion.MAX_PROPERTY_ITEMS)
+	assert.Equal(t, []string{"Links"}, truncated[0].TruncatedProperties())
+	assert.Equal(t, links, completed[0].Properties["Links"].Relation)
+	assert.Empty(t, completed[0].TruncatedProperties())
+	assert.Equal(t, 5, s.Requests(), "Completing the relation takes three requests of ten items")
+}
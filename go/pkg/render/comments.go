@@ -0,0 +1,54 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Render a comment thread as a GitHub-flavoured Markdown section to follow PageMarkdown,
+// or nothing if there are no comments
+func CommentsMarkdown(comments []notion.Comment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n## Comments\n\n")
+	for _, comment := range comments {
+		fmt.Fprintf(&sb, "- **%s** (%s): %s\n",
+			markdownEscaper.Replace(commentAuthor(comment)), commentDate(comment), RichTextMarkdown(comment.RichText))
+	}
+	return sb.String()
+}
+
+// Render a comment thread as an HTML section, or nothing if there are no comments
+func CommentsHTML(comments []notion.Comment) string {
+	if len(comments) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("<section>\n<h2>Comments</h2>\n<ul>\n")
+	for _, comment := range comments {
+		fmt.Fprintf(&sb, "<li><strong>%s</strong> (%s): %s</li>\n",
+			html.EscapeString(commentAuthor(comment)), commentDate(comment), RichTextHTML(comment.RichText))
+	}
+	sb.WriteString("</ul>\n</section>\n")
+	return sb.String()
+}
+
+func commentAuthor(comment notion.Comment) string {
+	if comment.CreatedBy == nil || comment.CreatedBy.Name == "" {
+		return "Unknown"
+	}
+	return comment.CreatedBy.Name
+}
+
+// Return the date part of the comment's ISO 8601 creation time
+func commentDate(comment notion.Comment) string {
+	if len(comment.CreatedTime) < 10 {
+		return comment.CreatedTime
+	}
+	return html.EscapeString(comment.CreatedTime[:10])
+}
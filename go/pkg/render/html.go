@@ -0,0 +1,274 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Render a page's title, link, author and content as an HTML fragment.
+// All text is escaped and only http, https and mailto links are kept
+func PageHTML(page notion.Page, blocks []notion.Block) string {
+	var sb strings.Builder
+	title := page.Title()
+	if title == "" {
+		title = "Untitled"
+	}
+	sb.WriteString("<article>\n")
+	sb.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	if href := safeUrl(page.Url); href != "" {
+		sb.WriteString(`<p><a href="` + href + `">Open in Notion</a></p>` + "\n")
+	}
+	if author := authorName(page); author != "" {
+		sb.WriteString("<p>Created by " + html.EscapeString(author) + "</p>\n")
+	}
+	writeHTMLBlocks(&sb, blocks)
+	sb.WriteString("</article>\n")
+	return sb.String()
+}
+
+// Render a tree of blocks as an HTML fragment.
+// All text is escaped and only http, https and mailto links are kept
+func HTML(blocks []notion.Block) string {
+	var sb strings.Builder
+	writeHTMLBlocks(&sb, blocks)
+	return sb.String()
+}
+
+// Render rich text as inline HTML
+func RichTextHTML(segments []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range segments {
+		sb.WriteString(richTextSegmentHTML(rt))
+	}
+	return sb.String()
+}
+
+func richTextSegmentHTML(rt notion.RichText) string {
+	text := segmentText(rt)
+	if text == "" {
+		return ""
+	}
+
+	if rt.Equation != nil {
+		return `<span class="equation">` + html.EscapeString(rt.Equation.Expression) + "</span>"
+	}
+	if rt.Mention != nil && rt.Mention.Type == "user" {
+		text = "@" + strings.TrimPrefix(text, "@")
+	}
+
+	text = strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+	if a := rt.Annotations; a != nil {
+		if a.Code {
+			text = "<code>" + text + "</code>"
+		}
+		if a.Underline {
+			text = "<u>" + text + "</u>"
+		}
+		if a.Strikethrough {
+			text = "<s>" + text + "</s>"
+		}
+		if a.Italic {
+			text = "<em>" + text + "</em>"
+		}
+		if a.Bold {
+			text = "<strong>" + text + "</strong>"
+		}
+	}
+	if href := safeUrl(segmentHref(rt)); href != "" {
+		text = `<a href="` + href + `">` + text + "</a>"
+	}
+	return text
+}
+
+// Return an escaped URL suitable for an href or src attribute,
+// or an empty string if it could be used to run script
+func safeUrl(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return html.EscapeString(u.String())
+	}
+	return ""
+}
+
+func writeHTMLBlocks(sb *strings.Builder, blocks []notion.Block) {
+	for i, block := range blocks {
+		// Open and close lists around runs of consecutive list items
+		startsList := isListItem(block) && (i == 0 || blocks[i-1].Type != block.Type)
+		endsList := isListItem(block) && (i+1 == len(blocks) || blocks[i+1].Type != block.Type)
+		if startsList {
+			sb.WriteString(listTag(block, true) + "\n")
+		}
+		writeHTMLBlock(sb, block)
+		if endsList {
+			sb.WriteString(listTag(block, false) + "\n")
+		}
+	}
+}
+
+func listTag(block notion.Block, open bool) string {
+	switch {
+	case block.Type == notion.BLOCK_NUMBERED_LIST_ITEM && open:
+		return "<ol>"
+	case block.Type == notion.BLOCK_NUMBERED_LIST_ITEM:
+		return "</ol>"
+	case block.Type == notion.BLOCK_TO_DO && open:
+		return `<ul class="to-do">`
+	case open:
+		return "<ul>"
+	}
+	return "</ul>"
+}
+
+func writeHTMLBlock(sb *strings.Builder, block notion.Block) {
+	text := RichTextHTML(block.RichText())
+
+	switch block.Type {
+	case notion.BLOCK_PARAGRAPH:
+		sb.WriteString("<p>" + text + "</p>\n")
+		writeHTMLChildren(sb, block.Children, `<div class="indented">`, "</div>")
+	case notion.BLOCK_HEADING_1, notion.BLOCK_HEADING_2, notion.BLOCK_HEADING_3:
+		tag := "h" + strings.TrimPrefix(block.Type, "heading_")
+		heading := fmt.Sprintf("<%s>%s</%s>", tag, text, tag)
+		if len(block.Children) > 0 {
+			sb.WriteString("<details>\n<summary>" + heading + "</summary>\n")
+			writeHTMLBlocks(sb, block.Children)
+			sb.WriteString("</details>\n")
+		} else {
+			sb.WriteString(heading + "\n")
+		}
+	case notion.BLOCK_BULLETED_LIST_ITEM, notion.BLOCK_NUMBERED_LIST_ITEM:
+		sb.WriteString("<li>" + text)
+		writeHTMLChildren(sb, block.Children, "\n", "")
+		sb.WriteString("</li>\n")
+	case notion.BLOCK_TO_DO:
+		checked := ""
+		if block.ToDo.Checked {
+			checked = " checked"
+		}
+		sb.WriteString(`<li><input type="checkbox" disabled` + checked + "> " + text)
+		writeHTMLChildren(sb, block.Children, "\n", "")
+		sb.WriteString("</li>\n")
+	case notion.BLOCK_TOGGLE:
+		sb.WriteString("<details>\n<summary>" + text + "</summary>\n")
+		writeHTMLBlocks(sb, block.Children)
+		sb.WriteString("</details>\n")
+	case notion.BLOCK_QUOTE:
+		sb.WriteString("<blockquote>\n<p>" + text + "</p>\n")
+		writeHTMLBlocks(sb, block.Children)
+		sb.WriteString("</blockquote>\n")
+	case notion.BLOCK_CALLOUT:
+		sb.WriteString(`<aside class="callout">` + "\n<p>")
+		if icon := block.Callout.Icon; icon != nil && icon.Emoji != "" {
+			sb.WriteString(html.EscapeString(icon.Emoji) + " ")
+		}
+		sb.WriteString(text + "</p>\n")
+		writeHTMLBlocks(sb, block.Children)
+		sb.WriteString("</aside>\n")
+	case notion.BLOCK_CODE:
+		code := html.EscapeString(notion.PlainText(block.Code.RichText))
+		if language := codeLanguage(block.Code.Language); language != "" {
+			sb.WriteString(`<pre><code class="language-` + html.EscapeString(language) + `">` + code + "</code></pre>\n")
+		} else {
+			sb.WriteString("<pre><code>" + code + "</code></pre>\n")
+		}
+	case notion.BLOCK_EQUATION:
+		sb.WriteString(`<div class="equation">` + html.EscapeString(block.Equation.Expression) + "</div>\n")
+	case notion.BLOCK_DIVIDER:
+		sb.WriteString("<hr>\n")
+	case notion.BLOCK_IMAGE:
+		caption := RichTextHTML(block.Image.Caption)
+		alt := html.EscapeString(notion.PlainText(block.Image.Caption))
+		if src := safeUrl(block.Image.Url()); src != "" {
+			sb.WriteString(`<figure><img src="` + src + `" alt="` + alt + `">`)
+			if caption != "" {
+				sb.WriteString("<figcaption>" + caption + "</figcaption>")
+			}
+			sb.WriteString("</figure>\n")
+		}
+	case notion.BLOCK_VIDEO, notion.BLOCK_FILE, notion.BLOCK_PDF:
+		file := fileBlock(block)
+		sb.WriteString("<p>" + htmlLink(RichTextHTML(file.Caption), file.Url()) + "</p>\n")
+	case notion.BLOCK_BOOKMARK, notion.BLOCK_EMBED, notion.BLOCK_LINK_PREVIEW:
+		link := linkBlock(block)
+		sb.WriteString("<p>" + htmlLink(RichTextHTML(link.Caption), link.Url) + "</p>\n")
+	case notion.BLOCK_TABLE:
+		writeHTMLTable(sb, block)
+	case notion.BLOCK_CHILD_PAGE:
+		sb.WriteString("<p>" + htmlLink(html.EscapeString(block.ChildPage.Title), notionUrl(block.Id)) + "</p>\n")
+	case notion.BLOCK_CHILD_DATABASE:
+		sb.WriteString("<p>" + htmlLink(html.EscapeString(block.ChildDatabase.Title), notionUrl(block.Id)) + "</p>\n")
+	case notion.BLOCK_COLUMN_LIST:
+		writeHTMLChildren(sb, block.Children, `<div class="column-list">`+"\n", "</div>\n")
+	case notion.BLOCK_COLUMN:
+		writeHTMLChildren(sb, block.Children, `<div class="column">`+"\n", "</div>\n")
+	case notion.BLOCK_SYNCED_BLOCK:
+		writeHTMLBlocks(sb, block.Children)
+	default:
+		if text != "" {
+			sb.WriteString("<p>" + text + "</p>\n")
+		}
+	}
+}
+
+func writeHTMLChildren(sb *strings.Builder, blocks []notion.Block, open string, close string) {
+	if len(blocks) == 0 {
+		return
+	}
+	sb.WriteString(open)
+	writeHTMLBlocks(sb, blocks)
+	sb.WriteString(close)
+}
+
+func writeHTMLTable(sb *strings.Builder, block notion.Block) {
+	rows := block.Children
+	if len(rows) == 0 {
+		return
+	}
+
+	sb.WriteString("<table>\n")
+	if block.Table.HasColumnHeader {
+		sb.WriteString("<thead>\n")
+		writeHTMLRow(sb, rows[0], block.Table, true)
+		sb.WriteString("</thead>\n")
+		rows = rows[1:]
+	}
+	sb.WriteString("<tbody>\n")
+	for _, row := range rows {
+		writeHTMLRow(sb, row, block.Table, false)
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+}
+
+func writeHTMLRow(sb *strings.Builder, row notion.Block, table *notion.TableBlock, header bool) {
+	if row.TableRow == nil {
+		return
+	}
+	sb.WriteString("<tr>")
+	for i, cell := range row.TableRow.Cells {
+		tag := "td"
+		if header || (i == 0 && table.HasRowHeader) {
+			tag = "th"
+		}
+		sb.WriteString("<" + tag + ">" + RichTextHTML(cell) + "</" + tag + ">")
+	}
+	sb.WriteString("</tr>\n")
+}
+
+func htmlLink(text string, raw string) string {
+	href := safeUrl(raw)
+	if text == "" {
+		text = html.EscapeString(raw)
+	}
+	if href == "" {
+		return text
+	}
+	return `<a href="` + href + `">` + text + "</a>"
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderHTML(t *testing.T) {
+	expected := "<h1>Goals</h1>\n" +
+		`<p>Read <strong>more </strong><a href="https://example.com/books">books</a>@Ada<span class="equation">e=mc^2</span></p>` + "\n" +
+		"<ul>\n<li>First\n<ul>\n<li>Nested</li>\n</ul>\n</li>\n<li>Second</li>\n</ul>\n" +
+		"<ol>\n<li>One</li>\n<li>Two</li>\n</ol>\n" +
+		`<ul class="to-do">` + "\n" +
+		`<li><input type="checkbox" disabled checked> Done</li>` + "\n" +
+		`<li><input type="checkbox" disabled> Not done</li>` + "\n" +
+		"</ul>\n" +
+		"<details>\n<summary>More</summary>\n<p>Hidden</p>\n</details>\n" +
+		"<blockquote>\n<p>To be</p>\n</blockquote>\n" +
+		`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>` + "\n" +
+		"<table>\n<thead>\n<tr><th>Name</th><th>Value</th></tr>\n</thead>\n<tbody>\n<tr><td>a|b</td><td>1</td></tr>\n</tbody>\n</table>\n" +
+		`<figure><img src="https://example.com/cat.png" alt="A cat"><figcaption>A cat</figcaption></figure>` + "\n" +
+		"<hr>\n"
+
+	assert.Equal(t, expected, HTML(mockBlocks()))
+}
+
+func TestRenderHTMLIsSanitized(t *testing.T) {
+	input := []notion.RichText{
+		{Type: "text", PlainText: "<script>alert(1)</script>", Text: &notion.Text{Content: "<script>alert(1)</script>"}},
+		{Type: "text", PlainText: "click", Href: "javascript:alert(1)", Text: &notion.Text{Content: "click", Link: &notion.Link{Url: "javascript:alert(1)"}}},
+		{Type: "text", PlainText: "quote", Href: `https://example.com/?q="><script>`, Text: &notion.Text{Content: "quote"}},
+	}
+
+	assert.Equal(t,
+		`&lt;script&gt;alert(1)&lt;/script&gt;click<a href="https://example.com/?q=&#34;&gt;&lt;script&gt;">quote</a>`,
+		RichTextHTML(input))
+}
+
+func TestRenderPageHTMLWithAuthor(t *testing.T) {
+	page := notion.Page{
+		Url:       "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+		CreatedBy: &notion.User{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada <Lovelace>"},
+	}
+
+	expected := "<article>\n<h1>Untitled</h1>\n" +
+		`<p><a href="https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3">Open in Notion</a></p>` + "\n" +
+		"<p>Created by Ada &lt;Lovelace&gt;</p>\n</article>\n"
+	assert.Equal(t, expected, PageHTML(page, []notion.Block{}))
+}
+
+func TestRenderCommentsHTML(t *testing.T) {
+	comments := []notion.Comment{
+		{CreatedTime: "2022-07-15T16:52:00.000Z", CreatedBy: &notion.User{Name: "<Ada>"}, RichText: text("Still true")},
+	}
+
+	expected := "<section>\n<h2>Comments</h2>\n<ul>\n<li><strong>&lt;Ada&gt;</strong> (2022-07-15): Still true</li>\n</ul>\n</section>\n"
+	assert.Equal(t, expected, CommentsHTML(comments))
+}
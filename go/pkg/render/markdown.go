@@ -0,0 +1,280 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+// Characters with special meaning in GitHub-flavoured Markdown
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`, "$", `\$`,
+)
+
+// Render a page's title, link, author and content as GitHub-flavoured Markdown
+func PageMarkdown(page notion.Page, blocks []notion.Block) string {
+	var sb strings.Builder
+	title := page.Title()
+	if title == "" {
+		title = "Untitled"
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", markdownEscaper.Replace(title))
+	if page.Url != "" {
+		fmt.Fprintf(&sb, "<%s>\n\n", page.Url)
+	}
+	if author := authorName(page); author != "" {
+		fmt.Fprintf(&sb, "_Created by %s_\n\n", markdownEscaper.Replace(author))
+	}
+	sb.WriteString(Markdown(blocks))
+	return sb.String()
+}
+
+// Render a tree of blocks as GitHub-flavoured Markdown
+func Markdown(blocks []notion.Block) string {
+	var sb strings.Builder
+	writeMarkdownBlocks(&sb, blocks)
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// Render rich text as inline GitHub-flavoured Markdown
+func RichTextMarkdown(segments []notion.RichText) string {
+	var sb strings.Builder
+	for _, rt := range segments {
+		sb.WriteString(richTextSegmentMarkdown(rt))
+	}
+	return sb.String()
+}
+
+func richTextSegmentMarkdown(rt notion.RichText) string {
+	text := segmentText(rt)
+	if text == "" {
+		return ""
+	}
+
+	if rt.Equation != nil {
+		return "$" + rt.Equation.Expression + "$"
+	}
+	if rt.Mention != nil && rt.Mention.Type == "user" {
+		text = "@" + strings.TrimPrefix(text, "@")
+	}
+
+	a := rt.Annotations
+	if a != nil && a.Code {
+		text = "`" + strings.ReplaceAll(text, "`", "'") + "`"
+	} else {
+		text = markdownEscaper.Replace(text)
+	}
+	if href := segmentHref(rt); href != "" {
+		text = fmt.Sprintf("[%s](%s)", text, strings.ReplaceAll(href, ")", "%29"))
+	}
+	if a != nil {
+		if a.Strikethrough {
+			text = wrapMarkdown(text, "~~")
+		}
+		if a.Italic {
+			text = wrapMarkdown(text, "_")
+		}
+		if a.Bold {
+			text = wrapMarkdown(text, "**")
+		}
+	}
+	return text
+}
+
+// Wrap text in emphasis markers, keeping surrounding whitespace outside them
+// since Markdown doesn't allow emphasis to start or end with a space
+func wrapMarkdown(text string, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + marker + trimmed + marker + text[start+len(trimmed):]
+}
+
+func writeMarkdownBlocks(sb *strings.Builder, blocks []notion.Block) {
+	number := 0
+	for i, block := range blocks {
+		if block.Type == notion.BLOCK_NUMBERED_LIST_ITEM {
+			number++
+		} else {
+			number = 0
+		}
+
+		writeMarkdownBlock(sb, block, number)
+
+		// Keep consecutive list items together, separate everything else
+		if i+1 < len(blocks) && isListItem(block) && blocks[i+1].Type == block.Type {
+			continue
+		}
+		sb.WriteString("\n")
+	}
+}
+
+func writeMarkdownBlock(sb *strings.Builder, block notion.Block, number int) {
+	text := RichTextMarkdown(block.RichText())
+
+	switch block.Type {
+	case notion.BLOCK_PARAGRAPH:
+		sb.WriteString(text + "\n")
+		writeIndentedMarkdown(sb, block.Children, "    ")
+	case notion.BLOCK_HEADING_1:
+		sb.WriteString("# " + text + "\n")
+		writeToggleableChildren(sb, block)
+	case notion.BLOCK_HEADING_2:
+		sb.WriteString("## " + text + "\n")
+		writeToggleableChildren(sb, block)
+	case notion.BLOCK_HEADING_3:
+		sb.WriteString("### " + text + "\n")
+		writeToggleableChildren(sb, block)
+	case notion.BLOCK_BULLETED_LIST_ITEM:
+		sb.WriteString("- " + text + "\n")
+		writeIndentedMarkdown(sb, block.Children, "  ")
+	case notion.BLOCK_NUMBERED_LIST_ITEM:
+		prefix := fmt.Sprintf("%d. ", number)
+		sb.WriteString(prefix + text + "\n")
+		writeIndentedMarkdown(sb, block.Children, strings.Repeat(" ", len(prefix)))
+	case notion.BLOCK_TO_DO:
+		check := " "
+		if block.ToDo.Checked {
+			check = "x"
+		}
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", check, text))
+		writeIndentedMarkdown(sb, block.Children, "  ")
+	case notion.BLOCK_TOGGLE:
+		// GitHub renders <details> natively, and Markdown isn't processed inside <summary>
+		sb.WriteString("<details>\n<summary>" + RichTextHTML(block.Toggle.RichText) + "</summary>\n\n")
+		writeMarkdownBlocks(sb, block.Children)
+		sb.WriteString("</details>\n")
+	case notion.BLOCK_QUOTE:
+		writeQuotedMarkdown(sb, text, block.Children)
+	case notion.BLOCK_CALLOUT:
+		if icon := block.Callout.Icon; icon != nil && icon.Emoji != "" {
+			text = icon.Emoji + " " + text
+		}
+		writeQuotedMarkdown(sb, text, block.Children)
+	case notion.BLOCK_CODE:
+		code := notion.PlainText(block.Code.RichText)
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		sb.WriteString(fence + codeLanguage(block.Code.Language) + "\n" + code + "\n" + fence + "\n")
+		if caption := RichTextMarkdown(block.Code.Caption); caption != "" {
+			sb.WriteString("\n_" + caption + "_\n")
+		}
+	case notion.BLOCK_EQUATION:
+		sb.WriteString("$$\n" + block.Equation.Expression + "\n$$\n")
+	case notion.BLOCK_DIVIDER:
+		sb.WriteString("---\n")
+	case notion.BLOCK_IMAGE:
+		alt := notion.PlainText(block.Image.Caption)
+		sb.WriteString(fmt.Sprintf("![%s](%s)\n", markdownEscaper.Replace(alt), block.Image.Url()))
+	case notion.BLOCK_VIDEO, notion.BLOCK_FILE, notion.BLOCK_PDF:
+		file := fileBlock(block)
+		sb.WriteString(markdownLink(RichTextMarkdown(file.Caption), file.Url()) + "\n")
+	case notion.BLOCK_BOOKMARK, notion.BLOCK_EMBED, notion.BLOCK_LINK_PREVIEW:
+		link := linkBlock(block)
+		sb.WriteString(markdownLink(RichTextMarkdown(link.Caption), link.Url) + "\n")
+	case notion.BLOCK_TABLE:
+		writeMarkdownTable(sb, block)
+	case notion.BLOCK_CHILD_PAGE:
+		sb.WriteString(markdownLink(markdownEscaper.Replace(block.ChildPage.Title), notionUrl(block.Id)) + "\n")
+	case notion.BLOCK_CHILD_DATABASE:
+		sb.WriteString(markdownLink(markdownEscaper.Replace(block.ChildDatabase.Title), notionUrl(block.Id)) + "\n")
+	case notion.BLOCK_COLUMN_LIST, notion.BLOCK_COLUMN, notion.BLOCK_SYNCED_BLOCK:
+		// Markdown has no layout, so render the contents one after another
+		writeMarkdownBlocks(sb, block.Children)
+	default:
+		if text != "" {
+			sb.WriteString(text + "\n")
+		}
+	}
+}
+
+func writeToggleableChildren(sb *strings.Builder, block notion.Block) {
+	if len(block.Children) > 0 {
+		sb.WriteString("\n")
+		writeMarkdownBlocks(sb, block.Children)
+	}
+}
+
+func writeIndentedMarkdown(sb *strings.Builder, blocks []notion.Block, indent string) {
+	if len(blocks) == 0 {
+		return
+	}
+	children := strings.TrimRight(Markdown(blocks), "\n")
+	for _, line := range strings.Split(children, "\n") {
+		if line == "" {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(indent + line + "\n")
+		}
+	}
+}
+
+func writeQuotedMarkdown(sb *strings.Builder, text string, children []notion.Block) {
+	content := text
+	if len(children) > 0 {
+		content += "\n\n" + strings.TrimRight(Markdown(children), "\n")
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			sb.WriteString(">\n")
+		} else {
+			sb.WriteString("> " + line + "\n")
+		}
+	}
+}
+
+func writeMarkdownTable(sb *strings.Builder, block notion.Block) {
+	rows := block.Children
+	width := block.Table.TableWidth
+	if len(rows) == 0 || width == 0 {
+		return
+	}
+
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for _, cell := range cells {
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	// GFM tables always have a header row, so use a blank one if the table doesn't
+	header := make([]string, width)
+	if block.Table.HasColumnHeader {
+		header = markdownCells(rows[0], width)
+		rows = rows[1:]
+	}
+	writeRow(header)
+	separator := make([]string, width)
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeRow(separator)
+	for _, row := range rows {
+		writeRow(markdownCells(row, width))
+	}
+}
+
+func markdownCells(row notion.Block, width int) []string {
+	cells := make([]string, width)
+	if row.TableRow == nil {
+		return cells
+	}
+	for i := 0; i < width && i < len(row.TableRow.Cells); i++ {
+		cells[i] = strings.ReplaceAll(RichTextMarkdown(row.TableRow.Cells[i]), "\n", "<br>")
+	}
+	return cells
+}
+
+func markdownLink(text string, url string) string {
+	if text == "" {
+		text = markdownEscaper.Replace(url)
+	}
+	return fmt.Sprintf("[%s](%s)", text, strings.ReplaceAll(url, ")", "%29"))
+}
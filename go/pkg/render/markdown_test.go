@@ -0,0 +1,93 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	expected := "# Goals\n" +
+		"\n" +
+		"Read **more** [books](https://example.com/books)@Ada$e=mc^2$\n" +
+		"\n" +
+		"- First\n" +
+		"  - Nested\n" +
+		"- Second\n" +
+		"\n" +
+		"1. One\n" +
+		"2. Two\n" +
+		"\n" +
+		"- [x] Done\n" +
+		"- [ ] Not done\n" +
+		"\n" +
+		"<details>\n<summary>More</summary>\n\nHidden\n\n</details>\n" +
+		"\n" +
+		"> To be\n" +
+		"\n" +
+		"```go\nfmt.Println(\"<hi>\")\n```\n" +
+		"\n" +
+		"| Name | Value |\n" +
+		"| --- | --- |\n" +
+		"| a\\|b | 1 |\n" +
+		"\n" +
+		"![A cat](https://example.com/cat.png)\n" +
+		"\n" +
+		"---\n"
+
+	assert.Equal(t, expected, Markdown(mockBlocks()))
+}
+
+func TestRenderMarkdownRichText(t *testing.T) {
+	tests := []struct {
+		input    []notion.RichText
+		expected string
+	}{
+		{text("*not emphasis*"), `\*not emphasis\*`},
+		{[]notion.RichText{annotated("x := 1", notion.Annotations{Code: true})}, "`x := 1`"},
+		{[]notion.RichText{annotated(" spaced ", notion.Annotations{Italic: true, Strikethrough: true})}, " _~~spaced~~_ "},
+		{[]notion.RichText{{Type: "mention", PlainText: "Other page", Mention: &notion.Mention{Type: "page", Page: &notion.ObjectRef{Id: "5331da24-6597-4f2d-a684-fd94a0f3278a"}}}},
+			"[Other page](https://www.notion.so/5331da2465974f2da684fd94a0f3278a)"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, RichTextMarkdown(test.input))
+	}
+}
+
+func TestRenderPageMarkdown(t *testing.T) {
+	page := notion.Page{
+		Url: "https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3",
+		Properties: map[string]notion.PropertyValue{
+			"Name": {Type: notion.PROPERTY_TITLE, Title: text("Initial goals")},
+		},
+	}
+	blocks := []notion.Block{{Type: notion.BLOCK_PARAGRAPH, Paragraph: &notion.TextBlock{RichText: text("Hello")}}}
+
+	expected := "# Initial goals\n\n<https://www.notion.so/Initial-goals-3350ba0448b143e387261b1e9828b2b3>\n\nHello\n"
+	assert.Equal(t, expected, PageMarkdown(page, blocks))
+}
+
+func TestRenderPageMarkdownWithAuthor(t *testing.T) {
+	page := notion.Page{
+		Properties: map[string]notion.PropertyValue{
+			"Name": {Type: notion.PROPERTY_TITLE, Title: text("Initial goals")},
+		},
+		CreatedBy: &notion.User{Id: "8fa5cc3b-d1b5-4e33-b3b4-6a4b7d1f10ed", Name: "Ada_Lovelace"},
+	}
+
+	expected := "# Initial goals\n\n_Created by Ada\\_Lovelace_\n\n\n"
+	assert.Equal(t, expected, PageMarkdown(page, []notion.Block{}))
+}
+
+func TestRenderCommentsMarkdown(t *testing.T) {
+	comments := []notion.Comment{
+		{CreatedTime: "2022-07-15T16:52:00.000Z", CreatedBy: &notion.User{Name: "Ada Lovelace"}, RichText: text("Still *true*")},
+		{CreatedTime: "2022-07-16T09:00:00.000Z", RichText: text("Agreed")},
+	}
+
+	expected := "\n## Comments\n\n- **Ada Lovelace** (2022-07-15): Still \\*true\\*\n- **Unknown** (2022-07-16): Agreed\n"
+	assert.Equal(t, expected, CommentsMarkdown(comments))
+	assert.Equal(t, "", CommentsMarkdown([]notion.Comment{}))
+}
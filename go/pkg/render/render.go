@@ -0,0 +1,111 @@
+// Package render turns Notion rich text and block trees into readable
+// Markdown and HTML
+package render
+
+import (
+	"strings"
+
+	"github.com/jeffrosenberg/random-notion/pkg/notion"
+)
+
+func isListItem(block notion.Block) bool {
+	switch block.Type {
+	case notion.BLOCK_BULLETED_LIST_ITEM, notion.BLOCK_NUMBERED_LIST_ITEM, notion.BLOCK_TO_DO:
+		return true
+	}
+	return false
+}
+
+// Return the text of a rich text segment, falling back to its content
+// for segments built locally rather than returned by the API
+func segmentText(rt notion.RichText) string {
+	if rt.PlainText != "" {
+		return rt.PlainText
+	}
+	if rt.Text != nil {
+		return rt.Text.Content
+	}
+	if rt.Equation != nil {
+		return rt.Equation.Expression
+	}
+	return ""
+}
+
+// Return where a rich text segment links to, if anywhere
+func segmentHref(rt notion.RichText) string {
+	if rt.Href != "" {
+		return rt.Href
+	}
+	if rt.Text != nil && rt.Text.Link != nil {
+		return rt.Text.Link.Url
+	}
+	if rt.Mention != nil {
+		switch {
+		case rt.Mention.Page != nil:
+			return notionUrl(rt.Mention.Page.Id)
+		case rt.Mention.Database != nil:
+			return notionUrl(rt.Mention.Database.Id)
+		case rt.Mention.LinkPreview != nil:
+			return rt.Mention.LinkPreview.Url
+		}
+	}
+	return ""
+}
+
+// Build a link to a Notion page or block from its ID
+func notionUrl(id string) string {
+	return "https://www.notion.so/" + strings.ReplaceAll(id, "-", "")
+}
+
+// Notion names some languages differently than syntax highlighters do
+func codeLanguage(language string) string {
+	switch language {
+	case "plain text":
+		return ""
+	case "c#":
+		return "csharp"
+	case "c++":
+		return "cpp"
+	case "f#":
+		return "fsharp"
+	case "objective-c":
+		return "objectivec"
+	case "shell", "bash":
+		return "sh"
+	}
+	return strings.ReplaceAll(language, " ", "-")
+}
+
+func fileBlock(block notion.Block) notion.FileBlock {
+	switch {
+	case block.Image != nil:
+		return *block.Image
+	case block.Video != nil:
+		return *block.Video
+	case block.File != nil:
+		return *block.File
+	case block.Pdf != nil:
+		return *block.Pdf
+	}
+	return notion.FileBlock{}
+}
+
+func linkBlock(block notion.Block) notion.LinkBlock {
+	switch {
+	case block.Bookmark != nil:
+		return *block.Bookmark
+	case block.Embed != nil:
+		return *block.Embed
+	case block.LinkPreview != nil:
+		return *block.LinkPreview
+	}
+	return notion.LinkBlock{}
+}
+
+// Return the name of the user who created the page, if it's been resolved
+func authorName(page notion.Page) string {
+	if page.CreatedBy == nil {
+		return ""
+	}
+	return page.CreatedBy.Name
+}
@@ -0,0 +1,44 @@
+package render
+
+import "github.com/jeffrosenberg/random-notion/pkg/notion"
+
+func text(content string) []notion.RichText {
+	return []notion.RichText{{Type: "text", PlainText: content, Text: &notion.Text{Content: content}}}
+}
+
+func annotated(content string, annotations notion.Annotations) notion.RichText {
+	return notion.RichText{Type: "text", PlainText: content, Text: &notion.Text{Content: content}, Annotations: &annotations}
+}
+
+// A page exercising most block types
+func mockBlocks() []notion.Block {
+	return []notion.Block{
+		{Type: notion.BLOCK_HEADING_1, Heading1: &notion.HeadingBlock{RichText: text("Goals")}},
+		{Type: notion.BLOCK_PARAGRAPH, Paragraph: &notion.TextBlock{RichText: []notion.RichText{
+			{Type: "text", PlainText: "Read ", Text: &notion.Text{Content: "Read "}},
+			annotated("more ", notion.Annotations{Bold: true}),
+			{Type: "text", PlainText: "books", Href: "https://example.com/books", Text: &notion.Text{Content: "books", Link: &notion.Link{Url: "https://example.com/books"}}},
+			{Type: "mention", PlainText: "@Ada", Mention: &notion.Mention{Type: "user", User: &notion.User{Id: "1", Name: "Ada"}}},
+			{Type: "equation", PlainText: "e=mc^2", Equation: &notion.Equation{Expression: "e=mc^2"}},
+		}}},
+		{Type: notion.BLOCK_BULLETED_LIST_ITEM, BulletedListItem: &notion.TextBlock{RichText: text("First")}, Children: []notion.Block{
+			{Type: notion.BLOCK_BULLETED_LIST_ITEM, BulletedListItem: &notion.TextBlock{RichText: text("Nested")}},
+		}},
+		{Type: notion.BLOCK_BULLETED_LIST_ITEM, BulletedListItem: &notion.TextBlock{RichText: text("Second")}},
+		{Type: notion.BLOCK_NUMBERED_LIST_ITEM, NumberedListItem: &notion.TextBlock{RichText: text("One")}},
+		{Type: notion.BLOCK_NUMBERED_LIST_ITEM, NumberedListItem: &notion.TextBlock{RichText: text("Two")}},
+		{Type: notion.BLOCK_TO_DO, ToDo: &notion.ToDoBlock{RichText: text("Done"), Checked: true}},
+		{Type: notion.BLOCK_TO_DO, ToDo: &notion.ToDoBlock{RichText: text("Not done")}},
+		{Type: notion.BLOCK_TOGGLE, Toggle: &notion.TextBlock{RichText: text("More")}, Children: []notion.Block{
+			{Type: notion.BLOCK_PARAGRAPH, Paragraph: &notion.TextBlock{RichText: text("Hidden")}},
+		}},
+		{Type: notion.BLOCK_QUOTE, Quote: &notion.TextBlock{RichText: text("To be")}},
+		{Type: notion.BLOCK_CODE, Code: &notion.CodeBlock{RichText: text("fmt.Println(\"<hi>\")"), Language: "go"}},
+		{Type: notion.BLOCK_TABLE, Table: &notion.TableBlock{TableWidth: 2, HasColumnHeader: true}, Children: []notion.Block{
+			{Type: notion.BLOCK_TABLE_ROW, TableRow: &notion.TableRowBlock{Cells: [][]notion.RichText{text("Name"), text("Value")}}},
+			{Type: notion.BLOCK_TABLE_ROW, TableRow: &notion.TableRowBlock{Cells: [][]notion.RichText{text("a|b"), text("1")}}},
+		}},
+		{Type: notion.BLOCK_IMAGE, Image: &notion.FileBlock{Type: "external", External: &notion.ExternalFile{Url: "https://example.com/cat.png"}, Caption: text("A cat")}},
+		{Type: notion.BLOCK_DIVIDER, Divider: &struct{}{}},
+	}
+}